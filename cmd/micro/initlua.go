@@ -15,6 +15,7 @@ import (
 	"github.com/micro-editor/micro/v2/internal/screen"
 	"github.com/micro-editor/micro/v2/internal/shell"
 	"github.com/micro-editor/micro/v2/internal/util"
+	"github.com/micro-editor/micro/v2/internal/watcher"
 )
 
 func init() {
@@ -60,6 +61,14 @@ func luaImportMicro() *lua.LTable {
 			timerChan <- f
 		})
 	}))
+	ulua.L.SetField(pkg, "WatchPath", luar.New(ulua.L, watcher.Watch))
+	ulua.L.SetField(pkg, "UnwatchPath", luar.New(ulua.L, watcher.Unwatch))
+	ulua.L.SetField(pkg, "AddQuickfix", luar.New(ulua.L, action.AddQuickfix))
+	ulua.L.SetField(pkg, "ClearQuickfix", luar.New(ulua.L, action.ClearQuickfix))
+	ulua.L.SetField(pkg, "SetQuickfixFromOutput", luar.New(ulua.L, action.SetQuickfixFromOutput))
+	ulua.L.SetField(pkg, "SetQuickfixFromMessages", luar.New(ulua.L, func() {
+		action.SetQuickfix(buffer.MessagesQuickfix())
+	}))
 
 	return pkg
 }
@@ -25,6 +25,7 @@ import (
 	"github.com/micro-editor/micro/v2/internal/screen"
 	"github.com/micro-editor/micro/v2/internal/shell"
 	"github.com/micro-editor/micro/v2/internal/util"
+	"github.com/micro-editor/micro/v2/internal/watcher"
 	"github.com/micro-editor/tcell/v2"
 	lua "github.com/yuin/gopher-lua"
 )
@@ -38,6 +39,8 @@ var (
 	flagProfile   = flag.Bool("profile", false, "Enable CPU profiling (writes profile info to ./micro.prof)")
 	flagPlugin    = flag.String("plugin", "", "Plugin command")
 	flagClean     = flag.Bool("clean", false, "Clean configuration directory")
+	flagSession   = flag.String("session", "", "Restore a session saved with the session command")
+	flagDiff      = flag.Bool("diff", false, "Show the differences between two files side by side")
 	optionFlags   map[string]*string
 
 	sighup chan os.Signal
@@ -63,6 +66,11 @@ func InitFlags() {
 		fmt.Println("    \tShow all options help and exit")
 		fmt.Println("-debug")
 		fmt.Println("    \tEnable debug mode (enables logging to ./log.txt)")
+		fmt.Println("-diff FILE1 FILE2")
+		fmt.Println("    \tShow the differences between two files side by side")
+		fmt.Println("-session name")
+		fmt.Println("    \tRestore the tabs and splits of a session saved with the `session save` command")
+		fmt.Println("    \tThe files given on the command line are opened in new tabs after them")
 		fmt.Println("-profile")
 		fmt.Println("    \tEnable CPU profiling (writes profile info to ./micro.prof")
 		fmt.Println("    \tso it can be analyzed later with \"go tool pprof micro.prof\")")
@@ -416,7 +424,24 @@ func main() {
 		runtime.Goexit()
 	}
 
-	action.InitTabs(b)
+	if *flagDiff && len(b) != 2 {
+		screen.TermMessage("-diff needs exactly two files")
+	}
+	if *flagDiff && len(b) == 2 {
+		action.InitTabs(b[:1])
+		action.MainTab().CurPane().DiffSplit(b[1])
+	} else {
+		action.InitTabs(b)
+	}
+
+	if *flagSession != "" {
+		err = action.LoadSession(*flagSession, len(args) > 0)
+	} else if len(args) == 0 && isatty.IsTerminal(os.Stdin.Fd()) && config.GetGlobalOption("autosession").(bool) {
+		err = action.LoadAutoSession()
+	}
+	if err != nil {
+		screen.TermMessage(err)
+	}
 
 	err = config.RunPluginFn("init")
 	if err != nil {
@@ -508,6 +533,8 @@ func DoEvent() {
 		}
 	case <-shell.CloseTerms:
 		action.Tabs.CloseTerms()
+	case e := <-watcher.Events:
+		action.HandleFileEvent(e)
 	case event = <-screen.Events:
 	case <-screen.DrawChan():
 		for len(screen.DrawChan()) > 0 {
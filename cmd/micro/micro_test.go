@@ -327,6 +327,92 @@ func TestSearchAndReplace(t *testing.T) {
 	assert.Equal(t, srTest3, string(data))
 }
 
+func TestSession(t *testing.T) {
+	file1 := createTestFile(t, "one\ntwo\nthree")
+	file2 := createTestFile(t, "four")
+
+	openFile(file1)
+	injectKey(tcell.KeyDown, 0, tcell.ModNone)
+	runCommand("vsplit " + file2)
+	runCommand("hsplit")
+	runCommand("session save test")
+
+	// close the splits before restoring them
+	injectKey(tcell.KeyCtrlQ, rune(tcell.KeyCtrlQ), tcell.ModCtrl)
+	injectKey(tcell.KeyCtrlQ, rune(tcell.KeyCtrlQ), tcell.ModCtrl)
+	assert.Len(t, action.MainTab().Panes, 1)
+
+	runCommand("session load test")
+
+	tab := action.MainTab()
+	if !assert.Len(t, tab.Panes, 3) {
+		return
+	}
+	var paths []string
+	for _, p := range tab.Panes {
+		paths = append(paths, p.(*action.BufPane).Buf.Path)
+	}
+	assert.Equal(t, []string{file1, file2, ""}, paths)
+	assert.Equal(t, buffer.Loc{X: 0, Y: 1}, tab.Panes[0].(*action.BufPane).Cursor.Loc)
+	assert.Equal(t, tab.Panes[2], tab.CurPane())
+
+	children := tab.Node.Children()
+	if assert.Len(t, children, 2) {
+		assert.Len(t, children[1].Children(), 2)
+	}
+}
+
+func TestDiffSplit(t *testing.T) {
+	file1 := createTestFile(t, "a\nb\nc\nd")
+	file2 := createTestFile(t, "a\nB\nc\nd\ne")
+
+	openFile(file1)
+	runCommand("diffsplit " + file2)
+
+	// the new pane is on the right of the current one
+	tab := action.MainTab()
+	right := tab.CurPane()
+	i := tab.GetPane(right.ID())
+	if !assert.Greater(t, i, 0) {
+		return
+	}
+	left := tab.Panes[i-1].(*action.BufPane)
+	assert.Equal(t, file1, left.Buf.Path)
+
+	// the screen is drawn before handling the next event
+	injectKey(tcell.KeyDown, 0, tcell.ModNone)
+
+	// the line missing on the left is replaced by a filler line
+	v := left.GetView()
+	row := ""
+	for x := v.X; x < v.X+v.Width; x++ {
+		c, _, _, _ := sim.GetContent(x, v.Y+4)
+		row += string(c)
+	}
+	assert.Contains(t, row, "---")
+
+	assert.True(t, right.DiffCopyLeft())
+	assert.Equal(t, "a\nB\nc\nd", string(left.Buf.Bytes()))
+
+	assert.True(t, right.DiffNext())
+	assert.Equal(t, 4, right.Cursor.Y)
+	assert.True(t, right.DiffCopyLeft())
+	assert.Equal(t, "a\nB\nc\nd\ne", string(left.Buf.Bytes()))
+	assert.False(t, right.DiffNext())
+
+	runCommand("diffoff")
+	assert.False(t, right.DiffCopyLeft())
+
+	left.Buf.Save()
+	injectKey(tcell.KeyCtrlQ, rune(tcell.KeyCtrlQ), tcell.ModCtrl)
+}
+
+func runCommand(cmd string) {
+	injectKey(tcell.KeyCtrlE, rune(tcell.KeyCtrlE), tcell.ModCtrl)
+	injectString(cmd)
+	injectKey(tcell.KeyEnter, rune(tcell.KeyEnter), tcell.ModNone)
+}
+
 func TestMultiCursor(t *testing.T) {
 	// TODO
 }
@@ -147,6 +147,54 @@ func (h *BufPane) MouseRelease(e *tcell.EventMouse) bool {
 	return true
 }
 
+// mouseBlockLoc returns the line and the visual column of a mouse event,
+// the column being possibly past the end of the line
+func (h *BufPane) mouseBlockLoc(e *tcell.EventMouse) (int, int) {
+	mx, my := e.Position()
+	loc := h.LocFromVisual(buffer.Loc{mx, my})
+	if h.Buf.Settings["softwrap"].(bool) {
+		return h.VLocFromLoc(loc).VisualX, loc.Y
+	}
+	v := h.BufView()
+	return util.Max(mx-v.X+v.StartCol, 0), loc.Y
+}
+
+// MouseBlockPress starts a rectangular selection at the mouse position
+func (h *BufPane) MouseBlockPress(e *tcell.EventMouse) bool {
+	_, my := e.Position()
+	// ignore click on the status line
+	if my >= h.BufView().Y+h.BufView().Height {
+		return false
+	}
+	if h.Buf.NumCursors() > 1 {
+		h.Buf.ClearCursors()
+		h.Cursor = h.Buf.GetActiveCursor()
+	}
+
+	vx, y := h.mouseBlockLoc(e)
+	h.Cursor.Deselect(true)
+	h.Cursor.Y = y
+	h.Cursor.StartBlockSelection(vx)
+	h.DoubleClick = false
+	h.TripleClick = false
+	h.Relocate()
+	return true
+}
+
+// MouseBlockDrag extends the rectangular selection to the mouse position
+func (h *BufPane) MouseBlockDrag(e *tcell.EventMouse) bool {
+	_, my := e.Position()
+	// ignore drag on the status line
+	if my >= h.BufView().Y+h.BufView().Height {
+		return false
+	}
+
+	vx, y := h.mouseBlockLoc(e)
+	h.Cursor.SetBlockCorner(vx, y)
+	h.Relocate()
+	return true
+}
+
 // ScrollUpAction scrolls the view up
 func (h *BufPane) ScrollUpAction() bool {
 	h.ScrollUp(util.IntOpt(h.Buf.Settings["scrollspeed"]))
@@ -378,6 +426,34 @@ func (h *BufPane) SelectDown() bool {
 	return true
 }
 
+// SelectBlockUp extends the rectangular selection up one line
+func (h *BufPane) SelectBlockUp() bool {
+	h.Cursor.MoveBlockCorner(0, -1)
+	h.Relocate()
+	return true
+}
+
+// SelectBlockDown extends the rectangular selection down one line
+func (h *BufPane) SelectBlockDown() bool {
+	h.Cursor.MoveBlockCorner(0, 1)
+	h.Relocate()
+	return true
+}
+
+// SelectBlockLeft extends the rectangular selection left one column
+func (h *BufPane) SelectBlockLeft() bool {
+	h.Cursor.MoveBlockCorner(-1, 0)
+	h.Relocate()
+	return true
+}
+
+// SelectBlockRight extends the rectangular selection right one column
+func (h *BufPane) SelectBlockRight() bool {
+	h.Cursor.MoveBlockCorner(1, 0)
+	h.Relocate()
+	return true
+}
+
 // SelectLeft selects the character to the left of the cursor
 func (h *BufPane) SelectLeft() bool {
 	loc := h.Cursor.Loc
@@ -1102,6 +1178,7 @@ func (h *BufPane) Search(str string, useRegex bool, searchDown bool) error {
 		return err
 	}
 	if found {
+		h.pushJump(h.Cursor.Loc)
 		h.Cursor.SetSelectionStart(match[0])
 		h.Cursor.SetSelectionEnd(match[1])
 		h.Cursor.OrigSelection[0] = h.Cursor.CurSelection[0]
@@ -1117,6 +1194,9 @@ func (h *BufPane) Search(str string, useRegex bool, searchDown bool) error {
 }
 
 func (h *BufPane) find(useRegex bool) bool {
+	if h.isHex() {
+		return h.findHex()
+	}
 	h.searchOrig = h.Cursor.Loc
 	prompt := "Find: "
 	if useRegex {
@@ -1145,6 +1225,7 @@ func (h *BufPane) find(useRegex bool) bool {
 			if err != nil {
 				InfoBar.Error(err)
 			} else if found {
+				h.pushJump(h.searchOrig)
 				h.Cursor.SetSelectionStart(match[0])
 				h.Cursor.SetSelectionEnd(match[1])
 				h.Cursor.OrigSelection[0] = h.Cursor.CurSelection[0]
@@ -1204,6 +1285,10 @@ func (h *BufPane) FindNext() bool {
 	if h.Buf.LastSearch == "" {
 		return false
 	}
+	if h.isHex() {
+		h.findHexNext(true)
+		return true
+	}
 	// If the cursor is at the start of a selection and we search we want
 	// to search from the end of the selection in the case that
 	// the selection is a search result in which case we wouldn't move at
@@ -1225,6 +1310,7 @@ func (h *BufPane) FindNext() bool {
 		match, found, _ = h.Buf.FindNext(h.Buf.LastSearch, h.Buf.Start(), h.Buf.End(), searchLoc, true, h.Buf.LastSearchRegex)
 	}
 	if found {
+		h.pushJump(h.Cursor.Loc)
 		h.Cursor.SetSelectionStart(match[0])
 		h.Cursor.SetSelectionEnd(match[1])
 		h.Cursor.OrigSelection[0] = h.Cursor.CurSelection[0]
@@ -1241,6 +1327,10 @@ func (h *BufPane) FindPrevious() bool {
 	if h.Buf.LastSearch == "" {
 		return false
 	}
+	if h.isHex() {
+		h.findHexNext(false)
+		return true
+	}
 	// If the cursor is at the end of a selection and we search we want
 	// to search from the beginning of the selection in the case that
 	// the selection is a search result in which case we wouldn't move at
@@ -1262,6 +1352,7 @@ func (h *BufPane) FindPrevious() bool {
 		match, found, _ = h.Buf.FindNext(h.Buf.LastSearch, h.Buf.Start(), h.Buf.End(), searchLoc, false, h.Buf.LastSearchRegex)
 	}
 	if found {
+		h.pushJump(h.Cursor.Loc)
 		h.Cursor.SetSelectionStart(match[0])
 		h.Cursor.SetSelectionEnd(match[1])
 		h.Cursor.OrigSelection[0] = h.Cursor.CurSelection[0]
@@ -1275,6 +1366,9 @@ func (h *BufPane) FindPrevious() bool {
 
 // DiffNext searches forward until the beginning of the next block of diffs
 func (h *BufPane) DiffNext() bool {
+	if h.diff != nil {
+		return h.diff.gotoHunk(h, true)
+	}
 	cur := h.Cursor.Loc.Y
 	dl, err := h.Buf.FindNextDiffLine(cur, true)
 	if err != nil {
@@ -1286,6 +1380,9 @@ func (h *BufPane) DiffNext() bool {
 
 // DiffPrevious searches forward until the end of the previous block of diffs
 func (h *BufPane) DiffPrevious() bool {
+	if h.diff != nil {
+		return h.diff.gotoHunk(h, false)
+	}
 	cur := h.Cursor.Loc.Y
 	dl, err := h.Buf.FindNextDiffLine(cur, false)
 	if err != nil {
@@ -1346,7 +1443,7 @@ func (h *BufPane) Copy() bool {
 	if !h.Cursor.HasSelection() {
 		return false
 	}
-	h.Cursor.CopySelection(clipboard.ClipboardReg)
+	h.Cursor.CopySelection(h.clipRegister())
 	h.freshClip = false
 	InfoBar.Message("Copied selection")
 	h.Relocate()
@@ -1365,7 +1462,7 @@ func (h *BufPane) CopyLine() bool {
 	if nlines == 0 {
 		return false
 	}
-	h.Cursor.CopySelection(clipboard.ClipboardReg)
+	h.Cursor.CopySelection(h.clipRegister())
 	h.freshClip = false
 	if nlines > 1 {
 		InfoBar.Message(fmt.Sprintf("Copied %d lines", nlines))
@@ -1386,7 +1483,7 @@ func (h *BufPane) Cut() bool {
 	if !h.Cursor.HasSelection() {
 		return false
 	}
-	h.Cursor.CopySelection(clipboard.ClipboardReg)
+	h.Cursor.CopySelection(h.clipRegister())
 	h.Cursor.DeleteSelection()
 	h.Cursor.ResetSelection()
 	h.freshClip = false
@@ -1405,15 +1502,15 @@ func (h *BufPane) CutLine() bool {
 	}
 	totalLines := nlines
 	if h.freshClip {
-		if clip, err := clipboard.Read(clipboard.ClipboardReg); err != nil {
+		if clip, err := clipboard.Read(h.clipRegister()); err != nil {
 			InfoBar.Error(err)
 			return false
 		} else {
-			clipboard.WriteMulti(clip+string(h.Cursor.GetSelection()), clipboard.ClipboardReg, h.Cursor.Num, h.Buf.NumCursors())
+			clipboard.WriteMulti(clip+string(h.Cursor.GetSelection()), h.clipRegister(), h.Cursor.Num, h.Buf.NumCursors())
 			totalLines = strings.Count(clip, "\n") + nlines
 		}
 	} else {
-		h.Cursor.CopySelection(clipboard.ClipboardReg)
+		h.Cursor.CopySelection(h.clipRegister())
 	}
 	h.freshClip = true
 	h.Cursor.DeleteSelection()
@@ -1587,10 +1684,17 @@ func (h *BufPane) MoveLinesDown() bool {
 // Paste whatever is in the system clipboard into the buffer
 // Delete and paste if the user has a selection
 func (h *BufPane) Paste() bool {
-	clip, err := clipboard.ReadMulti(clipboard.ClipboardReg, h.Cursor.Num, h.Buf.NumCursors())
+	reg := h.clipRegister()
+	clip, err := clipboard.ReadMulti(reg, h.Cursor.Num, h.Buf.NumCursors())
 	if err != nil {
 		InfoBar.Error(err)
+	} else if clipboard.IsBlock(reg, clip) {
+		h.pasteBlock(clip)
 	} else {
+		if reg == clipboard.ClipboardReg {
+			// the clipboard may have been written by another application
+			clipboard.AddToRing(clip)
+		}
 		h.paste(clip)
 	}
 	h.Relocate()
@@ -1602,6 +1706,8 @@ func (h *BufPane) PastePrimary() bool {
 	clip, err := clipboard.ReadMulti(clipboard.PrimaryReg, h.Cursor.Num, h.Buf.NumCursors())
 	if err != nil {
 		InfoBar.Error(err)
+	} else if clipboard.IsBlock(clipboard.PrimaryReg, clip) {
+		h.pasteBlock(clip)
 	} else {
 		h.paste(clip)
 	}
@@ -1610,6 +1716,7 @@ func (h *BufPane) PastePrimary() bool {
 }
 
 func (h *BufPane) paste(clip string) {
+	ring := ringIndex(clip)
 	if h.Buf.Settings["smartpaste"].(bool) {
 		if h.Cursor.X > 0 {
 			leadingPasteWS := string(util.GetLeadingWhitespace([]byte(clip)))
@@ -1626,17 +1733,32 @@ func (h *BufPane) paste(clip string) {
 		h.Cursor.ResetSelection()
 	}
 
+	start := h.Cursor.Loc
 	h.Buf.Insert(h.Cursor.Loc, clip)
 	// h.Cursor.Loc = h.Cursor.Loc.Move(Count(clip), h.Buf)
+	h.lastPaste = pasteState{start, h.Cursor.Loc, clip, ring}
 	h.freshClip = false
 	InfoBar.Message("Pasted clipboard")
 }
 
+// pasteBlock pastes a rectangular block at the cursor's column
+func (h *BufPane) pasteBlock(clip string) {
+	if h.Cursor.HasSelection() && !h.Cursor.BlockMode {
+		h.Cursor.DeleteSelection()
+		h.Cursor.ResetSelection()
+	}
+
+	h.Cursor.PasteBlock(strings.Split(clip, "\n"))
+	h.freshClip = false
+	InfoBar.Message("Pasted block")
+}
+
 // JumpToMatchingBrace moves the cursor to the matching brace if it is
 // currently on a brace
 func (h *BufPane) JumpToMatchingBrace() bool {
 	matchingBrace, left, found := h.Buf.FindMatchingBrace(h.Cursor.Loc)
 	if found {
+		h.pushJump(h.Cursor.Loc)
 		if h.Buf.Settings["matchbraceleft"].(bool) {
 			if left {
 				h.Cursor.GotoLoc(matchingBrace)
@@ -1683,6 +1805,64 @@ func (h *BufPane) JumpLine() bool {
 	return true
 }
 
+// Fold closes the innermost fold containing the cursor
+func (h *BufPane) Fold() bool {
+	if !h.Buf.Fold(h.Cursor.Y) {
+		InfoBar.Message("No fold found")
+		return false
+	}
+	h.moveCursorsOutOfFolds()
+	h.Relocate()
+	return true
+}
+
+// Unfold opens the closed fold at the cursor
+func (h *BufPane) Unfold() bool {
+	if !h.Buf.Unfold(h.Cursor.Y) {
+		return false
+	}
+	h.Relocate()
+	return true
+}
+
+// ToggleFold opens the closed fold at the cursor, or closes the innermost
+// fold containing the cursor if there is none
+func (h *BufPane) ToggleFold() bool {
+	if h.Unfold() {
+		return true
+	}
+	return h.Fold()
+}
+
+// FoldAll closes all the outermost folds of the buffer
+func (h *BufPane) FoldAll() bool {
+	h.Buf.FoldAll()
+	h.moveCursorsOutOfFolds()
+	h.Relocate()
+	return true
+}
+
+// UnfoldAll opens all the closed folds of the buffer
+func (h *BufPane) UnfoldAll() bool {
+	h.Buf.UnfoldAll()
+	h.Relocate()
+	return true
+}
+
+// moveCursorsOutOfFolds moves the cursors hidden by closed folds to the
+// first line of their fold
+func (h *BufPane) moveCursorsOutOfFolds() {
+	for _, c := range h.Buf.GetCursors() {
+		if c.HasSelection() && (h.Buf.IsHidden(c.CurSelection[0].Y) || h.Buf.IsHidden(c.CurSelection[1].Y)) {
+			c.Deselect(true)
+		}
+		if h.Buf.IsHidden(c.Y) {
+			c.GotoLoc(buffer.Loc{X: 0, Y: h.Buf.VisibleLine(c.Y)})
+			c.StoreVisualX()
+		}
+	}
+}
+
 // Start moves the viewport to the start of the buffer
 func (h *BufPane) Start() bool {
 	v := h.GetView()
@@ -1898,12 +2078,19 @@ func (h *BufPane) ClearInfo() bool {
 // ForceQuit closes the tab or view even if there are unsaved changes
 // (no prompt)
 func (h *BufPane) ForceQuit() bool {
+	if h.picker != nil {
+		h.picker.closed(h)
+	}
+	if h.diff != nil {
+		h.diff.end()
+	}
 	h.Buf.Close()
 	if len(h.tab.Panes) > 1 {
 		h.Unsplit()
 	} else if len(Tabs.List) > 1 {
 		Tabs.RemoveTab(h.splitID)
 	} else {
+		saveAutoSession()
 		screen.Screen.Fini()
 		InfoBar.Close()
 		runtime.Goexit()
@@ -1953,6 +2140,7 @@ func (h *BufPane) QuitAll() bool {
 	}
 
 	quit := func() {
+		saveAutoSession()
 		buffer.CloseOpenBuffers()
 		screen.Screen.Fini()
 		InfoBar.Close()
@@ -7,6 +7,7 @@ import (
 	luar "layeh.com/gopher-luar"
 
 	"github.com/micro-editor/micro/v2/internal/buffer"
+	"github.com/micro-editor/micro/v2/internal/clipboard"
 	"github.com/micro-editor/micro/v2/internal/config"
 	"github.com/micro-editor/micro/v2/internal/display"
 	ulua "github.com/micro-editor/micro/v2/internal/lua"
@@ -250,6 +251,23 @@ type BufPane struct {
 	// remember original location of a search in case the search is canceled
 	searchOrig buffer.Loc
 
+	// picker is set if this pane lists entries to choose from
+	picker *picker
+	// diff is set if this pane shows a side of a side by side diff
+	diff *diffSplit
+
+	// lastPaste is the text inserted by the last paste
+	lastPaste pasteState
+
+	// jumps is the list of locations the pane jumped from
+	jumps jumpList
+
+	// register is the register chosen with SelectRegister for the next
+	// action, or 0 if the clipboard actions use the clipboard
+	register clipboard.Register
+	// awaitRegister is set if the next key chooses a register
+	awaitRegister bool
+
 	// The pane may not yet be fully initialized after its creation
 	// since we may not know the window geometry yet. In such case we finish
 	// its initialization a bit later, after the initial resize.
@@ -289,6 +307,7 @@ func NewBufPaneFromBuf(buf *buffer.Buffer, tab *Tab) *BufPane {
 func (h *BufPane) finishInitialize() {
 	h.initialRelocate()
 	h.initialized = true
+	h.offerHexView()
 
 	err := config.RunPluginFn("onBufPaneOpen", luar.New(ulua.L, h))
 	if err != nil {
@@ -304,6 +323,15 @@ func (h *BufPane) Resize(width, height int) {
 	}
 }
 
+// Display shows the pane, after synchronizing it with the other pane of its
+// diff if any
+func (h *BufPane) Display() {
+	if h.diff != nil {
+		h.diff.update()
+	}
+	h.BWindow.Display()
+}
+
 // SetTab sets this pane's tab.
 func (h *BufPane) SetTab(t *Tab) {
 	h.tab = t
@@ -355,6 +383,7 @@ func (h *BufPane) OpenBuffer(b *buffer.Buffer) {
 	// pressed when the editor is opened
 	h.resetMouse()
 	h.lastClickTime = time.Time{}
+	h.offerHexView()
 }
 
 // GotoLoc moves the cursor to a new location and adjusts the view accordingly.
@@ -426,27 +455,10 @@ func (h *BufPane) getReloadSetting() string {
 
 // HandleEvent executes the tcell event properly
 func (h *BufPane) HandleEvent(event tcell.Event) {
-	if h.Buf.ExternallyModified() && !h.Buf.ReloadDisabled {
-		reload := h.getReloadSetting()
+	h.hidePopup()
 
-		if reload == "prompt" {
-			InfoBar.YNPrompt("The file on disk has changed. Reload file? (y,n,esc)", func(yes, canceled bool) {
-				if canceled {
-					h.Buf.DisableReload()
-				}
-				if !yes || canceled {
-					h.Buf.UpdateModTime()
-				} else {
-					h.ReOpen()
-				}
-			})
-		} else if reload == "auto" {
-			h.ReOpen()
-		} else if reload == "disabled" {
-			h.Buf.DisableReload()
-		} else {
-			InfoBar.Message("Invalid reload setting")
-		}
+	if h.Buf.ChangedOnDisk {
+		h.checkFileChange()
 	}
 
 	switch e := event.(type) {
@@ -456,15 +468,31 @@ func (h *BufPane) HandleEvent(event tcell.Event) {
 		}
 		h.DoKeyEvent(re)
 	case *tcell.EventPaste:
+		clipboard.AddToRing(e.Text())
 		h.paste(e.Text())
 		h.Relocate()
 	case *tcell.EventKey:
+		if h.picker != nil && h.picker.handleKey(h, e) {
+			return
+		}
+		if h.isHex() && h.handleHexKey(e) {
+			return
+		}
+		if h.awaitRegister {
+			h.chooseRegister(e)
+			return
+		}
+		chosen := h.register != 0
 		ke := keyEvent(e)
 
 		done := h.DoKeyEvent(ke)
 		if !done && e.Key() == tcell.KeyRune {
 			h.DoRuneInsert(e.Rune())
 		}
+		// the chosen register only applies to the action following its choice
+		if chosen {
+			h.register = 0
+		}
 	case *tcell.EventMouse:
 		if e.Buttons() != tcell.ButtonNone {
 			me := MouseEvent{
@@ -500,6 +528,16 @@ func (h *BufPane) HandleEvent(event tcell.Event) {
 		}
 	}
 	h.Buf.MergeCursors()
+	if h.isHex() {
+		h.snapHexCursor()
+	}
+
+	if h.picker != nil && h.picker.preview != nil {
+		// the pane the list was opened from may have been closed
+		if _, ok := h.picker.sourceIndex(); ok {
+			h.picker.preview(h.picker.source, h.Cursor.Y)
+		}
+	}
 
 	if h.IsActive() {
 		// Display any gutter messages for this line
@@ -627,17 +665,22 @@ func (h *BufPane) DoRuneInsert(r rune) {
 		if !h.PluginCB("preRune", string(r)) {
 			continue
 		}
-		if c.HasSelection() {
-			c.DeleteSelection()
+		if c.HasBlockSelection() {
+			// typing in a block types on all its lines
+			c.InsertBlockText(string(r))
+		} else {
+			if c.HasSelection() {
+				c.DeleteSelection()
+			}
 			c.ResetSelection()
-		}
 
-		if h.Buf.OverwriteMode {
-			next := c.Loc
-			next.X++
-			h.Buf.Replace(c.Loc, next, string(r))
-		} else {
-			h.Buf.Insert(c.Loc, string(r))
+			if h.Buf.OverwriteMode {
+				next := c.Loc
+				next.X++
+				h.Buf.Replace(c.Loc, next, string(r))
+			} else {
+				h.Buf.Insert(c.Loc, string(r))
+			}
 		}
 		if recordingMacro {
 			curmacro = append(curmacro, r)
@@ -735,6 +778,10 @@ var BufKeyActions = map[string]BufKeyAction{
 	"SelectToStart":             (*BufPane).SelectToStart,
 	"SelectToEnd":               (*BufPane).SelectToEnd,
 	"SelectUp":                  (*BufPane).SelectUp,
+	"SelectBlockUp":             (*BufPane).SelectBlockUp,
+	"SelectBlockDown":           (*BufPane).SelectBlockDown,
+	"SelectBlockLeft":           (*BufPane).SelectBlockLeft,
+	"SelectBlockRight":          (*BufPane).SelectBlockRight,
 	"SelectDown":                (*BufPane).SelectDown,
 	"SelectLeft":                (*BufPane).SelectLeft,
 	"SelectRight":               (*BufPane).SelectRight,
@@ -772,6 +819,8 @@ var BufKeyActions = map[string]BufKeyAction{
 	"FindPrevious":              (*BufPane).FindPrevious,
 	"DiffNext":                  (*BufPane).DiffNext,
 	"DiffPrevious":              (*BufPane).DiffPrevious,
+	"DiffCopyLeft":              (*BufPane).DiffCopyLeft,
+	"DiffCopyRight":             (*BufPane).DiffCopyRight,
 	"Center":                    (*BufPane).Center,
 	"Undo":                      (*BufPane).Undo,
 	"Redo":                      (*BufPane).Redo,
@@ -792,6 +841,20 @@ var BufKeyActions = map[string]BufKeyAction{
 	"IndentLine":                (*BufPane).IndentLine,
 	"Paste":                     (*BufPane).Paste,
 	"PastePrimary":              (*BufPane).PastePrimary,
+	"JumpBack":                  (*BufPane).JumpBack,
+	"JumpForward":               (*BufPane).JumpForward,
+	"PrevChange":                (*BufPane).PrevChange,
+	"NextChange":                (*BufPane).NextChange,
+	"NextMessage":               (*BufPane).NextMessage,
+	"PreviousMessage":           (*BufPane).PreviousMessage,
+	"NextWarning":               (*BufPane).NextWarning,
+	"PreviousWarning":           (*BufPane).PreviousWarning,
+	"NextError":                 (*BufPane).NextError,
+	"PreviousError":             (*BufPane).PreviousError,
+	"ShowMessages":              (*BufPane).ShowMessages,
+	"PasteCycle":                (*BufPane).PasteCycle,
+	"PasteFromRing":             (*BufPane).PasteFromRing,
+	"SelectRegister":            (*BufPane).SelectRegister,
 	"SelectAll":                 (*BufPane).SelectAll,
 	"OpenFile":                  (*BufPane).OpenFile,
 	"Start":                     (*BufPane).Start,
@@ -848,6 +911,11 @@ var BufKeyActions = map[string]BufKeyAction{
 	"SkipMultiCursorBack":       (*BufPane).SkipMultiCursorBack,
 	"JumpToMatchingBrace":       (*BufPane).JumpToMatchingBrace,
 	"JumpLine":                  (*BufPane).JumpLine,
+	"Fold":                      (*BufPane).Fold,
+	"Unfold":                    (*BufPane).Unfold,
+	"ToggleFold":                (*BufPane).ToggleFold,
+	"FoldAll":                   (*BufPane).FoldAll,
+	"UnfoldAll":                 (*BufPane).UnfoldAll,
 	"Deselect":                  (*BufPane).Deselect,
 	"ClearInfo":                 (*BufPane).ClearInfo,
 	"None":                      (*BufPane).None,
@@ -862,6 +930,8 @@ var BufMouseActions = map[string]BufMouseAction{
 	"MouseDrag":        (*BufPane).MouseDrag,
 	"MouseRelease":     (*BufPane).MouseRelease,
 	"MouseMultiCursor": (*BufPane).MouseMultiCursor,
+	"MouseBlockPress":  (*BufPane).MouseBlockPress,
+	"MouseBlockDrag":   (*BufPane).MouseBlockDrag,
 }
 
 // MultiActions is a list of actions that should be executed multiple
@@ -880,6 +950,10 @@ var MultiActions = map[string]bool{
 	"SelectToStart":             true,
 	"SelectToEnd":               true,
 	"SelectUp":                  true,
+	"SelectBlockUp":             true,
+	"SelectBlockDown":           true,
+	"SelectBlockLeft":           true,
+	"SelectBlockRight":          true,
 	"SelectDown":                true,
 	"SelectLeft":                true,
 	"SelectRight":               true,
@@ -11,7 +11,9 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
+	humanize "github.com/dustin/go-humanize"
 	shellquote "github.com/kballard/go-shellquote"
 	"github.com/micro-editor/micro/v2/internal/buffer"
 	"github.com/micro-editor/micro/v2/internal/clipboard"
@@ -32,41 +34,71 @@ var commands map[string]Command
 
 func InitCommands() {
 	commands = map[string]Command{
-		"set":         {(*BufPane).SetCmd, OptionValueComplete},
-		"setlocal":    {(*BufPane).SetLocalCmd, OptionValueComplete},
-		"toggle":      {(*BufPane).ToggleCmd, OptionValueComplete},
-		"togglelocal": {(*BufPane).ToggleLocalCmd, OptionValueComplete},
-		"reset":       {(*BufPane).ResetCmd, OptionValueComplete},
-		"show":        {(*BufPane).ShowCmd, OptionComplete},
-		"showkey":     {(*BufPane).ShowKeyCmd, nil},
-		"run":         {(*BufPane).RunCmd, nil},
-		"bind":        {(*BufPane).BindCmd, nil},
-		"unbind":      {(*BufPane).UnbindCmd, nil},
-		"quit":        {(*BufPane).QuitCmd, nil},
-		"goto":        {(*BufPane).GotoCmd, nil},
-		"jump":        {(*BufPane).JumpCmd, nil},
-		"save":        {(*BufPane).SaveCmd, nil},
-		"replace":     {(*BufPane).ReplaceCmd, nil},
-		"replaceall":  {(*BufPane).ReplaceAllCmd, nil},
-		"vsplit":      {(*BufPane).VSplitCmd, buffer.FileComplete},
-		"hsplit":      {(*BufPane).HSplitCmd, buffer.FileComplete},
-		"tab":         {(*BufPane).NewTabCmd, buffer.FileComplete},
-		"help":        {(*BufPane).HelpCmd, HelpComplete},
-		"eval":        {(*BufPane).EvalCmd, nil},
-		"log":         {(*BufPane).ToggleLogCmd, nil},
-		"plugin":      {(*BufPane).PluginCmd, PluginComplete},
-		"reload":      {(*BufPane).ReloadCmd, nil},
-		"reopen":      {(*BufPane).ReopenCmd, nil},
-		"cd":          {(*BufPane).CdCmd, buffer.FileComplete},
-		"pwd":         {(*BufPane).PwdCmd, nil},
-		"open":        {(*BufPane).OpenCmd, buffer.FileComplete},
-		"tabmove":     {(*BufPane).TabMoveCmd, nil},
-		"tabswitch":   {(*BufPane).TabSwitchCmd, nil},
-		"term":        {(*BufPane).TermCmd, nil},
-		"memusage":    {(*BufPane).MemUsageCmd, nil},
-		"retab":       {(*BufPane).RetabCmd, nil},
-		"raw":         {(*BufPane).RawCmd, nil},
-		"textfilter":  {(*BufPane).TextFilterCmd, nil},
+		"set":            {(*BufPane).SetCmd, OptionValueComplete},
+		"setlocal":       {(*BufPane).SetLocalCmd, OptionValueComplete},
+		"toggle":         {(*BufPane).ToggleCmd, OptionValueComplete},
+		"togglelocal":    {(*BufPane).ToggleLocalCmd, OptionValueComplete},
+		"reset":          {(*BufPane).ResetCmd, OptionValueComplete},
+		"show":           {(*BufPane).ShowCmd, OptionComplete},
+		"showkey":        {(*BufPane).ShowKeyCmd, nil},
+		"run":            {(*BufPane).RunCmd, nil},
+		"bind":           {(*BufPane).BindCmd, nil},
+		"unbind":         {(*BufPane).UnbindCmd, nil},
+		"quit":           {(*BufPane).QuitCmd, nil},
+		"goto":           {(*BufPane).GotoCmd, nil},
+		"jump":           {(*BufPane).JumpCmd, nil},
+		"save":           {(*BufPane).SaveCmd, nil},
+		"replace":        {(*BufPane).ReplaceCmd, nil},
+		"replaceall":     {(*BufPane).ReplaceAllCmd, nil},
+		"grep":           {(*BufPane).GrepCmd, buffer.FileComplete},
+		"replaceproject": {(*BufPane).ReplaceProjectCmd, buffer.FileComplete},
+		"vsplit":         {(*BufPane).VSplitCmd, buffer.FileComplete},
+		"diffsplit":      {(*BufPane).DiffSplitCmd, buffer.FileComplete},
+		"diffoff":        {(*BufPane).DiffOffCmd, nil},
+		"hsplit":         {(*BufPane).HSplitCmd, buffer.FileComplete},
+		"tab":            {(*BufPane).NewTabCmd, buffer.FileComplete},
+		"help":           {(*BufPane).HelpCmd, HelpComplete},
+		"eval":           {(*BufPane).EvalCmd, nil},
+		"log":            {(*BufPane).ToggleLogCmd, nil},
+		"plugin":         {(*BufPane).PluginCmd, PluginComplete},
+		"reload":         {(*BufPane).ReloadCmd, nil},
+		"reopen":         {(*BufPane).ReopenCmd, nil},
+		"merge":          {(*BufPane).MergeCmd, nil},
+		"gitblame":       {(*BufPane).GitBlameCmd, nil},
+		"stagehunk":      {(*BufPane).StageHunkCmd, nil},
+		"reverthunk":     {(*BufPane).RevertHunkCmd, nil},
+		"cd":             {(*BufPane).CdCmd, buffer.FileComplete},
+		"pwd":            {(*BufPane).PwdCmd, nil},
+		"open":           {(*BufPane).OpenCmd, buffer.FileComplete},
+		"tabmove":        {(*BufPane).TabMoveCmd, nil},
+		"tabswitch":      {(*BufPane).TabSwitchCmd, nil},
+		"term":           {(*BufPane).TermCmd, nil},
+		"memusage":       {(*BufPane).MemUsageCmd, nil},
+		"retab":          {(*BufPane).RetabCmd, nil},
+		"normalizeeol":   {(*BufPane).NormalizeEolCmd, nil},
+		"hexview":        {(*BufPane).HexViewCmd, nil},
+		"detectindent":   {(*BufPane).DetectIndentCmd, nil},
+		"raw":            {(*BufPane).RawCmd, nil},
+		"textfilter":     {(*BufPane).TextFilterCmd, nil},
+		"undo":           {(*BufPane).UndoCmd, nil},
+		"earlier":        {(*BufPane).EarlierCmd, nil},
+		"later":          {(*BufPane).LaterCmd, nil},
+		"undolist":       {(*BufPane).UndoListCmd, nil},
+		"undotree":       {(*BufPane).UndoTreeCmd, nil},
+		"fold":           {(*BufPane).FoldCmd, nil},
+		"unfold":         {(*BufPane).UnfoldCmd, nil},
+		"togglefold":     {(*BufPane).ToggleFoldCmd, nil},
+		"foldall":        {(*BufPane).FoldAllCmd, nil},
+		"unfoldall":      {(*BufPane).UnfoldAllCmd, nil},
+		"registers":      {(*BufPane).RegistersCmd, nil},
+		"killring":       {(*BufPane).KillRingCmd, nil},
+		"copen":          {(*BufPane).COpenCmd, nil},
+		"cclose":         {(*BufPane).CCloseCmd, nil},
+		"cnext":          {(*BufPane).CNextCmd, nil},
+		"cprev":          {(*BufPane).CPrevCmd, nil},
+		"cexec":          {(*BufPane).CExecCmd, nil},
+		"cmessages":      {(*BufPane).CMessagesCmd, nil},
+		"session":        {(*BufPane).SessionCmd, nil},
 	}
 }
 
@@ -123,6 +155,31 @@ func (h *BufPane) RetabCmd(args []string) {
 	h.Buf.Retab()
 }
 
+// NormalizeEolCmd ends every line with the line ending of the file format
+// of the buffer
+func (h *BufPane) NormalizeEolCmd(args []string) {
+	n := h.Buf.NormalizeEndings()
+	if n == 0 {
+		InfoBar.Message("All lines already have the same line ending")
+		return
+	}
+	InfoBar.Message(fmt.Sprintf("Changed the line ending of %d lines to %s", n, h.Buf.Settings["fileformat"]))
+}
+
+// DetectIndentCmd sets the indentation options of the buffer from the
+// indentation of its text
+func (h *BufPane) DetectIndentCmd(args []string) {
+	if !h.Buf.DetectIndent() {
+		InfoBar.Error("The indentation of the buffer is not clear enough to be detected")
+		return
+	}
+	if h.Buf.Settings["tabstospaces"].(bool) {
+		InfoBar.Message(fmt.Sprintf("Indenting with %d spaces", util.IntOpt(h.Buf.Settings["tabsize"])))
+	} else {
+		InfoBar.Message("Indenting with tabs")
+	}
+}
+
 // RawCmd opens a new raw view which displays the escape sequences micro
 // is receiving in real-time
 func (h *BufPane) RawCmd(args []string) {
@@ -270,11 +327,12 @@ func (h *BufPane) CdCmd(args []string) {
 // Alloc shows how many bytes are currently in use
 // Sys shows how many bytes have been requested from the operating system
 // NumGC shows how many times the GC has been run
+// Buffer shows how the lines of the current buffer are stored
 // Note that Go commonly reserves more memory from the OS than is currently in-use/required
 // Additionally, even if Go returns memory to the OS, the OS does not always claim it because
 // there may be plenty of memory to spare
 func (h *BufPane) MemUsageCmd(args []string) {
-	InfoBar.Message(util.GetMemStats())
+	InfoBar.Message(util.GetMemStats() + ", Buffer: " + h.Buf.StorageInfo())
 }
 
 // PwdCmd prints the current working directory
@@ -296,6 +354,7 @@ func (h *BufPane) OpenCmd(args []string) {
 				InfoBar.Error(err)
 				return
 			}
+			h.pushJump(h.Cursor.Loc)
 			h.OpenBuffer(b)
 		}
 		if h.Buf.Modified() && !h.Buf.Shared() {
@@ -398,22 +457,48 @@ func reloadRuntime(reloadPlugins bool) {
 	}
 }
 
-// ReopenCmd reopens the buffer (reload from disk)
+// ReopenCmd reopens the buffer (reload from disk), with the encoding given
+// by a ++enc=<name> argument if there is one
 func (h *BufPane) ReopenCmd(args []string) {
+	enc := ""
+	for _, a := range args {
+		if !strings.HasPrefix(a, "++enc=") {
+			InfoBar.Error("Invalid argument ", a)
+			return
+		}
+		enc = strings.TrimPrefix(a, "++enc=")
+		if _, err := config.GetEncoding(enc); err != nil {
+			InfoBar.Error("Unknown encoding ", enc)
+			return
+		}
+	}
+	reopen := func() {
+		if enc != "" {
+			h.Buf.SetOptionNative("encoding", enc)
+		}
+		h.ReOpen()
+	}
+
 	if h.Buf.Modified() {
 		InfoBar.YNPrompt("Save file before reopen?", func(yes, canceled bool) {
 			if !canceled && yes {
 				h.Save()
-				h.ReOpen()
+				reopen()
 			} else if !canceled {
-				h.ReOpen()
+				reopen()
 			}
 		})
 	} else {
-		h.ReOpen()
+		reopen()
 	}
 }
 
+// MergeCmd merges the changes of the file on disk into the buffer, keeping
+// the unsaved changes of the buffer
+func (h *BufPane) MergeCmd(args []string) {
+	h.mergeFromDisk()
+}
+
 func (h *BufPane) openHelp(page string, hsplit bool, forceSplit bool) error {
 	if data, err := config.FindRuntimeFile(config.RTHelp, page).Data(); err != nil {
 		return errors.New(fmt.Sprintf("Unable to load help text for %s: %v", page, err))
@@ -810,6 +895,10 @@ func (h *BufPane) ShowCmd(args []string) {
 		return
 	}
 
+	if file := h.Buf.SettingOrigin(args[0]); file != "" {
+		InfoBar.Message(option, " (from ", file, ")")
+		return
+	}
 	InfoBar.Message(option)
 }
 
@@ -893,6 +982,10 @@ func (h *BufPane) QuitCmd(args []string) {
 // position in the buffer
 // For example: `goto line`, or `goto line:col`
 func (h *BufPane) GotoCmd(args []string) {
+	if h.isHex() {
+		h.gotoHexOffset(args)
+		return
+	}
 	line, col, err := h.parseLineCol(args)
 	if err != nil {
 		InfoBar.Error(err)
@@ -905,6 +998,7 @@ func (h *BufPane) GotoCmd(args []string) {
 	line = util.Clamp(line-1, 0, h.Buf.LinesNum()-1)
 	col = util.Clamp(col-1, 0, util.CharacterCount(h.Buf.LineBytes(line)))
 
+	h.pushJump(h.Cursor.Loc)
 	h.RemoveAllMultiCursors()
 	h.Cursor.Deselect(true)
 	h.GotoLoc(buffer.Loc{col, line})
@@ -924,6 +1018,7 @@ func (h *BufPane) JumpCmd(args []string) {
 	line = util.Clamp(line-1, 0, h.Buf.LinesNum()-1)
 	col = util.Clamp(col-1, 0, util.CharacterCount(h.Buf.LineBytes(line)))
 
+	h.pushJump(h.Cursor.Loc)
 	h.RemoveAllMultiCursors()
 	h.Cursor.Deselect(true)
 	h.GotoLoc(buffer.Loc{col, line})
@@ -956,6 +1051,152 @@ func (h *BufPane) parseLineCol(args []string) (line int, col int, err error) {
 	return line, col, nil
 }
 
+// UndoCmd undoes the last action, or moves the buffer to the given state
+// of the undo tree
+// For example: `undo` or `undo 12`
+func (h *BufPane) UndoCmd(args []string) {
+	if len(args) == 0 {
+		h.Undo()
+		return
+	}
+
+	seq, err := strconv.Atoi(args[0])
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	if !h.Buf.GotoState(seq) {
+		InfoBar.Error("Undo state ", seq, " does not exist")
+		return
+	}
+	h.undoStateMessage()
+}
+
+// EarlierCmd moves the buffer back in its undo history
+// For example: `earlier 3` goes 3 states back, `earlier 5m` goes to the
+// state 5 minutes before the current one
+func (h *BufPane) EarlierCmd(args []string) {
+	h.undoTimeTravel(args, false)
+}
+
+// LaterCmd moves the buffer forward in its undo history
+// For example: `later 3` goes 3 states forward, `later 30s` goes to the
+// state 30 seconds after the current one
+func (h *BufPane) LaterCmd(args []string) {
+	h.undoTimeTravel(args, true)
+}
+
+// undoTimeTravel is a helper for EarlierCmd and LaterCmd
+func (h *BufPane) undoTimeTravel(args []string, later bool) {
+	arg := "1"
+	if len(args) > 0 {
+		arg = args[0]
+	}
+
+	steps, d, err := parseUndoDistance(arg)
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+
+	if d != 0 {
+		if later {
+			h.Buf.LaterTime(d)
+		} else {
+			h.Buf.EarlierTime(d)
+		}
+	} else {
+		if later {
+			h.Buf.Later(steps)
+		} else {
+			h.Buf.Earlier(steps)
+		}
+	}
+	h.undoStateMessage()
+}
+
+// parseUndoDistance parses the argument of EarlierCmd and LaterCmd: either
+// a number of states or a duration with a unit (s, m, h or d)
+func parseUndoDistance(arg string) (int, time.Duration, error) {
+	if steps, err := strconv.Atoi(arg); err == nil {
+		if steps < 0 {
+			return 0, 0, errors.New("Invalid undo distance: " + arg)
+		}
+		return steps, 0, nil
+	}
+
+	if strings.HasSuffix(arg, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(arg, "d"))
+		if err != nil || days < 0 {
+			return 0, 0, errors.New("Invalid undo distance: " + arg)
+		}
+		return 0, time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(arg)
+	if err != nil || d < 0 {
+		return 0, 0, errors.New("Invalid undo distance: " + arg)
+	}
+	return 0, d, nil
+}
+
+// undoStateMessage relocates the view after moving through the undo tree
+// and shows the current state
+func (h *BufPane) undoStateMessage() {
+	h.Relocate()
+	n := h.Buf.UndoTree.Current()
+	InfoBar.Message(fmt.Sprintf("Undo state %d of %d, %s", n.Seq, h.Buf.UndoTree.Len()-1, humanize.Time(n.Time)))
+}
+
+// UndoListCmd opens a split listing the ends of all the branches of the
+// undo tree, so that any of them can be restored with `undo N`
+func (h *BufPane) UndoListCmd(args []string) {
+	tree := h.Buf.UndoTree
+
+	var b strings.Builder
+	b.WriteString("  number  changes  when\n")
+	for _, seq := range tree.Leaves() {
+		mark := " "
+		if seq == tree.Cur {
+			mark = ">"
+		}
+		fmt.Fprintf(&b, "%s %6d  %7d  %s\n", mark, seq, tree.Depth(seq), humanize.Time(tree.Node(seq).Time))
+	}
+	if len(tree.Current().Children) > 0 {
+		fmt.Fprintf(&b, "\ncurrent state: %d\n", tree.Cur)
+	}
+
+	list := buffer.NewBufferFromString(b.String(), "", buffer.BTScratch)
+	list.Type.Readonly = true
+	list.SetName("Undo list")
+	h.HSplitBuf(list)
+}
+
+// FoldCmd closes the innermost fold containing the cursor
+func (h *BufPane) FoldCmd(args []string) {
+	h.Fold()
+}
+
+// UnfoldCmd opens the closed fold at the cursor
+func (h *BufPane) UnfoldCmd(args []string) {
+	h.Unfold()
+}
+
+// ToggleFoldCmd opens or closes the fold at the cursor
+func (h *BufPane) ToggleFoldCmd(args []string) {
+	h.ToggleFold()
+}
+
+// FoldAllCmd closes all the outermost folds of the buffer
+func (h *BufPane) FoldAllCmd(args []string) {
+	h.FoldAll()
+}
+
+// UnfoldAllCmd opens all the closed folds of the buffer
+func (h *BufPane) UnfoldAllCmd(args []string) {
+	h.UnfoldAll()
+}
+
 // SaveCmd saves the buffer optionally with an argument file name
 func (h *BufPane) SaveCmd(args []string) {
 	if len(args) == 0 {
@@ -1035,6 +1276,19 @@ func (h *BufPane) ReplaceCmd(args []string) {
 		inRange := func(l buffer.Loc) bool {
 			return l.GreaterEqual(start) && l.LessEqual(end)
 		}
+		// fromEnd and toEnd convert a location to and from its distance to
+		// the end of the buffer, in lines and in characters before the end
+		// of its line
+		fromEnd := func(l buffer.Loc) buffer.Loc {
+			return buffer.Loc{
+				X: util.CharacterCount(h.Buf.LineBytes(l.Y)) - l.X,
+				Y: h.Buf.LinesNum() - 1 - l.Y,
+			}
+		}
+		toEnd := func(d buffer.Loc) buffer.Loc {
+			y := h.Buf.LinesNum() - 1 - d.Y
+			return buffer.Loc{X: util.CharacterCount(h.Buf.LineBytes(y)) - d.X, Y: y}
+		}
 
 		lastMatchEnd := buffer.Loc{-1, -1}
 		var doReplacement func()
@@ -1072,13 +1326,13 @@ func (h *BufPane) ReplaceCmd(args []string) {
 
 			InfoBar.YNPrompt("Perform replacement (y,n,esc)", func(yes, canceled bool) {
 				if !canceled && yes {
-					_, nrunes := h.Buf.ReplaceRegex(locs[0], locs[1], regex, replace, !noRegex)
+					// the text after the match doesn't change, so the
+					// locations after it are kept relative to the end
+					matchEnd, rangeEnd := fromEnd(locs[1]), fromEnd(end)
+					h.Buf.ReplaceRegex(locs[0], locs[1], regex, replace, !noRegex)
 
-					searchLoc = locs[0]
-					searchLoc.X += nrunes + locs[0].Diff(locs[1], h.Buf)
-					if end.Y == locs[1].Y {
-						end = end.Move(nrunes, h.Buf)
-					}
+					searchLoc = toEnd(matchEnd)
+					end = toEnd(rangeEnd)
 					h.Cursor.Loc = searchLoc
 					nreplaced++
 				} else if !canceled && !yes {
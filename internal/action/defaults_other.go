@@ -30,6 +30,9 @@ var bufdefaults = map[string]string{
 	"CtrlShiftDown":  "SelectToEnd",
 	"Alt-{":          "ParagraphPrevious",
 	"Alt-}":          "ParagraphNext",
+	"Alt-\"":         "SelectRegister",
+	"Alt-o":          "JumpBack",
+	"Alt-i":          "JumpForward",
 	"Enter":          "InsertNewline",
 	"CtrlH":          "Backspace",
 	"Backspace":      "Backspace",
@@ -53,6 +56,7 @@ var bufdefaults = map[string]string{
 	"Ctrl-k":         "CutLine",
 	"Ctrl-d":         "Duplicate|DuplicateLine",
 	"Ctrl-v":         "Paste",
+	"Alt-y":          "PasteCycle",
 	"Ctrl-a":         "SelectAll",
 	"Ctrl-t":         "AddTab",
 	"Alt-,":          "PreviousTab|LastTab",
@@ -105,6 +109,14 @@ var bufdefaults = map[string]string{
 	"MouseMiddle":      "PastePrimary",
 	"Ctrl-MouseLeft":   "MouseMultiCursor",
 
+	"CtrlAltShiftUp":       "SelectBlockUp",
+	"CtrlAltShiftDown":     "SelectBlockDown",
+	"CtrlAltShiftLeft":     "SelectBlockLeft",
+	"CtrlAltShiftRight":    "SelectBlockRight",
+	"Alt-MouseLeft":        "MouseBlockPress",
+	"Alt-MouseLeftDrag":    "MouseBlockDrag",
+	"Alt-MouseLeftRelease": "MouseRelease",
+
 	"Alt-n":        "SpawnMultiCursor",
 	"Alt-m":        "SpawnMultiCursorSelect",
 	"AltShiftUp":   "SpawnMultiCursorUp",
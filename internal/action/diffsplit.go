@@ -0,0 +1,298 @@
+package action
+
+import (
+	"strings"
+
+	"github.com/micro-editor/micro/v2/internal/buffer"
+	"github.com/micro-editor/micro/v2/internal/display"
+	"github.com/micro-editor/micro/v2/internal/util"
+)
+
+// A diffSplit shows the differences between the buffers of two panes side by
+// side, the left one being the older version
+type diffSplit struct {
+	left, right       *BufPane
+	leftBuf, rightBuf *buffer.Buffer
+	// softwrap is the softwrap option of the buffers before the diff
+	softwrap [2]bool
+
+	// versions is the versions of the buffers when the hunks were computed
+	versions [2]uint64
+	hunks    []buffer.DiffHunk
+
+	// views is the start of the views after they were last synchronized
+	views [2]display.View
+}
+
+// DiffSplit opens the given buffer in a vertical split on the right and shows
+// the differences between it and the buffer of h
+func (h *BufPane) DiffSplit(buf *buffer.Buffer) *BufPane {
+	if h.diff != nil {
+		h.diff.end()
+	}
+	right := h.VSplitIndex(buf, true)
+	startDiff(h, right)
+	return right
+}
+
+func startDiff(left, right *BufPane) {
+	d := &diffSplit{
+		left:     left,
+		right:    right,
+		leftBuf:  left.Buf,
+		rightBuf: right.Buf,
+		softwrap: [2]bool{left.Buf.Settings["softwrap"].(bool), right.Buf.Settings["softwrap"].(bool)},
+	}
+	// the filler lines keep the sides aligned only if lines are not wrapped
+	left.Buf.SetOptionNative("softwrap", false)
+	right.Buf.SetOptionNative("softwrap", false)
+	left.diff, right.diff = d, d
+	d.computeHunks()
+}
+
+// end stops showing the diff
+func (d *diffSplit) end() {
+	for i, h := range d.panes() {
+		h.diff = nil
+		if w, ok := h.BWindow.(*display.BufWindow); ok {
+			w.SetDiffSide(nil)
+		}
+		if h.Buf == d.buffers()[i] {
+			h.Buf.SetOptionNative("softwrap", d.softwrap[i])
+		}
+	}
+}
+
+func (d *diffSplit) panes() [2]*BufPane {
+	return [2]*BufPane{d.left, d.right}
+}
+
+func (d *diffSplit) buffers() [2]*buffer.Buffer {
+	return [2]*buffer.Buffer{d.leftBuf, d.rightBuf}
+}
+
+// side returns 0 if h is the left pane and 1 if it is the right one
+func (d *diffSplit) side(h *BufPane) int {
+	if h == d.left {
+		return 0
+	}
+	return 1
+}
+
+// computeHunks computes the differences between the buffers and sets the
+// sides of the diff displayed by both panes
+func (d *diffSplit) computeHunks() {
+	d.versions = [2]uint64{d.leftBuf.Version(), d.rightBuf.Version()}
+	d.hunks = buffer.DiffHunks(d.leftBuf, d.rightBuf)
+
+	sides := [2]*display.DiffSide{}
+	for i := range sides {
+		sides[i] = &display.DiffSide{
+			Fillers: make(map[int]int),
+			Lines:   make(map[int]buffer.DiffStatus),
+			Changes: make(map[int][][2]int),
+			Old:     i == 0,
+		}
+	}
+	for _, hk := range d.hunks {
+		na, nb := hk.AEnd-hk.AStart, hk.BEnd-hk.BStart
+		paired := util.Min(na, nb)
+		for k := 0; k < paired; k++ {
+			a, b := hk.AStart+k, hk.BStart+k
+			sides[0].Lines[a] = buffer.DSModified
+			sides[1].Lines[b] = buffer.DSModified
+			sides[0].Changes[a], sides[1].Changes[b] = buffer.LineChanges(d.leftBuf.Line(a), d.rightBuf.Line(b))
+		}
+		for a := hk.AStart + paired; a < hk.AEnd; a++ {
+			sides[0].Lines[a] = buffer.DSAdded
+		}
+		for b := hk.BStart + paired; b < hk.BEnd; b++ {
+			sides[1].Lines[b] = buffer.DSAdded
+		}
+		// the side with fewer lines is padded after its lines of the hunk
+		if na > nb {
+			sides[1].Fillers[hk.BEnd] += na - nb
+		} else if nb > na {
+			sides[0].Fillers[hk.AEnd] += nb - na
+		}
+	}
+
+	for i, h := range d.panes() {
+		if w, ok := h.BWindow.(*display.BufWindow); ok {
+			w.SetDiffSide(sides[i])
+		}
+	}
+}
+
+// update recomputes the differences if a buffer has been modified and
+// scrolls the panes together. The diff ends if a pane shows another buffer.
+func (d *diffSplit) update() {
+	if d.left.Buf != d.leftBuf || d.right.Buf != d.rightBuf {
+		d.end()
+		return
+	}
+	if d.leftBuf.Version() != d.versions[0] || d.rightBuf.Version() != d.versions[1] {
+		d.computeHunks()
+	}
+
+	// the pane which has been scrolled since the last update leads, or the
+	// active one if none has
+	panes := d.panes()
+	lead := 0
+	if d.right.IsActive() {
+		lead = 1
+	}
+	for i, h := range panes {
+		v := h.GetView()
+		if v.StartLine != d.views[i].StartLine || v.StartCol != d.views[i].StartCol {
+			lead = i
+			break
+		}
+	}
+
+	from, to := panes[lead], panes[1-lead]
+	fv, tv := from.GetView(), to.GetView()
+	start := display.SLoc{Line: 0, Row: 0}
+	tv.StartLine = to.Scroll(start, from.Diff(start, fv.StartLine))
+	tv.StartCol = fv.StartCol
+	to.SetView(tv)
+
+	d.views[lead], d.views[1-lead] = *fv, *tv
+}
+
+// hunkRange returns the range of lines [start, end) of a hunk on the side of
+// the pane h
+func (d *diffSplit) hunkRange(h *BufPane, hk buffer.DiffHunk) (int, int) {
+	if d.side(h) == 0 {
+		return hk.AStart, hk.AEnd
+	}
+	return hk.BStart, hk.BEnd
+}
+
+// hunkAt returns the hunk at the line of the cursor of the pane h
+func (d *diffSplit) hunkAt(h *BufPane) (buffer.DiffHunk, bool) {
+	y := h.Cursor.Y
+	for _, hk := range d.hunks {
+		start, end := d.hunkRange(h, hk)
+		// a hunk without lines on this side is at the line after them
+		if y >= start && (y < end || y == start) {
+			return hk, true
+		}
+	}
+	return buffer.DiffHunk{}, false
+}
+
+// gotoHunk moves the cursor of the pane h to the next or previous hunk
+func (d *diffSplit) gotoHunk(h *BufPane, next bool) bool {
+	y := h.Cursor.Y
+	line := -1
+	for _, hk := range d.hunks {
+		start, _ := d.hunkRange(h, hk)
+		if next && start > y {
+			line = start
+			break
+		}
+		if !next && start < y {
+			line = start
+		}
+	}
+	if line < 0 {
+		InfoBar.Message("No more differences")
+		return false
+	}
+	h.pushJump(h.Cursor.Loc)
+	h.GotoLoc(buffer.Loc{0, util.Min(line, h.Buf.LinesNum()-1)})
+	return true
+}
+
+// copyHunk replaces the lines of the hunk at the cursor of the pane h in one
+// pane with its lines in the other pane, as a single undoable edit. The lines
+// are copied to the left pane if toLeft is set, and to the right one
+// otherwise.
+func (d *diffSplit) copyHunk(h *BufPane, toLeft bool) bool {
+	hk, ok := d.hunkAt(h)
+	if !ok {
+		InfoBar.Message("No difference at the cursor")
+		return false
+	}
+	from, to := d.left, d.right
+	if toLeft {
+		from, to = to, from
+	}
+	if to.Buf.Type.Readonly {
+		InfoBar.Error(to.Buf.GetName(), " is read-only")
+		return false
+	}
+
+	srcStart, srcEnd := d.hunkRange(from, hk)
+	lines := make([]string, 0, srcEnd-srcStart)
+	for i := srcStart; i < srcEnd; i++ {
+		lines = append(lines, from.Buf.Line(i))
+	}
+	start, end := d.hunkRange(to, hk)
+
+	var delta buffer.Delta
+	if end < to.Buf.LinesNum() {
+		text := strings.Join(lines, "\n")
+		if len(lines) > 0 {
+			text += "\n"
+		}
+		delta = buffer.Delta{Text: []byte(text), Start: buffer.Loc{0, start}, End: buffer.Loc{0, end}}
+	} else if start > 0 {
+		// the last line has no newline, so the lines are replaced from the
+		// end of the line before them
+		text := ""
+		if len(lines) > 0 {
+			text = "\n" + strings.Join(lines, "\n")
+		}
+		prev := util.CharacterCount(to.Buf.LineBytes(start - 1))
+		delta = buffer.Delta{Text: []byte(text), Start: buffer.Loc{prev, start - 1}, End: to.Buf.End()}
+	} else {
+		delta = buffer.Delta{Text: []byte(strings.Join(lines, "\n")), Start: to.Buf.Start(), End: to.Buf.End()}
+	}
+	to.Buf.MultipleReplace([]buffer.Delta{delta})
+	to.Buf.RelocateCursors()
+	return true
+}
+
+// DiffCopyLeft replaces the difference at the cursor in the left pane of a
+// side by side diff with its version in the right pane
+func (h *BufPane) DiffCopyLeft() bool {
+	if h.diff == nil {
+		return false
+	}
+	return h.diff.copyHunk(h, true)
+}
+
+// DiffCopyRight replaces the difference at the cursor in the right pane of a
+// side by side diff with its version in the left pane
+func (h *BufPane) DiffCopyRight() bool {
+	if h.diff == nil {
+		return false
+	}
+	return h.diff.copyHunk(h, false)
+}
+
+// DiffSplitCmd opens a file in a vertical split and shows its differences
+// with the current buffer side by side
+func (h *BufPane) DiffSplitCmd(args []string) {
+	if len(args) != 1 {
+		InfoBar.Error("Usage: diffsplit file")
+		return
+	}
+	buf, err := buffer.NewBufferFromFile(args[0], buffer.BTDefault)
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	h.DiffSplit(buf)
+}
+
+// DiffOffCmd stops showing the differences of the current pane
+func (h *BufPane) DiffOffCmd(args []string) {
+	if h.diff == nil {
+		InfoBar.Error("Not in a diff")
+		return
+	}
+	h.diff.end()
+}
@@ -0,0 +1,33 @@
+package action
+
+// GitBlameCmd shows or hides the commit, the author and the date of the last
+// change of every line of the buffer in a column before the line numbers
+func (h *BufPane) GitBlameCmd(args []string) {
+	if h.Buf.BlameShown() {
+		h.Buf.HideBlame()
+		return
+	}
+	if err := h.Buf.ShowBlame(); err != nil {
+		InfoBar.Error(err)
+	}
+}
+
+// StageHunkCmd adds the change at the cursor to the git index
+func (h *BufPane) StageHunkCmd(args []string) {
+	if err := h.Buf.StageHunk(h.Cursor.Y); err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	InfoBar.Message("Staged the change")
+}
+
+// RevertHunkCmd replaces the change at the cursor, as shown by the diff
+// gutter, with the text of the diff base
+func (h *BufPane) RevertHunkCmd(args []string) {
+	if err := h.Buf.RevertHunk(h.Cursor.Y); err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	h.Relocate()
+	InfoBar.Message("Reverted the change")
+}
@@ -0,0 +1,100 @@
+package action
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/micro-editor/micro/v2/internal/buffer"
+	"github.com/micro-editor/micro/v2/internal/shell"
+	"github.com/micro-editor/micro/v2/internal/util"
+)
+
+// GrepCmd searches a pattern in the files under the given paths, or under
+// the current directory, and lists the matches in a new pane as they are
+// found. Pressing enter on a match opens its file at the match. Closing the
+// pane stops the search.
+func (h *BufPane) GrepCmd(args []string) {
+	literal := false
+	var pattern string
+	var paths []string
+	for _, arg := range args {
+		switch {
+		case arg == "-F" && pattern == "":
+			literal = true
+		case pattern == "":
+			pattern = arg
+		default:
+			paths = append(paths, arg)
+		}
+	}
+	if pattern == "" {
+		InfoBar.Error("Usage: grep [-F] pattern [paths...]")
+		return
+	}
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	r, err := buffer.CompileSearch(pattern, !literal, h.Buf.Settings["ignorecase"].(bool))
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+
+	var matches []buffer.GrepMatch
+	files := 0
+
+	// the first line of the list tells how the search is going, and
+	// matches[i] is on the line i+1
+	header := "Searching for " + pattern + "..."
+	p := h.openPicker("Grep", []string{header}, 0, func(h *BufPane, line int) {
+		if line < 1 || line > len(matches) {
+			return
+		}
+		m := matches[line-1]
+		h.pushJump(h.Cursor.Loc)
+		h.openLoc(m.Path, m.Loc)
+	})
+	list := p.Buf
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.picker.keep = true
+	p.picker.onClose = cancel
+
+	go func() {
+		err := buffer.Grep(ctx, r, paths, func(path string, found []buffer.GrepMatch) {
+			var b strings.Builder
+			for _, m := range found {
+				fmt.Fprintf(&b, "\n%s:%d:%d: %s", m.Path, m.Loc.Y+1, m.Loc.X+1, m.Line)
+			}
+			shell.Jobs <- shell.JobFunction{
+				Function: func(text string, args []any) {
+					// the job may run after the list has been closed
+					if ctx.Err() != nil {
+						return
+					}
+					matches = append(matches, found...)
+					files++
+					editList(list, func() { list.Insert(list.End(), text) })
+				},
+				Output: b.String(),
+			}
+		})
+
+		shell.Jobs <- shell.JobFunction{
+			Function: func(string, []any) {
+				if ctx.Err() != nil {
+					return
+				}
+				status := fmt.Sprintf("%d matches in %d files for %s", len(matches), files, pattern)
+				if err != nil {
+					status = "Search failed: " + err.Error()
+				}
+				editList(list, func() {
+					list.Replace(buffer.Loc{X: 0, Y: 0}, buffer.Loc{X: util.CharacterCountInString(header), Y: 0}, status)
+				})
+			},
+		}
+	}()
+}
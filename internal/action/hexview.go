@@ -0,0 +1,241 @@
+package action
+
+import (
+	"strconv"
+
+	"github.com/micro-editor/micro/v2/internal/buffer"
+	"github.com/micro-editor/tcell/v2"
+)
+
+// isHex returns true if the pane shows a hex view
+func (h *BufPane) isHex() bool {
+	return h.Buf.Type.Kind == buffer.BTHex.Kind
+}
+
+// HexViewCmd reopens the file of the buffer in a hex view, or as text if
+// it is shown in a hex view
+func (h *BufPane) HexViewCmd(args []string) {
+	if h.Buf.Path == "" {
+		InfoBar.Error("The buffer has no file")
+		return
+	}
+	if h.Buf.Modified() {
+		InfoBar.Error("Save the buffer before changing its view")
+		return
+	}
+	btype := buffer.BTHex
+	if h.isHex() {
+		btype = buffer.BTDefault
+	}
+	b, err := buffer.NewBufferFromFile(h.Buf.Path, btype)
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	// the file is known to be binary if it is opened from a hex view
+	b.Binary = false
+	h.OpenBuffer(b)
+}
+
+// offerHexView asks whether to open the file of the buffer in a hex view if
+// it looks binary. It is only asked once for a buffer.
+func (h *BufPane) offerHexView() {
+	if !h.Buf.Binary || h.Buf.Type != buffer.BTDefault || h.Buf.Modified() {
+		return
+	}
+	h.Buf.Binary = false
+	InfoBar.YNPrompt("The file looks binary, open it in a hex view? (y,n,esc)", func(yes, canceled bool) {
+		if yes && !canceled {
+			h.HexViewCmd(nil)
+		}
+	})
+}
+
+// gotoHexPos moves the cursor of a hex view to the given position
+func (h *BufPane) gotoHexPos(p buffer.HexPos) {
+	h.Cursor.ResetSelection()
+	h.GotoLoc(h.Buf.HexLoc(p))
+}
+
+// snapHexCursor moves the cursor of a hex view out of the offsets and the
+// spaces, to the closest digit or character of a byte
+func (h *BufPane) snapHexCursor() {
+	if h.Cursor.HasSelection() {
+		return
+	}
+	if loc := h.Buf.HexLoc(h.Buf.HexPosAt(h.Cursor.Loc)); loc != h.Cursor.Loc {
+		h.Cursor.GotoLoc(loc)
+	}
+}
+
+// handleHexKey handles the keys which edit the bytes of a hex view or move
+// between their digits. It returns false if the key should be handled as
+// usual.
+func (h *BufPane) handleHexKey(e *tcell.EventKey) bool {
+	if e.Modifiers()&^tcell.ModShift != 0 {
+		return false
+	}
+	b := h.Buf
+	p := b.HexPosAt(h.Cursor.Loc)
+	switch e.Key() {
+	case tcell.KeyRune:
+		r := e.Rune()
+		if p.ASCII {
+			if r < 0x20 || r >= 0x7f {
+				InfoBar.Error("Only ASCII characters can be typed in the ASCII column")
+				return true
+			}
+			h.writeHexByte(p.Offset, byte(r), b.OverwriteMode)
+			p.Offset++
+			break
+		}
+		v, err := strconv.ParseUint(string(r), 16, 8)
+		if err != nil {
+			InfoBar.Error("Only hex digits can be typed in the hex column")
+			return true
+		}
+		old := b.HexByte(p.Offset)
+		if p.Low {
+			h.writeHexByte(p.Offset, old&0xf0|byte(v), true)
+			p.Offset++
+		} else {
+			h.writeHexByte(p.Offset, byte(v)<<4|old&0x0f, b.OverwriteMode)
+		}
+		p.Low = !p.Low
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if e.Modifiers() != 0 {
+			return false
+		}
+		if p.Offset > 0 {
+			b.ReplaceHexBytes(p.Offset-1, 1, nil)
+			p.Offset--
+		}
+		p.Low = false
+	case tcell.KeyDelete:
+		if e.Modifiers() != 0 {
+			return false
+		}
+		if p.Offset < b.HexLen() {
+			b.ReplaceHexBytes(p.Offset, 1, nil)
+		}
+		p.Low = false
+	case tcell.KeyTab:
+		p.ASCII = !p.ASCII
+		p.Low = false
+	case tcell.KeyLeft:
+		if e.Modifiers() != 0 {
+			return false
+		}
+		if p.Low {
+			p.Low = false
+		} else if p.Offset > 0 {
+			p.Offset--
+			p.Low = !p.ASCII
+		}
+	case tcell.KeyRight:
+		if e.Modifiers() != 0 {
+			return false
+		}
+		if !p.Low && !p.ASCII && p.Offset < b.HexLen() {
+			p.Low = true
+		} else {
+			p.Offset++
+			p.Low = false
+		}
+	default:
+		return false
+	}
+	h.gotoHexPos(p)
+	return true
+}
+
+// writeHexByte sets the byte at the given offset of a hex view, or inserts
+// it before the byte at the offset if overwrite is false. A byte written
+// after the last one is added at the end.
+func (h *BufPane) writeHexByte(off int, c byte, overwrite bool) {
+	n := 0
+	if overwrite && off < h.Buf.HexLen() {
+		n = 1
+	}
+	h.Buf.ReplaceHexBytes(off, n, []byte{c})
+}
+
+// gotoHexOffset moves the cursor of a hex view to the byte at the offset
+// given as argument, in decimal or with a 0x prefix in hexadecimal. A
+// negative offset counts from the end.
+func (h *BufPane) gotoHexOffset(args []string) {
+	if len(args) == 0 {
+		InfoBar.Error("Not enough arguments")
+		return
+	}
+	off, err := strconv.ParseInt(args[0], 0, 64)
+	if err != nil {
+		InfoBar.Error("Invalid offset: ", args[0])
+		return
+	}
+	if off < 0 {
+		off += int64(h.Buf.HexLen())
+	}
+	h.pushJump(h.Cursor.Loc)
+	h.RemoveAllMultiCursors()
+	h.gotoHexPos(buffer.HexPos{Offset: int(off)})
+}
+
+// findHex opens a prompt and searches forward for the bytes written in
+// hexadecimal
+func (h *BufPane) findHex() bool {
+	h.searchOrig = h.Cursor.Loc
+	InfoBar.Prompt("Find (hex): ", "", "Find", nil, func(resp string, canceled bool) {
+		if canceled {
+			return
+		}
+		if _, err := buffer.ParseHex(resp); err != nil {
+			InfoBar.Error(err)
+			return
+		}
+		h.Buf.LastSearch = resp
+		h.Buf.LastSearchRegex = false
+		// the matches of the text are not the matches of the bytes
+		h.Buf.HighlightSearch = false
+		h.searchHex(h.Buf.HexPosAt(h.searchOrig).Offset, true)
+	})
+	return true
+}
+
+// searchHex selects the next match of the last search of a hex view,
+// searching forwards or backwards from the given offset
+func (h *BufPane) searchHex(from int, down bool) {
+	pattern, err := buffer.ParseHex(h.Buf.LastSearch)
+	if err != nil {
+		InfoBar.Error("The last search is not a sequence of hex bytes")
+		return
+	}
+	off, found := h.Buf.FindHex(pattern, from, down)
+	if !found {
+		h.Cursor.ResetSelection()
+		InfoBar.Message("No matches found")
+		return
+	}
+	h.pushJump(h.Cursor.Loc)
+	start := h.Buf.HexLoc(buffer.HexPos{Offset: off})
+	end := h.Buf.HexLoc(buffer.HexPos{Offset: off + len(pattern) - 1, Low: true})
+	end.X++
+	h.GotoLoc(start)
+	h.Cursor.SetSelectionStart(start)
+	h.Cursor.SetSelectionEnd(end)
+	h.Cursor.OrigSelection[0] = h.Cursor.CurSelection[0]
+	h.Cursor.OrigSelection[1] = h.Cursor.CurSelection[1]
+}
+
+// findHexNext searches a hex view for the next or the previous match of the
+// last search, from the match selected by the previous search
+func (h *BufPane) findHexNext(down bool) {
+	from := h.Buf.HexPosAt(h.Cursor.Loc).Offset
+	if h.Cursor.HasSelection() {
+		from = h.Buf.HexPosAt(h.Cursor.CurSelection[0]).Offset
+		if down {
+			from++
+		}
+	}
+	h.searchHex(from, down)
+}
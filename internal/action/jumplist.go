@@ -0,0 +1,157 @@
+package action
+
+import (
+	"path/filepath"
+
+	"github.com/micro-editor/micro/v2/internal/buffer"
+)
+
+// maxJumps is the maximum number of locations kept in a jump list
+const maxJumps = 100
+
+// A jumpLoc is a location of the jump list of a pane. The buffer it is in
+// may have been replaced in the pane since, in which case it is reopened
+// from its path.
+type jumpLoc struct {
+	buf  *buffer.Buffer
+	path string
+	loc  buffer.Loc
+}
+
+// A jumpList holds the locations a pane jumped from, the oldest first.
+// idx is the position in the list of the location last moved to with
+// JumpBack or JumpForward, or the length of the list.
+type jumpList struct {
+	locs []jumpLoc
+	idx  int
+}
+
+// isCurrent returns true if the location is the cursor location of h
+func (l jumpLoc) isCurrent(h *BufPane) bool {
+	return l.inBuffer(h.Buf) && l.loc == h.Cursor.Loc
+}
+
+// inBuffer returns true if the location is in the given buffer
+func (l jumpLoc) inBuffer(b *buffer.Buffer) bool {
+	if l.path != "" {
+		return l.path == b.AbsPath
+	}
+	return l.buf == b
+}
+
+// pushJump adds the given location of the current buffer to the jump list,
+// before jumping away from it. The locations that JumpBack went back from
+// are dropped.
+func (h *BufPane) pushJump(loc buffer.Loc) {
+	j := &h.jumps
+	l := jumpLoc{h.Buf, h.Buf.AbsPath, loc}
+	j.locs = j.locs[:j.idx]
+	if n := len(j.locs); n == 0 || j.locs[n-1] != l {
+		j.locs = append(j.locs, l)
+		if len(j.locs) > maxJumps {
+			j.locs = j.locs[len(j.locs)-maxJumps:]
+		}
+	}
+	j.idx = len(j.locs)
+}
+
+// JumpBack moves the cursor back to where it was before the last jump,
+// e.g. a goto, a search or opening a file. Repeating it goes further back.
+func (h *BufPane) JumpBack() bool {
+	j := &h.jumps
+	if j.idx == len(j.locs) {
+		// remember where we come from for JumpForward
+		h.pushJump(h.Cursor.Loc)
+		j.idx = len(j.locs) - 1
+	}
+	if j.idx <= 0 {
+		InfoBar.Message("No older jump")
+		return false
+	}
+	j.idx--
+	return h.gotoJump(j.locs[j.idx])
+}
+
+// JumpForward goes forward in the jump list after JumpBack
+func (h *BufPane) JumpForward() bool {
+	j := &h.jumps
+	if j.idx >= len(j.locs)-1 {
+		InfoBar.Message("No newer jump")
+		return false
+	}
+	j.idx++
+	return h.gotoJump(j.locs[j.idx])
+}
+
+// gotoJump moves the cursor to a location of the jump list, opening its
+// buffer in the pane if needed
+func (h *BufPane) gotoJump(l jumpLoc) bool {
+	if l.isCurrent(h) {
+		return true
+	}
+	if !l.inBuffer(h.Buf) {
+		if l.path == "" {
+			InfoBar.Error("The buffer of this jump has been closed")
+			return false
+		}
+		h.openLoc(l.path, l.loc)
+		return true
+	}
+	h.moveToLoc(l.loc)
+	return true
+}
+
+// openLoc moves the cursor to the given location of the file at path,
+// opening the file in the pane first if it is not the current buffer
+func (h *BufPane) openLoc(path string, loc buffer.Loc) {
+	if abs, err := filepath.Abs(path); err == nil && abs == h.Buf.AbsPath {
+		h.moveToLoc(loc)
+		return
+	}
+	open := func() {
+		b, err := buffer.NewBufferFromFile(path, buffer.BTDefault)
+		if err != nil {
+			InfoBar.Error(err)
+			return
+		}
+		h.OpenBuffer(b)
+		h.moveToLoc(loc)
+	}
+	if h.Buf.Modified() && !h.Buf.Shared() {
+		h.closePrompt("Save", open)
+	} else {
+		open()
+	}
+}
+
+// moveToLoc removes the selection and the multiple cursors and moves the
+// cursor to the given location, clamped to the buffer
+func (h *BufPane) moveToLoc(loc buffer.Loc) {
+	h.RemoveAllMultiCursors()
+	h.Cursor.Deselect(true)
+	h.GotoLoc(loc.Clamp(h.Buf.Start(), h.Buf.End()))
+}
+
+// PrevChange moves the cursor to the location of the previous edit in the
+// change list of the buffer, starting from the most recent one
+func (h *BufPane) PrevChange() bool {
+	loc, ok := h.Buf.PrevChange()
+	if !ok {
+		InfoBar.Message("No older change")
+		return false
+	}
+	h.moveToLoc(loc)
+	return true
+}
+
+// NextChange moves the cursor to the location of the next edit in the
+// change list of the buffer
+func (h *BufPane) NextChange() bool {
+	loc, ok := h.Buf.NextChange()
+	if !ok {
+		InfoBar.Message("No newer change")
+		return false
+	}
+	h.moveToLoc(loc)
+	return true
+}
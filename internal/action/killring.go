@@ -0,0 +1,76 @@
+package action
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/micro-editor/micro/v2/internal/buffer"
+	"github.com/micro-editor/micro/v2/internal/clipboard"
+)
+
+// A pasteState is the text inserted by the last paste of a pane, which
+// PasteCycle can replace with another entry of the kill ring
+type pasteState struct {
+	start, end buffer.Loc
+	text       string
+	// ring is the index of the pasted text in the kill ring, or -1
+	ring int
+}
+
+// ringIndex returns the index of the given text in the kill ring, or -1
+func ringIndex(text string) int {
+	for i, t := range clipboard.Ring() {
+		if t == text {
+			return i
+		}
+	}
+	return -1
+}
+
+// PasteCycle replaces the text that was just pasted with the previous entry
+// of the kill ring, i.e. the text copied or cut before it. Repeating it goes
+// further back in the ring, and back to the most recent entry at the end.
+func (h *BufPane) PasteCycle() bool {
+	p := h.lastPaste
+	ring := clipboard.Ring()
+	if len(ring) == 0 || h.Buf.NumCursors() > 1 || p.text == "" || h.Cursor.HasSelection() ||
+		h.Cursor.Loc != p.end || string(h.Buf.Substr(p.start, p.end)) != p.text {
+		InfoBar.Message("Nothing to cycle, paste first")
+		return false
+	}
+
+	i := (p.ring + 1) % len(ring)
+	h.Buf.Replace(p.start, p.end, ring[i])
+	h.lastPaste = pasteState{p.start, h.Cursor.Loc, ring[i], i}
+	InfoBar.Message(fmt.Sprintf("Pasted kill ring entry %d of %d", i+1, len(ring)))
+	h.Relocate()
+	return true
+}
+
+// PasteFromRing opens a list of the kill ring entries. Enter pastes the
+// entry under the cursor.
+func (h *BufPane) PasteFromRing() bool {
+	ring := clipboard.Ring()
+	if len(ring) == 0 {
+		InfoBar.Message("The kill ring is empty")
+		return false
+	}
+
+	escape := strings.NewReplacer("\n", `\n`, "\t", `\t`, "\r", `\r`)
+	entries := make([]string, len(ring))
+	for i, t := range ring {
+		entries[i] = fmt.Sprintf("%d  %s", i+1, escape.Replace(t))
+	}
+	h.openPicker("Kill ring", entries, 0, func(h *BufPane, line int) {
+		if ring := clipboard.Ring(); line < len(ring) {
+			h.paste(ring[line])
+			h.Relocate()
+		}
+	})
+	return true
+}
+
+// KillRingCmd opens the list of the kill ring entries, like PasteFromRing
+func (h *BufPane) KillRingCmd(args []string) {
+	h.PasteFromRing()
+}
@@ -0,0 +1,104 @@
+package action
+
+import (
+	"strings"
+
+	"github.com/micro-editor/micro/v2/internal/buffer"
+	"github.com/micro-editor/micro/v2/internal/display"
+	"github.com/micro-editor/tcell/v2"
+)
+
+// gotoMessage moves the cursor to the next or previous message of the
+// buffer of at least the given severity
+func (h *BufPane) gotoMessage(kind buffer.MsgType, next bool) bool {
+	var m *buffer.Message
+	if next {
+		m = h.Buf.NextMessage(h.Cursor.Loc, kind)
+	} else {
+		m = h.Buf.PrevMessage(h.Cursor.Loc, kind)
+	}
+	if m == nil {
+		switch kind {
+		case buffer.MTError:
+			InfoBar.Message("No errors")
+		case buffer.MTWarning:
+			InfoBar.Message("No warnings or errors")
+		default:
+			InfoBar.Message("No messages")
+		}
+		return false
+	}
+
+	h.pushJump(h.Cursor.Loc)
+	h.moveToLoc(h.Buf.MessageLoc(m))
+	return true
+}
+
+// NextMessage moves the cursor to the next message of the buffer, like the
+// ones added by a linter, wrapping around the end of the buffer
+func (h *BufPane) NextMessage() bool {
+	return h.gotoMessage(buffer.MTInfo, true)
+}
+
+// PreviousMessage moves the cursor to the previous message of the buffer
+func (h *BufPane) PreviousMessage() bool {
+	return h.gotoMessage(buffer.MTInfo, false)
+}
+
+// NextWarning moves the cursor to the next message of the buffer which is a
+// warning or an error
+func (h *BufPane) NextWarning() bool {
+	return h.gotoMessage(buffer.MTWarning, true)
+}
+
+// PreviousWarning moves the cursor to the previous message of the buffer
+// which is a warning or an error
+func (h *BufPane) PreviousWarning() bool {
+	return h.gotoMessage(buffer.MTWarning, false)
+}
+
+// NextError moves the cursor to the next message of the buffer which is an
+// error
+func (h *BufPane) NextError() bool {
+	return h.gotoMessage(buffer.MTError, true)
+}
+
+// PreviousError moves the cursor to the previous message of the buffer which
+// is an error
+func (h *BufPane) PreviousError() bool {
+	return h.gotoMessage(buffer.MTError, false)
+}
+
+// ShowMessages shows the whole text of the messages on the line of the
+// cursor in a popup, until the next key or mouse event
+func (h *BufPane) ShowMessages() bool {
+	msgs := h.Buf.MessagesAt(h.Cursor.Y)
+	if len(msgs) == 0 {
+		InfoBar.Message("No messages on this line")
+		return false
+	}
+
+	var lines []string
+	var styles []tcell.Style
+	tabs := strings.NewReplacer("\t", "    ")
+	for _, m := range msgs {
+		style := m.Style().Reverse(true)
+		for _, l := range strings.Split(strings.TrimRight(m.Msg, "\n"), "\n") {
+			lines = append(lines, tabs.Replace(l))
+			styles = append(styles, style)
+		}
+	}
+	w, ok := h.BWindow.(*display.BufWindow)
+	if !ok {
+		return false
+	}
+	w.ShowPopup(h.Cursor.Loc, lines, styles)
+	return true
+}
+
+// hidePopup hides the popup shown by ShowMessages, if any
+func (h *BufPane) hidePopup() {
+	if w, ok := h.BWindow.(*display.BufWindow); ok {
+		w.HidePopup()
+	}
+}
@@ -0,0 +1,107 @@
+package action
+
+import (
+	"strings"
+
+	"github.com/micro-editor/micro/v2/internal/buffer"
+	"github.com/micro-editor/tcell/v2"
+)
+
+// A picker is attached to a read-only pane listing entries to choose from.
+// Enter closes the list and chooses the entry under the cursor, Escape
+// just closes the list.
+type picker struct {
+	source *BufPane
+	choose func(h *BufPane, line int)
+	// keep is set if the list stays open when an entry is chosen
+	keep bool
+	// onClose is called when the list is closed, if it is not nil
+	onClose func()
+	// toggle is called with the list pane when space is pressed, if it is
+	// not nil
+	toggle func(h *BufPane)
+	// preview is called like choose when the cursor of the list moves, if
+	// it is not nil
+	preview func(h *BufPane, line int)
+}
+
+// openPicker opens a read-only vertical split with the given name listing
+// the given entries, one per line, with the cursor on the line cur. The
+// function choose is called with the pane the list was opened from and the
+// line of the chosen entry.
+func (h *BufPane) openPicker(name string, entries []string, cur int, choose func(h *BufPane, line int)) *BufPane {
+	list := buffer.NewBufferFromString(strings.Join(entries, "\n"), "", buffer.BTScratch)
+	list.Type.Readonly = true
+	list.SetName(name)
+
+	p := h.VSplitBuf(list)
+	p.picker = &picker{source: h, choose: choose}
+	p.GotoLoc(buffer.Loc{X: 0, Y: cur})
+	return p
+}
+
+// editList runs f, which edits the read-only buffer of a list
+func editList(list *buffer.Buffer, f func()) {
+	list.Type.Readonly = false
+	f()
+	list.Type.Readonly = true
+}
+
+// handleKey handles the keys with a special meaning in the list pane h. It
+// returns false if the key should be handled as usual.
+func (p *picker) handleKey(h *BufPane, e *tcell.EventKey) bool {
+	switch e.Key() {
+	case tcell.KeyEnter:
+		line := h.Cursor.Y
+		if p.keep {
+			if p.activateSource() {
+				p.choose(p.source, line)
+			}
+		} else if p.close(h) {
+			p.choose(p.source, line)
+		}
+		return true
+	case tcell.KeyEscape:
+		p.close(h)
+		return true
+	case tcell.KeyRune:
+		if e.Rune() == ' ' && p.toggle != nil {
+			p.toggle(h)
+			return true
+		}
+	}
+	return false
+}
+
+// close closes the list pane h and goes back to the pane the list was
+// opened from. It returns false if that pane has been closed meanwhile.
+func (p *picker) close(h *BufPane) bool {
+	h.ForceQuit()
+	return p.activateSource()
+}
+
+// closed is called when the list pane is closed
+func (p *picker) closed(h *BufPane) {
+	h.picker = nil
+	if p.onClose != nil {
+		p.onClose()
+	}
+}
+
+// sourceIndex returns the index of the pane the list was opened from in its
+// tab. It returns false if that pane has been closed.
+func (p *picker) sourceIndex() (int, bool) {
+	t := p.source.tab
+	i := t.GetPane(p.source.splitID)
+	return i, i < len(t.Panes) && t.Panes[i] == p.source
+}
+
+// activateSource makes the pane the list was opened from active. It returns
+// false if that pane has been closed.
+func (p *picker) activateSource() bool {
+	i, ok := p.sourceIndex()
+	if ok {
+		p.source.tab.SetActive(i)
+	}
+	return ok
+}
@@ -0,0 +1,201 @@
+package action
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/micro-editor/micro/v2/internal/buffer"
+	"github.com/micro-editor/micro/v2/internal/config"
+	"github.com/micro-editor/micro/v2/internal/shell"
+	"github.com/micro-editor/micro/v2/internal/util"
+)
+
+// quickfix is the list of locations stepped through with cnext and cprev.
+// idx is the index of the current entry, or -1 before the first one, and
+// pane is the pane listing the entries if it is open.
+var quickfix struct {
+	entries []buffer.QuickfixEntry
+	idx     int
+	pane    *BufPane
+}
+
+// SetQuickfix replaces the entries of the quickfix list
+func SetQuickfix(entries []buffer.QuickfixEntry) {
+	quickfix.entries = entries
+	quickfix.idx = -1
+	updateQuickfixPane()
+}
+
+// AddQuickfix adds an entry to the quickfix list, with a line and a column
+// starting from 1. It is meant for plugins.
+func AddQuickfix(path string, line, col int, msg string, kind buffer.MsgType) {
+	if len(quickfix.entries) == 0 {
+		quickfix.idx = -1
+	}
+	quickfix.entries = append(quickfix.entries, buffer.QuickfixEntry{
+		Path: path,
+		Loc:  buffer.Loc{X: col - 1, Y: line - 1},
+		Msg:  msg,
+		Kind: kind,
+	})
+	updateQuickfixPane()
+}
+
+// ClearQuickfix removes all the entries of the quickfix list
+func ClearQuickfix() {
+	SetQuickfix(nil)
+}
+
+// SetQuickfixFromOutput replaces the entries of the quickfix list with the
+// ones described by the output of a command according to the errorformat
+// option, or to the given errorformat if it is not empty
+func SetQuickfixFromOutput(output, errorformat string) error {
+	if errorformat == "" {
+		errorformat = config.GetGlobalOption("errorformat").(string)
+	}
+	entries, err := buffer.ParseErrorformat(errorformat, output)
+	if err != nil {
+		return err
+	}
+	SetQuickfix(entries)
+	return nil
+}
+
+// updateQuickfixPane updates the list pane after a change of the list
+func updateQuickfixPane() {
+	p := quickfix.pane
+	if p == nil {
+		return
+	}
+	editList(p.Buf, func() {
+		p.Buf.Replace(p.Buf.Start(), p.Buf.End(), strings.Join(quickfixLines(), "\n"))
+	})
+	p.moveToLoc(buffer.Loc{X: 0, Y: quickfix.idx})
+}
+
+// quickfixLines returns the lines of the list pane
+func quickfixLines() []string {
+	lines := make([]string, len(quickfix.entries))
+	for i, e := range quickfix.entries {
+		lines[i] = e.String()
+	}
+	return lines
+}
+
+// COpenCmd opens a pane listing the entries of the quickfix list. Pressing
+// enter on an entry jumps to it.
+func (h *BufPane) COpenCmd(args []string) {
+	if p := quickfix.pane; p != nil {
+		for i, t := range Tabs.List {
+			if t != p.tab {
+				continue
+			}
+			if j := t.GetPane(p.splitID); j < len(t.Panes) && t.Panes[j] == p {
+				Tabs.SetActive(i)
+				t.SetActive(j)
+				return
+			}
+		}
+		// the tab of the list has been closed
+		p.picker.closed(p)
+	}
+	if len(quickfix.entries) == 0 {
+		InfoBar.Message("The quickfix list is empty")
+		return
+	}
+
+	p := h.openPicker("Quickfix", quickfixLines(), util.Max(quickfix.idx, 0), func(h *BufPane, line int) {
+		h.gotoQuickfix(line)
+	})
+	p.picker.keep = true
+	p.picker.onClose = func() {
+		quickfix.pane = nil
+	}
+	quickfix.pane = p
+}
+
+// CCloseCmd closes the pane listing the quickfix list
+func (h *BufPane) CCloseCmd(args []string) {
+	if p := quickfix.pane; p != nil {
+		p.picker.close(p)
+	}
+}
+
+// CNextCmd jumps to the next entry of the quickfix list
+func (h *BufPane) CNextCmd(args []string) {
+	if quickfix.idx+1 >= len(quickfix.entries) {
+		InfoBar.Message("No more quickfix entries")
+		return
+	}
+	h.quickfixTarget().gotoQuickfix(quickfix.idx + 1)
+}
+
+// CPrevCmd jumps to the previous entry of the quickfix list
+func (h *BufPane) CPrevCmd(args []string) {
+	if quickfix.idx <= 0 {
+		InfoBar.Message("No previous quickfix entries")
+		return
+	}
+	h.quickfixTarget().gotoQuickfix(quickfix.idx - 1)
+}
+
+// CMessagesCmd fills the quickfix list with the messages of the open
+// buffers, like the ones added by the linter plugin
+func (h *BufPane) CMessagesCmd(args []string) {
+	SetQuickfix(buffer.MessagesQuickfix())
+	InfoBar.Message(fmt.Sprintf("%d quickfix entries", len(quickfix.entries)))
+}
+
+// CExecCmd runs a command in the background and fills the quickfix list
+// with the locations found in its output according to the errorformat
+// option
+func (h *BufPane) CExecCmd(args []string) {
+	if len(args) == 0 {
+		InfoBar.Error("Usage: cexec command [args...]")
+		return
+	}
+
+	InfoBar.Message("Running " + strings.Join(args, " ") + "...")
+	go func() {
+		// a command reporting errors usually exits with a failure status, so
+		// its error only matters if nothing is found in its output
+		output, runErr := shell.ExecCommand(args[0], args[1:]...)
+		shell.Jobs <- shell.JobFunction{
+			Function: func(output string, _ []any) {
+				if err := SetQuickfixFromOutput(output, ""); err != nil {
+					InfoBar.Error(err)
+				} else if len(quickfix.entries) > 0 {
+					InfoBar.Message(fmt.Sprintf("%d quickfix entries", len(quickfix.entries)))
+				} else if runErr != nil {
+					InfoBar.Error(runErr)
+				} else {
+					InfoBar.Message("No quickfix entries")
+				}
+			},
+			Output: output,
+		}
+	}()
+}
+
+// quickfixTarget returns the pane where the entries of the quickfix list
+// are opened when h is active, i.e. h itself unless it is the list pane
+func (h *BufPane) quickfixTarget() *BufPane {
+	if h == quickfix.pane && h.picker.activateSource() {
+		return h.picker.source
+	}
+	return h
+}
+
+// gotoQuickfix makes the entry i of the quickfix list current and moves
+// the cursor to it, opening its file if needed
+func (h *BufPane) gotoQuickfix(i int) {
+	quickfix.idx = i
+	e := quickfix.entries[i]
+	if p := quickfix.pane; p != nil {
+		p.moveToLoc(buffer.Loc{X: 0, Y: i})
+	}
+
+	h.pushJump(h.Cursor.Loc)
+	h.openLoc(e.Path, e.Loc)
+	InfoBar.Message(fmt.Sprintf("(%d of %d) %s", i+1, len(quickfix.entries), e.Msg))
+}
@@ -0,0 +1,69 @@
+package action
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/micro-editor/micro/v2/internal/buffer"
+	"github.com/micro-editor/micro/v2/internal/clipboard"
+	"github.com/micro-editor/tcell/v2"
+)
+
+// SelectRegister makes the next key choose the register used by the next
+// copy, cut or paste action instead of the clipboard
+func (h *BufPane) SelectRegister() bool {
+	h.awaitRegister = true
+	InfoBar.Message("Register: a-z, 1-9, + or *")
+	return true
+}
+
+// chooseRegister handles the key pressed after SelectRegister
+func (h *BufPane) chooseRegister(e *tcell.EventKey) {
+	h.awaitRegister = false
+	if e.Key() != tcell.KeyRune {
+		InfoBar.Message("")
+		return
+	}
+
+	r, ok := clipboard.NamedRegister(e.Rune())
+	if !ok {
+		InfoBar.Error("Invalid register ", string(e.Rune()))
+		return
+	}
+	h.register = r
+	InfoBar.Message(fmt.Sprintf("Using register %q", r.Name()))
+}
+
+// clipRegister returns the register used by the clipboard actions
+func (h *BufPane) clipRegister() clipboard.Register {
+	if h.register != 0 {
+		return h.register
+	}
+	return clipboard.ClipboardReg
+}
+
+// RegistersCmd opens a read-only split showing the contents of the named
+// and history registers
+func (h *BufPane) RegistersCmd(args []string) {
+	regs := clipboard.Registers()
+	if len(regs) == 0 {
+		InfoBar.Message("All registers are empty")
+		return
+	}
+
+	escape := strings.NewReplacer("\n", `\n`, "\t", `\t`, "\r", `\r`)
+	var b strings.Builder
+	for _, r := range regs {
+		clip, _ := clipboard.Read(r)
+		fmt.Fprintf(&b, "\"%c  %s", r.Name(), escape.Replace(clip))
+		if clipboard.IsBlock(r, clip) {
+			b.WriteString("  (block)")
+		}
+		b.WriteByte('\n')
+	}
+
+	list := buffer.NewBufferFromString(strings.TrimSuffix(b.String(), "\n"), "", buffer.BTScratch)
+	list.Type.Readonly = true
+	list.SetName("Registers")
+	h.HSplitBuf(list)
+}
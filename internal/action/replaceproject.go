@@ -0,0 +1,300 @@
+package action
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/micro-editor/micro/v2/internal/buffer"
+	"github.com/micro-editor/micro/v2/internal/config"
+	"github.com/micro-editor/micro/v2/internal/screen"
+	"github.com/micro-editor/micro/v2/internal/shell"
+	"github.com/micro-editor/micro/v2/internal/util"
+)
+
+// replaceHeaderLines is the number of lines before the changes in the
+// preview of a project replacement
+const replaceHeaderLines = 2
+
+// A replaceHunk is a change proposed by replaceproject
+type replaceHunk struct {
+	buffer.GrepMatch
+	include bool
+	// line is the line of the hunk in the preview
+	line int
+}
+
+// A replaceFile holds the changes proposed in a file
+type replaceFile struct {
+	path  string
+	hunks []*replaceHunk
+}
+
+// A projectReplace is the state of the preview of a project replacement.
+// The line i of the preview shows the file or the hunk refs[i], or nothing
+// if both are nil.
+type projectReplace struct {
+	files []*replaceFile
+	refs  []replaceRef
+	// skipped are the files with unsaved changes in open buffers, which
+	// are not changed
+	skipped []string
+}
+
+type replaceRef struct {
+	file *replaceFile
+	hunk *replaceHunk
+}
+
+// ReplaceProjectCmd replaces a search in the files under the given paths, or
+// under the current directory. The changes are first listed in a preview
+// where they can be included or excluded, and the selected changes are
+// applied as undoable edits in the buffers of the files.
+func (h *BufPane) ReplaceProjectCmd(args []string) {
+	literal := false
+	var search, replace string
+	var paths []string
+	n := 0
+	for _, arg := range args {
+		switch {
+		case arg == "-l" && n == 0:
+			literal = true
+		case n == 0:
+			search = arg
+			n++
+		case n == 1:
+			replace = arg
+			n++
+		default:
+			paths = append(paths, arg)
+		}
+	}
+	if n < 2 {
+		InfoBar.Error("Usage: replaceproject [-l] search value [paths...]")
+		return
+	}
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	r, err := buffer.CompileSearch(search, !literal, h.Buf.Settings["ignorecase"].(bool))
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+
+	rp := &projectReplace{refs: make([]replaceRef, replaceHeaderLines)}
+	title := fmt.Sprintf("Replace %s with %s: Space toggles a change or a file, Enter applies the selected changes, Escape cancels", search, replace)
+	status := "Searching..."
+	p := h.openPicker("Replace", []string{title, status}, replaceHeaderLines-1, func(h *BufPane, line int) {
+		rp.apply()
+	})
+	list := p.Buf
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.picker.onClose = cancel
+	p.picker.toggle = func(l *BufPane) {
+		rp.toggle(list, l.Cursor.Y)
+	}
+
+	go func() {
+		err := buffer.GrepReplace(ctx, r, []byte(replace), !literal, paths, func(path string, found []buffer.GrepMatch) {
+			shell.Jobs <- shell.JobFunction{
+				Function: func(string, []any) {
+					// the job may run after the preview has been closed
+					if ctx.Err() != nil {
+						return
+					}
+					rp.addFile(list, path, found)
+				},
+			}
+		})
+
+		shell.Jobs <- shell.JobFunction{
+			Function: func(string, []any) {
+				if ctx.Err() != nil {
+					return
+				}
+				done := fmt.Sprintf("%d matches in %d files", rp.numHunks(), len(rp.files))
+				if len(rp.skipped) > 0 {
+					done += fmt.Sprintf(", skipped because of unsaved changes: %s", strings.Join(rp.skipped, ", "))
+				}
+				if err != nil {
+					done = "Search failed: " + err.Error()
+				}
+				editList(list, func() {
+					list.Replace(buffer.Loc{X: 0, Y: 1}, buffer.Loc{X: util.CharacterCountInString(status), Y: 1}, done)
+				})
+			},
+		}
+	}()
+}
+
+// addFile adds the changes proposed in a file to the preview, unless the
+// file has unsaved changes
+func (rp *projectReplace) addFile(list *buffer.Buffer, path string, matches []buffer.GrepMatch) {
+	if b := findOpenBuffer(path); b != nil && b.Modified() {
+		rp.skipped = append(rp.skipped, path)
+		return
+	}
+
+	f := &replaceFile{path: path}
+	rp.files = append(rp.files, f)
+	rp.refs = append(rp.refs, replaceRef{file: f})
+
+	var text strings.Builder
+	text.WriteString("\n" + path)
+	for _, m := range matches {
+		hunk := &replaceHunk{GrepMatch: m, include: true, line: len(rp.refs)}
+		f.hunks = append(f.hunks, hunk)
+		rp.refs = append(rp.refs, replaceRef{file: f, hunk: hunk})
+		text.WriteString("\n" + hunk.String())
+	}
+	editList(list, func() { list.Insert(list.End(), text.String()) })
+}
+
+// String returns the line of the hunk in the preview, showing the removed
+// and the added text inline
+func (hunk *replaceHunk) String() string {
+	escape := strings.NewReplacer("\r", `\r`, "\n", `\n`)
+	mark := "[x]"
+	if !hunk.include {
+		mark = "[ ]"
+	}
+	after := ""
+	if hunk.End.Y == hunk.Loc.Y {
+		after = util.SliceEndStr(hunk.Line, hunk.End.X)
+	}
+	return fmt.Sprintf("  %s %d:%d: %s[-%s-]{+%s+}%s", mark, hunk.Loc.Y+1, hunk.Loc.X+1,
+		util.SliceStartStr(hunk.Line, hunk.Loc.X), escape.Replace(hunk.Text), escape.Replace(hunk.Replacement), after)
+}
+
+// numHunks returns the number of changes in the preview
+func (rp *projectReplace) numHunks() int {
+	n := 0
+	for _, f := range rp.files {
+		n += len(f.hunks)
+	}
+	return n
+}
+
+// toggle includes or excludes the change on the given line of the preview.
+// On the line of a file, all the changes of the file are excluded, or
+// included if they are all excluded already.
+func (rp *projectReplace) toggle(list *buffer.Buffer, line int) {
+	if line >= len(rp.refs) || rp.refs[line].file == nil {
+		return
+	}
+	ref := rp.refs[line]
+
+	var hunks []*replaceHunk
+	include := true
+	if ref.hunk != nil {
+		hunks = []*replaceHunk{ref.hunk}
+		include = !ref.hunk.include
+	} else {
+		hunks = ref.file.hunks
+		for _, hunk := range hunks {
+			if hunk.include {
+				include = false
+				break
+			}
+		}
+	}
+
+	editList(list, func() {
+		for _, hunk := range hunks {
+			hunk.include = include
+			// replaces the mark in "  [x] "
+			list.Replace(buffer.Loc{X: 2, Y: hunk.line}, buffer.Loc{X: 5, Y: hunk.line}, hunk.String()[2:5])
+		}
+	})
+}
+
+// apply makes the selected changes in the buffers of their files, opening
+// the files which are not open in new tabs
+func (rp *projectReplace) apply() {
+	changed, files := 0, 0
+	var errs []string
+	for _, f := range rp.files {
+		var hunks []*replaceHunk
+		for _, hunk := range f.hunks {
+			if hunk.include {
+				hunks = append(hunks, hunk)
+			}
+		}
+		if len(hunks) == 0 {
+			continue
+		}
+
+		b := findOpenBuffer(f.path)
+		opened := false
+		if b == nil {
+			var err error
+			b, err = buffer.NewBufferFromFile(f.path, buffer.BTDefault)
+			if err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			opened = true
+		} else if b.Modified() {
+			errs = append(errs, f.path+" has unsaved changes")
+			continue
+		}
+
+		// the file may have changed since the search
+		deltas := make([]buffer.Delta, 0, len(hunks))
+		for i := len(hunks) - 1; i >= 0; i-- {
+			hunk := hunks[i]
+			text := strings.ReplaceAll(hunk.Text, "\r\n", "\n")
+			if !validLoc(b, hunk.Loc) || !validLoc(b, hunk.End) || string(b.Substr(hunk.Loc, hunk.End)) != text {
+				deltas = nil
+				break
+			}
+			replacement := strings.ReplaceAll(hunk.Replacement, "\r\n", "\n")
+			deltas = append(deltas, buffer.Delta{Text: []byte(replacement), Start: hunk.Loc, End: hunk.End})
+		}
+		if deltas == nil {
+			errs = append(errs, f.path+" has changed since the search")
+			if opened {
+				b.Close()
+			}
+			continue
+		}
+
+		if opened {
+			width, height := screen.Screen.Size()
+			iOffset := config.GetInfoBarOffset()
+			Tabs.AddTab(NewTabFromBuffer(0, 0, width, height-1-iOffset, b))
+		}
+		b.MultipleReplace(deltas)
+		changed += len(deltas)
+		files++
+	}
+
+	if len(errs) > 0 {
+		InfoBar.Error(fmt.Sprintf("Replaced %d occurrences in %d files, skipped: %s", changed, files, strings.Join(errs, ", ")))
+	} else {
+		InfoBar.Message(fmt.Sprintf("Replaced %d occurrences in %d files", changed, files))
+	}
+}
+
+// findOpenBuffer returns an open buffer of the file at path, or nil
+func findOpenBuffer(path string) *buffer.Buffer {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil
+	}
+	for _, b := range buffer.OpenBuffers {
+		if b.AbsPath == abs {
+			return b
+		}
+	}
+	return nil
+}
+
+// validLoc returns true if the location is in the buffer
+func validLoc(b *buffer.Buffer, l buffer.Loc) bool {
+	return l.Y >= 0 && l.Y < b.LinesNum() && l.X >= 0 && l.X <= util.CharacterCount(b.LineBytes(l.Y))
+}
@@ -0,0 +1,297 @@
+package action
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/micro-editor/micro/v2/internal/buffer"
+	"github.com/micro-editor/micro/v2/internal/config"
+	"github.com/micro-editor/micro/v2/internal/display"
+	"github.com/micro-editor/micro/v2/internal/screen"
+	"github.com/micro-editor/micro/v2/internal/util"
+	"github.com/micro-editor/micro/v2/internal/views"
+)
+
+// A session is the layout of the tabs and splits of the editor, with the
+// file and the position in it of every pane
+type session struct {
+	Tabs      []sessionTab
+	ActiveTab int
+}
+
+type sessionTab struct {
+	Root sessionNode
+	// Active is the index of the active pane in the order of the leaves
+	// of the split tree
+	Active int
+}
+
+// A sessionNode is a node of the split tree of a tab. Kind and Props are
+// set for the nodes which are split, where Props are the proportions of
+// the size of the node taken by its children, and the other fields for the
+// leaves.
+type sessionNode struct {
+	Kind     views.SplitType `json:",omitempty"`
+	Props    []float64       `json:",omitempty"`
+	Children []sessionNode   `json:",omitempty"`
+
+	// Path is empty for the panes which don't show a file
+	Path      string `json:",omitempty"`
+	Cursor    buffer.Loc
+	StartLine display.SLoc
+	StartCol  int
+}
+
+// sessionPath returns the file of the session with the given name
+func sessionPath(name string) string {
+	return filepath.Join(config.ConfigDir, "sessions", name+".json")
+}
+
+// autoSessionPath returns the file of the session of the current directory
+// saved and restored when the autosession option is on
+func autoSessionPath() (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(config.ConfigDir, "sessions", "auto", util.EscapePathUrl(wd)+".json"), nil
+}
+
+// SaveSession saves the current layout to the session with the given name
+func SaveSession(name string) error {
+	return saveSession(sessionPath(name))
+}
+
+// LoadSession restores the session with the given name. Its tabs replace
+// the current ones, unless keep is set in which case the current tabs are
+// kept after them.
+func LoadSession(name string, keep bool) error {
+	return loadSession(sessionPath(name), keep)
+}
+
+// LoadAutoSession restores the session of the current directory saved at
+// exit when the autosession option is on, if any
+func LoadAutoSession() error {
+	path, err := autoSessionPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return loadSession(path, false)
+}
+
+// saveAutoSession saves the session of the current directory before
+// exiting, if the autosession option is on
+func saveAutoSession() {
+	if !config.GetGlobalOption("autosession").(bool) {
+		return
+	}
+	path, err := autoSessionPath()
+	if err == nil {
+		err = saveSession(path)
+	}
+	if err != nil {
+		screen.TermMessage("Error saving the session: ", err)
+	}
+}
+
+func saveSession(path string) error {
+	s := session{ActiveTab: Tabs.Active()}
+	for _, t := range Tabs.List {
+		st := sessionTab{}
+		leaves := 0
+		st.Root = t.saveNode(t.Node, &leaves, &st.Active)
+		s.Tabs = append(s.Tabs, st)
+	}
+
+	data, err := json.MarshalIndent(s, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	return util.SafeWrite(path, data, false)
+}
+
+// saveNode returns the session node of the given split node of the tab.
+// leaves is the number of leaves before the node, and active is set to it
+// if the node is the active pane.
+func (t *Tab) saveNode(n *views.Node, leaves, active *int) sessionNode {
+	if !n.IsLeaf() {
+		sn := sessionNode{Kind: n.Kind, Props: n.Proportions()}
+		for _, c := range n.Children() {
+			sn.Children = append(sn.Children, t.saveNode(c, leaves, active))
+		}
+		return sn
+	}
+
+	sn := sessionNode{}
+	i := t.GetPane(n.ID())
+	if i == t.active {
+		*active = *leaves
+	}
+	*leaves++
+	if h, ok := t.Panes[i].(*BufPane); ok {
+		if h.Buf.Type == buffer.BTDefault && h.Buf.Path != "" {
+			sn.Path = h.Buf.AbsPath
+		}
+		v := h.GetView()
+		sn.Cursor = h.Cursor.Loc
+		sn.StartLine = v.StartLine
+		sn.StartCol = v.StartCol
+	}
+	return sn
+}
+
+func loadSession(path string, keep bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return errors.New("No session " + path)
+		}
+		return err
+	}
+	var s session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if len(s.Tabs) == 0 {
+		return errors.New("The session " + path + " has no tabs")
+	}
+
+	if !keep {
+		for _, t := range Tabs.List {
+			for _, p := range t.Panes {
+				p.Close()
+			}
+		}
+		Tabs.List = Tabs.List[:0]
+	}
+
+	w, h := screen.Screen.Size()
+	iOffset := config.GetInfoBarOffset()
+	tabs := make([]*Tab, 0, len(s.Tabs))
+	for _, st := range s.Tabs {
+		t := NewTabFromBuffer(0, 0, w, h-iOffset, openSessionBuffer(firstLeaf(st.Root)))
+		var panes []*BufPane
+		t.Panes[0].(*BufPane).restoreNode(st.Root, &panes)
+		t.restoreSizes(t.Node, st.Root)
+
+		active := util.Clamp(st.Active, 0, len(panes)-1)
+		t.SetActive(t.GetPane(panes[active].ID()))
+		tabs = append(tabs, t)
+	}
+	Tabs.List = append(tabs, Tabs.List...)
+
+	Tabs.Resize()
+	Tabs.UpdateNames()
+	Tabs.SetActive(util.Clamp(s.ActiveTab, 0, len(tabs)-1))
+	for _, t := range tabs {
+		for _, p := range t.Panes {
+			if h, ok := p.(*BufPane); ok {
+				h.Relocate()
+			}
+		}
+	}
+	return nil
+}
+
+// firstLeaf returns the first leaf under a session node
+func firstLeaf(sn sessionNode) sessionNode {
+	for len(sn.Children) > 0 {
+		sn = sn.Children[0]
+	}
+	return sn
+}
+
+// openSessionBuffer opens the buffer of a leaf of a session
+func openSessionBuffer(sn sessionNode) *buffer.Buffer {
+	if sn.Path != "" {
+		b, err := buffer.NewBufferFromFile(sn.Path, buffer.BTDefault)
+		if err == nil {
+			return b
+		}
+		InfoBar.Error(err)
+	}
+	return buffer.NewBufferFromString("", "", buffer.BTDefault)
+}
+
+// restoreNode splits the pane h, which shows the first leaf of the session
+// node sn, to rebuild the splits of sn. The panes of the leaves are added
+// to panes in order.
+func (h *BufPane) restoreNode(sn sessionNode, panes *[]*BufPane) {
+	if len(sn.Children) == 0 {
+		h.Cursor.GotoLoc(sn.Cursor.Clamp(h.Buf.Start(), h.Buf.End()))
+		v := h.GetView()
+		v.StartLine = sn.StartLine
+		v.StartCol = sn.StartCol
+		h.SetView(v)
+		*panes = append(*panes, h)
+		return
+	}
+
+	// the panes of the children are all created before splitting them
+	children := []*BufPane{h}
+	for _, c := range sn.Children[1:] {
+		last := children[len(children)-1]
+		b := openSessionBuffer(firstLeaf(c))
+		if sn.Kind == views.STVert {
+			children = append(children, last.HSplitIndex(b, true))
+		} else {
+			children = append(children, last.VSplitIndex(b, true))
+		}
+	}
+	for i, c := range sn.Children {
+		children[i].restoreNode(c, panes)
+	}
+}
+
+// restoreSizes sets the sizes of the splits under the node n from the
+// proportions saved in the session node sn
+func (t *Tab) restoreSizes(n *views.Node, sn sessionNode) {
+	children := n.Children()
+	if len(children) != len(sn.Children) {
+		return
+	}
+	n.SetProportions(sn.Props)
+	for i, c := range children {
+		t.restoreSizes(c, sn.Children[i])
+	}
+}
+
+// SessionCmd saves or restores the layout of the tabs and splits, with the
+// file and the position of every pane: "session save name" or "session
+// load name"
+func (h *BufPane) SessionCmd(args []string) {
+	if len(args) != 2 || args[0] != "save" && args[0] != "load" {
+		InfoBar.Error("Usage: session save|load name")
+		return
+	}
+	name := args[1]
+
+	if args[0] == "save" {
+		if err := SaveSession(name); err != nil {
+			InfoBar.Error(err)
+			return
+		}
+		InfoBar.Message("Saved session " + name)
+		return
+	}
+
+	for _, b := range buffer.OpenBuffers {
+		if b.Modified() {
+			InfoBar.Error("Save or discard the changes of ", b.GetName(), " before loading a session")
+			return
+		}
+	}
+	if err := LoadSession(name, false); err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	InfoBar.Message("Loaded session " + name)
+}
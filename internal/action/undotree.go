@@ -0,0 +1,98 @@
+package action
+
+import (
+	"fmt"
+	"strings"
+
+	humanize "github.com/dustin/go-humanize"
+	"github.com/micro-editor/micro/v2/internal/buffer"
+)
+
+// undoPreviewLen is the maximum number of characters of the changed text
+// shown for each state in the undo tree pane
+const undoPreviewLen = 40
+
+// UndoTreeCmd opens a read-only split listing every state of the undo tree
+// of the current buffer. Moving the cursor in the list previews the state
+// under it, Enter restores it and closing the list in any other way goes
+// back to the original state.
+func (h *BufPane) UndoTreeCmd(args []string) {
+	tree := h.Buf.UndoTree
+	orig := tree.Cur
+
+	// states[i] is the sequence number of the state listed on line i
+	var states []int
+	var entries []string
+	line := 0
+	for seq := tree.Len() - 1; seq >= 0; seq-- {
+		mark := " "
+		if seq == orig {
+			mark = ">"
+			line = len(states)
+		}
+		entries = append(entries, fmt.Sprintf("%s %5d  %-16s %s", mark, seq, humanize.Time(tree.Node(seq).Time), undoStateSummary(tree, seq)))
+		states = append(states, seq)
+	}
+
+	gotoState := func(h *BufPane, seq int) {
+		if seq != h.Buf.UndoTree.Cur {
+			h.Buf.GotoState(seq)
+			h.Relocate()
+		}
+	}
+	p := h.openPicker("Undo tree", entries, line, func(h *BufPane, line int) {
+		gotoState(h, states[line])
+		InfoBar.Message(fmt.Sprintf("Restored undo state %d", states[line]))
+	})
+	p.picker.preview = func(h *BufPane, line int) {
+		if line >= 0 && line < len(states) {
+			gotoState(h, states[line])
+		}
+	}
+	// closing the list goes back to the original state, and an entry is
+	// chosen after that
+	pk := p.picker
+	pk.onClose = func() {
+		if _, ok := pk.sourceIndex(); ok {
+			gotoState(h, orig)
+		}
+	}
+}
+
+// undoStateSummary describes the change leading to the given state: its
+// kind, a short preview of the changed text, and the state it branches off
+// if that is not the previous one
+func undoStateSummary(tree *buffer.UndoTree, seq int) string {
+	n := tree.Node(seq)
+	if n.Event == nil {
+		return "original"
+	}
+
+	var kind, sign string
+	switch tree.EventType(seq) {
+	case buffer.TextEventInsert:
+		kind, sign = "insert", "+"
+	case buffer.TextEventRemove:
+		kind, sign = "delete", "-"
+	default:
+		kind, sign = "replace", "~"
+	}
+
+	var text []byte
+	if len(n.Event.Deltas) > 0 {
+		text = n.Event.Deltas[0].Text
+	}
+	preview := strings.NewReplacer("\n", `\n`, "\t", `\t`, "\r", `\r`).Replace(string(text))
+	if r := []rune(preview); len(r) > undoPreviewLen {
+		preview = string(r[:undoPreviewLen]) + "..."
+	}
+
+	s := fmt.Sprintf("%-7s %s%s", kind, sign, preview)
+	if len(n.Event.Deltas) > 1 {
+		s += fmt.Sprintf(" (and %d more)", len(n.Event.Deltas)-1)
+	}
+	if n.Parent != seq-1 {
+		s += fmt.Sprintf(" [branch from %d]", n.Parent)
+	}
+	return s
+}
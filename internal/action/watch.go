@@ -0,0 +1,127 @@
+package action
+
+import (
+	"fmt"
+
+	luar "layeh.com/gopher-luar"
+
+	"github.com/micro-editor/micro/v2/internal/buffer"
+	"github.com/micro-editor/micro/v2/internal/config"
+	ulua "github.com/micro-editor/micro/v2/internal/lua"
+	"github.com/micro-editor/micro/v2/internal/screen"
+	"github.com/micro-editor/micro/v2/internal/watcher"
+)
+
+// HandleFileEvent updates the buffers of a file which has changed or has
+// been removed on disk, as reported by the file watcher, and runs the
+// onFileChanged callback of the plugins. The diff base of the buffers of
+// the files of a git repository is updated when its index changes.
+func HandleFileEvent(e watcher.Event) {
+	seen := make(map[*buffer.SharedBuffer]bool)
+	for _, t := range Tabs.List {
+		for _, p := range t.Panes {
+			h, ok := p.(*BufPane)
+			if !ok || seen[h.Buf.SharedBuffer] {
+				continue
+			}
+			if h.Buf.GitIndexPath() == e.Path {
+				// a commit, a checkout or a change of the staged files
+				seen[h.Buf.SharedBuffer] = true
+				h.Buf.UpdateGitDiffBase()
+				continue
+			}
+			if h.Buf.AbsPath != e.Path {
+				continue
+			}
+			seen[h.Buf.SharedBuffer] = true
+
+			if e.Removed {
+				if !h.Buf.DeletedOnDisk {
+					h.Buf.MarkDeleted()
+					InfoBar.Message(h.Buf.GetName(), " has been deleted on disk")
+				}
+				continue
+			}
+
+			h.Buf.DeletedOnDisk = false
+			h.Buf.ChangedOnDisk = true
+			// a prompt is only shown for the current pane, the others are
+			// checked when they become active
+			if h.getReloadSetting() != "prompt" || (h == MainTab().CurPane() && !InfoBar.HasPrompt) {
+				h.checkFileChange()
+			}
+		}
+	}
+
+	err := config.RunPluginFn("onFileChanged", luar.New(ulua.L, e.Path), luar.New(ulua.L, e.Removed))
+	if err != nil {
+		screen.TermMessage(err)
+	}
+}
+
+// checkFileChange reloads the file of the buffer, or asks whether to reload
+// it, according to the reload option, if the file has changed on disk
+func (h *BufPane) checkFileChange() {
+	changed := h.Buf.ChangedOnDisk
+	h.Buf.ChangedOnDisk = false
+	if h.Buf.ReloadDisabled {
+		return
+	}
+	// a change reported by the watcher may keep the modtime and the size of
+	// the file, e.g. with a coarse timestamp, so its contents are compared
+	if !h.Buf.ExternallyModified() && !(changed && h.Buf.ContentModified()) {
+		return
+	}
+
+	reload := h.getReloadSetting()
+	if reload == "prompt" && h.Buf.Modified() && h.Buf.CanMerge() {
+		InfoBar.YNPrompt("The file on disk has changed and the buffer has unsaved changes. Merge them? (y,n,esc)", func(yes, canceled bool) {
+			if canceled {
+				h.Buf.DisableReload()
+				h.Buf.UpdateModTime()
+			} else if yes {
+				h.mergeFromDisk()
+			} else {
+				h.promptReload()
+			}
+		})
+	} else if reload == "prompt" {
+		h.promptReload()
+	} else if reload == "auto" {
+		h.ReOpen()
+	} else if reload == "disabled" {
+		h.Buf.DisableReload()
+	} else {
+		InfoBar.Message("Invalid reload setting")
+	}
+}
+
+// promptReload asks whether to reload the file of the buffer, discarding
+// the changes of the buffer
+func (h *BufPane) promptReload() {
+	InfoBar.YNPrompt("The file on disk has changed. Reload file? (y,n,esc)", func(yes, canceled bool) {
+		if canceled {
+			h.Buf.DisableReload()
+		}
+		if !yes || canceled {
+			h.Buf.UpdateModTime()
+		} else {
+			h.ReOpen()
+		}
+	})
+}
+
+// mergeFromDisk merges the changes of the file on disk into the buffer
+func (h *BufPane) mergeFromDisk() {
+	conflicts, err := h.Buf.MergeFromDisk()
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	h.Relocate()
+	if conflicts > 0 {
+		InfoBar.Message(fmt.Sprintf("Merged the file on disk with %d conflicts", conflicts))
+	} else {
+		InfoBar.Message("Merged the file on disk")
+	}
+}
@@ -3,6 +3,7 @@ package buffer
 import (
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -136,7 +137,7 @@ func (b *SharedBuffer) removeBackup(path string, resolveName string) {
 
 // Backup saves the buffer to the backups directory
 func (b *SharedBuffer) Backup() error {
-	if !b.Settings["backup"].(bool) || b.Path == "" || b.Type != BTDefault {
+	if !b.Settings["backup"].(bool) || b.Path == "" || (b.Type != BTDefault && b.Type != BTHex) {
 		return nil
 	}
 
@@ -146,17 +147,30 @@ func (b *SharedBuffer) Backup() error {
 
 // RemoveBackup removes any backup file associated with this buffer
 func (b *SharedBuffer) RemoveBackup() {
-	if b.keepBackup() || b.Path == "" || b.Type != BTDefault {
+	if b.keepBackup() || b.Path == "" || (b.Type != BTDefault && b.Type != BTHex) {
 		return
 	}
 	f, resolveName := util.DetermineEscapePath(b.backupDir(), b.AbsPath)
 	b.removeBackup(f, resolveName)
 }
 
+// recoverBackup replaces the text of the buffer with the given backup of its
+// file
+func (b *SharedBuffer) recoverBackup(fsize int64, backup io.Reader) {
+	if b.Type.Kind == BTHex.Kind {
+		b.LineArray = newHexLineArray(backup)
+	} else {
+		text, bom := b.decodeFile(backup)
+		b.bom = bom
+		b.LineArray = b.newLineArray(fsize, FFAuto, text)
+	}
+	b.setModified()
+}
+
 // ApplyBackup applies the corresponding backup file to this buffer (if one exists)
 // Returns true if a backup was applied
 func (b *SharedBuffer) ApplyBackup(fsize int64) (bool, bool) {
-	if b.Settings["backup"].(bool) && !b.Settings["permbackup"].(bool) && len(b.Path) > 0 && b.Type == BTDefault {
+	if b.Settings["backup"].(bool) && !b.Settings["permbackup"].(bool) && len(b.Path) > 0 && (b.Type == BTDefault || b.Type == BTHex) {
 		backupfile, resolveName := util.DetermineEscapePath(b.backupDir(), b.AbsPath)
 		if info, err := os.Stat(backupfile); err == nil {
 			backup, err := os.Open(backupfile)
@@ -168,8 +182,7 @@ func (b *SharedBuffer) ApplyBackup(fsize int64) (bool, bool) {
 
 				if choice%3 == 0 {
 					// recover
-					b.LineArray = NewLineArray(uint64(fsize), FFAuto, backup)
-					b.setModified()
+					b.recoverBackup(fsize, backup)
 					return true, true
 				} else if choice%3 == 1 {
 					// delete
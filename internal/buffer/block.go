@@ -0,0 +1,202 @@
+package buffer
+
+import (
+	"strings"
+
+	"github.com/micro-editor/micro/v2/internal/util"
+)
+
+// StartBlockSelection starts a rectangular selection at the cursor, or at
+// the given visual column of the cursor's line if it is not negative. The
+// visual column may be past the end of the line.
+func (c *Cursor) StartBlockSelection(vx int) {
+	if vx < 0 {
+		vx = c.GetVisualX(false)
+	}
+	c.BlockMode = true
+	c.Block = [2]Loc{{vx, c.Y}, {vx, c.Y}}
+	c.updateBlockSelection()
+}
+
+// SetBlockCorner moves the corner of the block selection opposite to the
+// anchor to the given line and visual column, and the cursor along with it
+func (c *Cursor) SetBlockCorner(vx, y int) {
+	if !c.BlockMode {
+		c.StartBlockSelection(-1)
+	}
+	c.Block[1] = Loc{util.Max(vx, 0), util.Clamp(y, 0, c.buf.LinesNum()-1)}
+	c.updateBlockSelection()
+}
+
+// MoveBlockCorner moves the corner of the block selection by dy lines and
+// by dx characters. Past the end of the line, dx counts visual columns.
+func (c *Cursor) MoveBlockCorner(dx, dy int) {
+	if !c.BlockMode || !c.HasSelection() {
+		c.StartBlockSelection(-1)
+	}
+	vx, y := c.Block[1].X, c.Block[1].Y
+	line := c.buf.LineBytes(y)
+	tabsize := util.IntOpt(c.buf.Settings["tabsize"])
+	width := util.StringWidth(line, util.CharacterCount(line), tabsize)
+
+	for ; dx > 0; dx-- {
+		if vx < width {
+			x := util.GetCharPosInLine(line, vx, tabsize)
+			vx = util.StringWidth(line, x+1, tabsize)
+		} else {
+			vx++
+		}
+	}
+	for ; dx < 0 && vx > 0; dx++ {
+		if vx <= width {
+			x := util.GetCharPosInLine(line, vx-1, tabsize)
+			vx = util.StringWidth(line, x, tabsize)
+		} else {
+			vx--
+		}
+	}
+
+	c.SetBlockCorner(vx, c.buf.MoveLines(y, dy))
+}
+
+// BlockBounds returns the first and last lines of the block selection and
+// the visual columns it spans, the last one being excluded
+func (c *Cursor) BlockBounds() (y1, y2, vx1, vx2 int) {
+	a, b := c.Block[0], c.Block[1]
+	return util.Min(a.Y, b.Y), util.Max(a.Y, b.Y), util.Min(a.X, b.X), util.Max(a.X, b.X)
+}
+
+// BlockLineRange returns the range of characters of the given line that
+// are in the block selection. Tabs and wide runes that are only partly
+// covered by the block are included.
+func (c *Cursor) BlockLineRange(y int) (int, int) {
+	_, _, vx1, vx2 := c.BlockBounds()
+	line := c.buf.LineBytes(y)
+	tabsize := util.IntOpt(c.buf.Settings["tabsize"])
+
+	start := util.GetCharPosInLine(line, vx1, tabsize)
+	if vx2 == vx1 {
+		return start, start
+	}
+	end := util.GetCharPosInLine(line, vx2, tabsize)
+	if end < util.CharacterCount(line) && util.StringWidth(line, end, tabsize) < vx2 {
+		end++
+	}
+	return start, end
+}
+
+// updateBlockSelection updates the cursor location and the stream
+// selection covering the block after the block has changed
+func (c *Cursor) updateBlockSelection() {
+	y1, y2, _, _ := c.BlockBounds()
+	start, _ := c.BlockLineRange(y1)
+	_, end := c.BlockLineRange(y2)
+	c.CurSelection = [2]Loc{{start, y1}, {end, y2}}
+	c.OrigSelection = c.CurSelection
+
+	corner := c.Block[1]
+	tabsize := util.IntOpt(c.buf.Settings["tabsize"])
+	c.Loc = Loc{util.GetCharPosInLine(c.buf.LineBytes(corner.Y), corner.X, tabsize), corner.Y}
+	c.LastVisualX = corner.X
+	c.LastWrappedVisualX = corner.X
+}
+
+// HasBlockSelection returns whether the cursor has a rectangular selection
+// spanning several lines or some columns
+func (c *Cursor) HasBlockSelection() bool {
+	return c.BlockMode && (c.Block[0].Y != c.Block[1].Y || c.Block[0].X != c.Block[1].X)
+}
+
+// GetBlockSelection returns the text of every line of the block selection
+func (c *Cursor) GetBlockSelection() []string {
+	y1, y2, _, _ := c.BlockBounds()
+	lines := make([]string, 0, y2-y1+1)
+	for y := y1; y <= y2; y++ {
+		start, end := c.BlockLineRange(y)
+		lines = append(lines, string(c.buf.Substr(Loc{start, y}, Loc{end, y})))
+	}
+	return lines
+}
+
+// blockColumnText returns the text to insert at the given visual column
+// of a line so that the inserted text starts at this column, i.e. the text
+// padded with spaces if the line is shorter. It also returns where in the
+// line the text must be inserted.
+func (c *Cursor) blockColumnText(y, vx int, text string) (Loc, string) {
+	line := c.buf.LineBytes(y)
+	tabsize := util.IntOpt(c.buf.Settings["tabsize"])
+	n := util.CharacterCount(line)
+	if width := util.StringWidth(line, n, tabsize); width < vx {
+		return Loc{n, y}, strings.Repeat(" ", vx-width) + text
+	}
+	return Loc{util.GetCharPosInLine(line, vx, tabsize), y}, text
+}
+
+// DeleteBlockSelection deletes the text of the block selection, leaving
+// an empty block spanning the same lines at its first column
+func (c *Cursor) DeleteBlockSelection() {
+	y1, y2, vx1, _ := c.BlockBounds()
+	var deltas []Delta
+	for y := y1; y <= y2; y++ {
+		start, end := c.BlockLineRange(y)
+		if start < end {
+			deltas = append(deltas, Delta{[]byte{}, Loc{start, y}, Loc{end, y}, nil})
+		}
+	}
+	if len(deltas) > 0 {
+		c.buf.MultipleReplace(deltas)
+	}
+
+	c.Block[0].X, c.Block[1].X = vx1, vx1
+	c.updateBlockSelection()
+}
+
+// InsertBlockText inserts the same text on every line of the block
+// selection after deleting the selected text, so that typing in a block
+// types on all its lines. The block is moved after the inserted text.
+func (c *Cursor) InsertBlockText(text string) {
+	if c.HasBlockSelection() {
+		c.DeleteBlockSelection()
+	}
+
+	y1, y2, vx1, _ := c.BlockBounds()
+	deltas := make([]Delta, 0, y2-y1+1)
+	for y := y1; y <= y2; y++ {
+		loc, t := c.blockColumnText(y, vx1, text)
+		deltas = append(deltas, Delta{[]byte(t), loc, loc, nil})
+	}
+	c.buf.MultipleReplace(deltas)
+
+	vx := vx1 + util.StringWidth([]byte(text), util.CharacterCountInString(text), util.IntOpt(c.buf.Settings["tabsize"]))
+	c.Block[0].X, c.Block[1].X = vx, vx
+	c.updateBlockSelection()
+}
+
+// PasteBlock inserts the given lines as a block: every line is inserted at
+// the cursor's visual column on consecutive lines, adding lines at the end
+// of the buffer if needed
+func (c *Cursor) PasteBlock(lines []string) {
+	vx, y := c.GetVisualX(false), c.Y
+	if c.BlockMode {
+		if c.HasBlockSelection() {
+			c.DeleteBlockSelection()
+		}
+		y, _, vx, _ = c.BlockBounds()
+	}
+
+	if extra := y + len(lines) - c.buf.LinesNum(); extra > 0 {
+		c.buf.Insert(c.buf.End(), strings.Repeat("\n", extra))
+	}
+
+	deltas := make([]Delta, 0, len(lines))
+	for i, l := range lines {
+		loc, t := c.blockColumnText(y+i, vx, l)
+		deltas = append(deltas, Delta{[]byte(t), loc, loc, nil})
+	}
+	c.buf.MultipleReplace(deltas)
+
+	c.BlockMode = false
+	c.ResetSelection()
+	loc, _ := c.blockColumnText(y, vx, "")
+	c.GotoLoc(loc)
+}
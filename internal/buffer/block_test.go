@@ -0,0 +1,62 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/micro-editor/micro/v2/internal/clipboard"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockSelection(t *testing.T) {
+	b := NewBufferFromString("abcdef\nxy\n123456", "", BTDefault)
+	defer b.Close()
+	c := b.GetActiveCursor()
+
+	c.GotoLoc(Loc{1, 0})
+	c.StartBlockSelection(-1)
+	c.SetBlockCorner(4, 2)
+	assert.True(t, c.HasBlockSelection())
+	assert.Equal(t, []string{"bcd", "y", "234"}, c.GetBlockSelection())
+	assert.Equal(t, Loc{4, 2}, c.Loc)
+
+	c.CopySelection(clipboard.ClipboardReg)
+	clip, _ := clipboard.Read(clipboard.ClipboardReg)
+	assert.Equal(t, "bcd\ny\n234", clip)
+	assert.True(t, clipboard.IsBlock(clipboard.ClipboardReg, clip))
+
+	c.DeleteBlockSelection()
+	assert.Equal(t, "aef\nx\n156", string(b.Bytes()))
+
+	// typing in an empty block types on all its lines
+	c.InsertBlockText("Z")
+	assert.Equal(t, "aZef\nxZ\n1Z56", string(b.Bytes()))
+
+	c.ResetSelection()
+	c.GotoLoc(Loc{2, 1})
+	c.PasteBlock([]string{"12", "34", "56"})
+	assert.Equal(t, "aZef\nxZ12\n1Z3456\n  56", string(b.Bytes()))
+	assert.Equal(t, Loc{2, 1}, c.Loc)
+
+	// a stream copy is not a block
+	c.SetSelectionStart(Loc{0, 0})
+	c.SetSelectionEnd(Loc{2, 0})
+	c.CopySelection(clipboard.ClipboardReg)
+	clip, _ = clipboard.Read(clipboard.ClipboardReg)
+	assert.False(t, clipboard.IsBlock(clipboard.ClipboardReg, clip))
+}
+
+func TestBlockWidths(t *testing.T) {
+	b := NewBufferFromString("\tx\na世b", "", BTDefault)
+	defer b.Close()
+	c := b.GetActiveCursor()
+
+	// characters partly in the block are selected
+	c.StartBlockSelection(2)
+	c.SetBlockCorner(3, 1)
+	assert.Equal(t, []string{"\t", "世"}, c.GetBlockSelection())
+
+	c.MoveBlockCorner(1, 0)
+	assert.Equal(t, []string{"\t", "世b"}, c.GetBlockSelection())
+	c.MoveBlockCorner(0, -1)
+	assert.Equal(t, []string{"\t"}, c.GetBlockSelection())
+}
@@ -21,12 +21,11 @@ import (
 	ulua "github.com/micro-editor/micro/v2/internal/lua"
 	"github.com/micro-editor/micro/v2/internal/screen"
 	"github.com/micro-editor/micro/v2/internal/util"
+	"github.com/micro-editor/micro/v2/internal/watcher"
 	"github.com/micro-editor/micro/v2/pkg/highlight"
 	dmp "github.com/sergi/go-diff/diffmatchpatch"
 	"golang.org/x/text/encoding"
-	"golang.org/x/text/encoding/htmlindex"
 	"golang.org/x/text/encoding/unicode"
-	"golang.org/x/text/transform"
 )
 
 var (
@@ -61,6 +60,8 @@ var (
 	// BTStdout is a buffer that only writes to stdout
 	// when closed
 	BTStdout = BufType{6, false, true, true}
+	// BTHex is a buffer that shows the bytes of a file in hexadecimal
+	BTHex = BufType{7, false, false, false}
 )
 
 // SharedBuffer is a struct containing info that is shared among buffers
@@ -69,6 +70,23 @@ type SharedBuffer struct {
 	*LineArray
 	// Stores the last modification time of the file the buffer is pointing to
 	ModTime time.Time
+	// diskSize is the size of the file when ModTime was updated
+	diskSize int64
+	// diskHash is the hash of the contents of the file when ModTime was
+	// updated, or zero for large files
+	diskHash [md5.Size]byte
+	// DeletedOnDisk is true if the file has been removed since it was
+	// opened or saved
+	DeletedOnDisk bool
+	// ChangedOnDisk is set when the file watcher reports a change of the
+	// file, until the change is checked
+	ChangedOnDisk bool
+	// watchedPath is the path passed to the file watcher for this buffer
+	watchedPath string
+	// mergeBase is the text of the file when it was last loaded or saved,
+	// the base of the merge of its changes on disk with the changes of the
+	// buffer. It is nil if it is not known, e.g. for large files.
+	mergeBase []byte
 	// Type of the buffer (e.g. help, raw, scratch etc..)
 	Type BufType
 
@@ -85,8 +103,17 @@ type SharedBuffer struct {
 	Settings map[string]any
 	// LocalSettings customized by the user for this buffer only
 	LocalSettings map[string]bool
+	// editorConfig is the options set by the .editorconfig files of the
+	// file
+	editorConfig map[string]config.EditorConfigOption
+	// detectedIndent is the indentation options detected from the text of
+	// the buffer
+	detectedIndent map[string]any
 
 	encoding encoding.Encoding
+	// bom is true if the file starts with a byte order mark, which is kept
+	// when it is saved
+	bom bool
 
 	Suggestions   []string
 	Completions   []string
@@ -101,6 +128,18 @@ type SharedBuffer struct {
 	diff              map[int]DiffStatus
 
 	forceKeepBackup bool
+	// gitDir is the git directory of the repository of the file, if
+	// gitChecked is set and the file is in a repository
+	gitDir     string
+	gitChecked bool
+	// blame is the annotations shown by ShowBlame
+	blame []string
+	// locked is true if this instance holds the lock file of the file
+	locked bool
+
+	// Binary is true if the first lines of the file contain NUL bytes,
+	// so it is probably better shown in a hex view
+	Binary bool
 
 	// ReloadDisabled allows the user to disable reloads if they
 	// are viewing a file that is constantly changing
@@ -118,25 +157,64 @@ type SharedBuffer struct {
 	SyntaxDef *highlight.Def
 
 	ModifiedThisFrame bool
+	// version is incremented by every modification of the text
+	version uint64
 
 	// Hash of the original buffer -- empty if fastdirty is on
 	origHash [md5.Size]byte
+
+	// Closed folds, sorted and never overlapping
+	folds []Fold
+
+	// LargeFile is true if the file was larger than the `largefile` option
+	// when it was opened, so the expensive features were disabled
+	LargeFile bool
 }
 
-func (b *SharedBuffer) insert(pos Loc, value []byte) {
+func (b *SharedBuffer) insert(pos Loc, value []byte, endings []FileFormat) {
 	b.HasSuggestions = false
-	b.LineArray.insert(pos, value)
+	mixed := b.MixedEndings()
+	b.LineArray.insert(pos, value, endings)
+	b.version++
 	b.setModified()
+	if mixed != b.MixedEndings() {
+		b.updateFileFormat()
+	}
 
 	inslines := bytes.Count(value, []byte{'\n'})
+	b.insertFoldLines(pos.Y, inslines)
+	b.insertBlameLines(pos.Y, inslines)
 	b.MarkModified(pos.Y, pos.Y+inslines)
 }
 
-func (b *SharedBuffer) remove(start, end Loc) []byte {
+func (b *SharedBuffer) remove(start, end Loc) ([]byte, []FileFormat) {
 	b.HasSuggestions = false
+	b.version++
 	defer b.setModified()
 	defer b.MarkModified(start.Y, end.Y)
-	return b.LineArray.remove(start, end)
+	b.removeFoldLines(start.Y, end.Y)
+	b.removeBlameLines(start.Y, end.Y)
+	mixed := b.MixedEndings()
+	text, endings := b.LineArray.remove(start, end)
+	if mixed != b.MixedEndings() {
+		b.updateFileFormat()
+	}
+	return text, endings
+}
+
+// updateFileFormat sets the fileformat option to the file format of the
+// buffer, or to mixed if its lines do not all have the same line ending
+func (b *SharedBuffer) updateFileFormat() {
+	switch {
+	case b.MixedEndings():
+		b.Settings["fileformat"] = "mixed"
+	case b.Endings == FFUnix:
+		b.Settings["fileformat"] = "unix"
+	case b.Endings == FFDos:
+		b.Settings["fileformat"] = "dos"
+	case b.Endings == FFMac:
+		b.Settings["fileformat"] = "mac"
+	}
 }
 
 func (b *SharedBuffer) setModified() {
@@ -164,29 +242,52 @@ func (b *SharedBuffer) setModified() {
 func (b *SharedBuffer) calcHash(out *[md5.Size]byte) {
 	h := md5.New()
 
-	if len(b.lines) > 0 {
-		h.Write(b.lines[0].data)
+	if b.LinesNum() > 0 {
+		h.Write(b.LineBytes(0))
 
-		for _, l := range b.lines[1:] {
-			if b.Endings == FFDos {
-				h.Write([]byte{'\r', '\n'})
-			} else {
-				h.Write([]byte{'\n'})
-			}
-			h.Write(l.data)
+		for i := 1; i < b.LinesNum(); i++ {
+			h.Write(eolBytes(b.LineEnding(i - 1)))
+			h.Write(b.LineBytes(i))
 		}
 	}
 
 	h.Sum((*out)[:0])
 }
 
+// newLineArray creates the line array for a file of the given size,
+// using the piece table backend if the file is at least `piecetable`
+// megabytes large
+func (b *SharedBuffer) newLineArray(size int64, endings FileFormat, reader io.Reader) *LineArray {
+	threshold := b.Settings["piecetable"].(float64) * 1024 * 1024
+	if float64(size) >= threshold {
+		return NewPieceTableLineArray(uint64(size), endings, reader)
+	}
+	return NewLineArray(uint64(size), endings, reader)
+}
+
+// mapLineArray creates a piece table line array whose original is the file
+// mapped into memory, if the file is large enough for the piece table and
+// its text needs no decoding
+func (b *SharedBuffer) mapLineArray(r io.Reader, size int64, endings FileFormat) (*LineArray, bool, bool) {
+	f, ok := r.(*os.File)
+	threshold := b.Settings["piecetable"].(float64) * 1024 * 1024
+	if !ok || size == 0 || float64(size) < threshold || b.encoding != unicode.UTF8 {
+		return nil, false, false
+	}
+	la, bom, err := mapPieceTableLineArray(f, size, endings)
+	if err != nil {
+		return nil, false, false
+	}
+	return la, bom, true
+}
+
 // MarkModified marks the buffer as modified for this frame
 // and performs rehighlighting if syntax highlighting is enabled
 func (b *SharedBuffer) MarkModified(start, end int) {
 	b.ModifiedThisFrame = true
 
-	start = util.Clamp(start, 0, len(b.lines)-1)
-	end = util.Clamp(end, 0, len(b.lines)-1)
+	start = util.Clamp(start, 0, b.LinesNum()-1)
+	end = util.Clamp(end, 0, b.LinesNum()-1)
 
 	if b.Settings["syntax"].(bool) && b.SyntaxDef != nil {
 		l := -1
@@ -239,6 +340,11 @@ type Buffer struct {
 	*EventHandler
 	*SharedBuffer
 
+	// UndoStack and RedoStack are the undo and redo stacks of the current
+	// branch of the undo tree
+	UndoStack *TEStack
+	RedoStack *TEStack
+
 	cursors     []*Cursor
 	curCursor   int
 	StartCursor Loc
@@ -261,6 +367,9 @@ type Buffer struct {
 	LastSearchRegex bool
 	// HighlightSearch enables highlighting all instances of the last successful search
 	HighlightSearch bool
+	// multiLineMatches caches the matches of the last search for highlighting
+	// if it can span several lines
+	multiLineMatches *multiLineMatches
 
 	// OverwriteMode indicates that we are in overwrite mode (toggled by
 	// Insert key by default) i.e. that typing a character shall replace the
@@ -361,11 +470,15 @@ func NewBuffer(r io.Reader, size int64, path string, btype BufType, cmd Command)
 	}
 
 	b := new(Buffer)
+	b.UndoStack = &TEStack{b, false}
+	b.RedoStack = &TEStack{b, true}
 
 	found := false
 	if len(path) > 0 {
 		for _, buf := range OpenBuffers {
-			if buf.AbsPath == absPath && buf.Type != BTInfo {
+			// a hex view does not share the text of the file
+			if buf.AbsPath == absPath && buf.Type != BTInfo &&
+				(buf.Type.Kind == BTHex.Kind) == (btype.Kind == BTHex.Kind) {
 				found = true
 				b.SharedBuffer = buf.SharedBuffer
 				b.EventHandler = buf.EventHandler
@@ -390,39 +503,72 @@ func NewBuffer(r io.Reader, size int64, path string, btype BufType, cmd Command)
 			}
 		}
 		config.UpdatePathGlobLocals(b.Settings, absPath)
+		if path != "" && b.Settings["editorconfig"].(bool) {
+			b.editorConfig = config.UpdateEditorConfigLocals(b.Settings, absPath)
+		}
 
-		b.encoding, err = htmlindex.Get(b.Settings["encoding"].(string))
-		if err != nil {
-			b.encoding = unicode.UTF8
-			b.Settings["encoding"] = "utf-8"
+		b.LargeFile = isLargeFile(size, b.Settings)
+		if b.LargeFile {
+			b.applyLargeFileSettings()
+		}
+
+		file := bufio.NewReaderSize(r, encodingSniffLen)
+		if b.Type.Kind == BTHex.Kind {
+			// the bytes of a hex view are not decoded
+			b.encoding = encoding.Nop
+			b.OverwriteMode = true
+		} else {
+			b.detectEncoding(file)
 		}
 
 		var ok bool
-		hasBackup, ok = b.ApplyBackup(size)
+		if l := b.lockOwner(); l != nil {
+			// the backup of the file belongs to the other instance, which is
+			// still running
+			hasBackup, ok = b.resolveLock(l, size), true
+		} else {
+			hasBackup, ok = b.ApplyBackup(size)
+		}
 
 		if !ok {
 			return NewBufferFromString("", "", btype)
 		}
-		if !hasBackup {
-			reader := bufio.NewReader(transform.NewReader(r, b.encoding.NewDecoder()))
-
+		if !b.Type.Readonly {
+			if err := b.LockFile(); err != nil {
+				screen.TermMessage("Error creating the lock file of ", path, ": ", err)
+			}
+		}
+		b.watch()
+		if !hasBackup && b.Type.Kind == BTHex.Kind {
+			b.LineArray = newHexLineArray(file)
+		} else if !hasBackup {
 			var ff FileFormat = FFAuto
 
 			if size == 0 {
 				// for empty files, use the fileformat setting instead of
 				// autodetection
 				switch b.Settings["fileformat"] {
-				case "unix":
-					ff = FFUnix
 				case "dos":
 					ff = FFDos
+				case "mac":
+					ff = FFMac
+				default:
+					ff = FFUnix
 				}
 			} else {
 				// in case of autodetection treat as locally set
 				b.LocalSettings["fileformat"] = true
 			}
 
-			b.LineArray = NewLineArray(uint64(size), ff, reader)
+			if la, bom, ok := b.mapLineArray(r, size, ff); ok {
+				b.LineArray, b.bom = la, bom
+			} else {
+				text, bom := b.decodeFile(file)
+				b.bom = bom
+				b.LineArray = b.newLineArray(size, ff, bufio.NewReader(text))
+			}
+			b.setMergeBase(nil)
+			b.Binary = b.Type == BTDefault && b.hasNulBytes()
 		}
 		b.EventHandler = NewEventHandler(b.SharedBuffer, b.cursors)
 
@@ -430,20 +576,33 @@ func NewBuffer(r io.Reader, size int64, path string, btype BufType, cmd Command)
 		b.UpdateModTime()
 	}
 
-	if b.Settings["readonly"].(bool) && b.Type == BTDefault {
+	if b.Settings["readonly"].(bool) && (b.Type == BTDefault || b.Type == BTHex) {
 		b.Type.Readonly = true
 	}
 
-	switch b.Endings {
-	case FFUnix:
-		b.Settings["fileformat"] = "unix"
-	case FFDos:
-		b.Settings["fileformat"] = "dos"
-	}
+	b.updateFileFormat()
 
 	b.UpdateRules()
 	// we know the filetype now, so update per-filetype settings
 	config.UpdateFileTypeLocals(b.Settings, b.Settings["filetype"].(string))
+	b.applyEditorConfig()
+	if !found && b.Type == BTDefault && b.Settings["autodetectindent"].(bool) {
+		b.detectedIndent = b.detectIndent()
+		for k, v := range b.detectedIndent {
+			if _, ok := b.editorConfig[k]; ok {
+				// the .editorconfig files take precedence
+				delete(b.detectedIndent, k)
+				continue
+			}
+			// not marked as local, so that setting the option globally
+			// still applies to the buffer
+			b.Settings[k] = v
+		}
+	}
+	if b.LargeFile && !found {
+		// per-filetype settings must not turn the expensive features back on
+		b.applyLargeFileSettings()
+	}
 
 	if _, err := os.Stat(filepath.Join(config.ConfigDir, "buffers")); errors.Is(err, fs.ErrNotExist) {
 		os.Mkdir(filepath.Join(config.ConfigDir, "buffers"), os.ModePerm)
@@ -489,6 +648,10 @@ func NewBuffer(r io.Reader, size int64, path string, btype BufType, cmd Command)
 		}
 	}
 
+	if !found {
+		b.UpdateGitDiffBase()
+	}
+
 	err = config.RunPluginFn("onBufferOpen", luar.New(ulua.L, b))
 	if err != nil {
 		screen.TermMessage(err)
@@ -528,6 +691,10 @@ func (b *Buffer) Fini() {
 		b.Serialize()
 	}
 	b.CancelBackup()
+	if !b.Shared() {
+		b.UnlockFile()
+		b.unwatch()
+	}
 
 	if b.Type == BTStdout {
 		fmt.Fprint(util.Stdout, string(b.Bytes()))
@@ -555,9 +722,10 @@ func (b *Buffer) SetName(s string) {
 	b.name = s
 }
 
-// Insert inserts the given string of text at the start location
+// Insert inserts the given string of text at the start location. The text
+// of a hex view is only changed by ReplaceHexBytes.
 func (b *Buffer) Insert(start Loc, text string) {
-	if !b.Type.Readonly {
+	if !b.Type.Readonly && b.Type.Kind != BTHex.Kind {
 		b.EventHandler.cursors = b.cursors
 		b.EventHandler.active = b.curCursor
 		b.EventHandler.Insert(start, text)
@@ -566,7 +734,7 @@ func (b *Buffer) Insert(start Loc, text string) {
 
 // Remove removes the characters between the start and end locations
 func (b *Buffer) Remove(start, end Loc) {
-	if !b.Type.Readonly {
+	if !b.Type.Readonly && b.Type.Kind != BTHex.Kind {
 		b.EventHandler.cursors = b.cursors
 		b.EventHandler.active = b.curCursor
 		b.EventHandler.Remove(start, end)
@@ -581,44 +749,142 @@ func (b *Buffer) FileType() string {
 // ExternallyModified returns whether the file being edited has
 // been modified by some external process
 func (b *Buffer) ExternallyModified() bool {
-	modTime, err := util.GetModTime(b.Path)
+	info, err := os.Stat(b.Path)
 	if err == nil {
-		return modTime != b.ModTime
+		return info.ModTime() != b.ModTime || info.Size() != b.diskSize
 	}
 	return false
 }
 
+// ContentModified returns whether the contents of the file being edited
+// differ from the ones it had when its modtime was last updated, even if
+// its modtime and size are the same. It is always false for large files.
+func (b *Buffer) ContentModified() bool {
+	if b.LargeFile {
+		return false
+	}
+	hash, err := hashFile(b.Path)
+	return err == nil && hash != b.diskHash
+}
+
 // UpdateModTime updates the modtime of this file
-func (b *Buffer) UpdateModTime() (err error) {
-	b.ModTime, err = util.GetModTime(b.Path)
-	return
+func (b *Buffer) UpdateModTime() error {
+	info, err := os.Stat(b.Path)
+	if err != nil {
+		b.ModTime = time.Now()
+		return err
+	}
+	b.ModTime, b.diskSize = info.ModTime(), info.Size()
+	b.diskHash = [md5.Size]byte{}
+	if !b.LargeFile {
+		b.diskHash, _ = hashFile(b.Path)
+	}
+	return nil
 }
 
-// ReOpen reloads the current buffer from disk
-func (b *Buffer) ReOpen() error {
+// hashFile returns the md5 hash of the contents of a file
+func hashFile(path string) ([md5.Size]byte, error) {
+	var hash [md5.Size]byte
+	f, err := os.Open(path)
+	if err != nil {
+		return hash, err
+	}
+	defer f.Close()
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return hash, err
+	}
+	h.Sum(hash[:0])
+	return hash, nil
+}
+
+// MarkDeleted records that the file of the buffer has been removed from the
+// disk. The buffer is marked as modified, so that its text is not lost
+// without a warning.
+func (b *Buffer) MarkDeleted() {
+	b.DeletedOnDisk = true
+	if !b.Type.Scratch {
+		b.isModified = true
+		// the text does not match the file anymore, even if it is edited
+		// back to its original text when fastdirty is off
+		b.origHash = [md5.Size]byte{}
+	}
+}
+
+// setMergeBase sets the text of the file as it is on disk, or the text of
+// the buffer if text is nil, as the base of the next merge
+func (b *SharedBuffer) setMergeBase(text []byte) {
+	if b.LargeFile || b.Type.Kind != BTDefault.Kind {
+		b.mergeBase = nil
+		return
+	}
+	if text == nil {
+		text = b.Bytes()
+	}
+	b.mergeBase = text
+}
+
+// watch makes the file watcher report the changes of the file of the buffer
+func (b *SharedBuffer) watch() {
+	if b.Path == "" || b.Type.Kind != BTDefault.Kind || b.watchedPath != "" {
+		return
+	}
+	b.watchedPath = b.AbsPath
+	watcher.Watch(b.watchedPath)
+}
+
+func (b *SharedBuffer) unwatch() {
+	if b.watchedPath != "" {
+		watcher.Unwatch(b.watchedPath)
+		b.watchedPath = ""
+	}
+	b.unwatchGit()
+}
+
+// readFile returns the text of the file of the buffer on disk and whether
+// it starts with a byte order mark
+func (b *Buffer) readFile() (string, bool, error) {
 	file, err := os.Open(b.Path)
 	if err != nil {
-		return err
+		return "", false, err
 	}
 	defer file.Close()
 
-	enc, err := htmlindex.Get(b.Settings["encoding"].(string))
-	if err != nil {
-		return err
+	if b.Type.Kind == BTHex.Kind {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return "", false, err
+		}
+		return string(hexDump(0, data, true)), false, nil
 	}
 
-	reader := bufio.NewReader(transform.NewReader(file, enc.NewDecoder()))
+	reader, bom := b.decodeFile(file)
 	data, err := io.ReadAll(reader)
-	txt := string(data)
+	if err != nil {
+		return "", false, err
+	}
+	return string(data), bom, nil
+}
 
+// ReOpen reloads the current buffer from disk
+func (b *Buffer) ReOpen() error {
+	txt, bom, err := b.readFile()
 	if err != nil {
 		return err
 	}
-	b.EventHandler.ApplyDiff(txt)
+	b.bom = bom
+	// the lines are compared without their line endings, which are copied
+	// afterwards
+	la := NewLineArray(uint64(len(txt)), FFAuto, strings.NewReader(txt))
+	b.EventHandler.ApplyDiff(string(la.Substr(la.Start(), la.End())))
+	b.copyEndings(la)
+	b.updateFileFormat()
+	b.setMergeBase([]byte(txt))
 
+	b.DeletedOnDisk = false
 	err = b.UpdateModTime()
 	if !b.Settings["fastdirty"].(bool) {
-		if len(data) > LargeFileThreshold {
+		if len(txt) > LargeFileThreshold {
 			b.Settings["fastdirty"] = true
 		} else {
 			b.calcHash(&b.origHash)
@@ -706,10 +972,7 @@ func (b *Buffer) Size() int {
 		nb += len(b.LineBytes(i))
 
 		if i != b.LinesNum()-1 {
-			if b.Endings == FFDos {
-				nb++ // carriage return
-			}
-			nb++ // newline
+			nb += len(eolBytes(b.LineEnding(i)))
 		}
 	}
 	return nb
@@ -866,7 +1129,7 @@ func (b *Buffer) UpdateRules() {
 			if header.MatchFileName(b.Path) {
 				matchedFileName = true
 			}
-			if len(fnameMatches) == 0 && header.MatchFileHeader(b.lines[0].data) {
+			if len(fnameMatches) == 0 && header.MatchFileHeader(b.LineBytes(0)) {
 				matchedFileHeader = true
 			}
 		} else if header.FileType == ft {
@@ -920,7 +1183,7 @@ func (b *Buffer) UpdateRules() {
 				if header.MatchFileName(b.Path) {
 					fnameMatches = append(fnameMatches, syntaxFileInfo{header, f.Name(), nil})
 				}
-				if len(fnameMatches) == 0 && header.MatchFileHeader(b.lines[0].data) {
+				if len(fnameMatches) == 0 && header.MatchFileHeader(b.LineBytes(0)) {
 					headerMatches = append(headerMatches, syntaxFileInfo{header, f.Name(), nil})
 				}
 			} else if header.FileType == ft {
@@ -943,7 +1206,7 @@ func (b *Buffer) UpdateRules() {
 				// multiple matching syntax files found, try to resolve the ambiguity
 				// using signatures
 				detectlimit := util.IntOpt(b.Settings["detectlimit"])
-				lineCount := len(b.lines)
+				lineCount := b.LinesNum()
 				limit := lineCount
 				if detectlimit > 0 && lineCount > detectlimit {
 					limit = detectlimit
@@ -953,7 +1216,7 @@ func (b *Buffer) UpdateRules() {
 				for _, m := range matches {
 					if m.header.HasFileSignature() {
 						for i := 0; i < limit; i++ {
-							if m.header.MatchFileSignature(b.lines[i].data) {
+							if m.header.MatchFileSignature(b.LineBytes(i)) {
 								syntaxFile = m.fileName
 								if m.syntaxDef != nil {
 									b.SyntaxDef = m.syntaxDef
@@ -1012,7 +1275,7 @@ func (b *Buffer) UpdateRules() {
 
 // ClearMatches clears all of the syntax highlighting for the buffer
 func (b *Buffer) ClearMatches() {
-	for i := range b.lines {
+	for i := 0; i < b.LinesNum(); i++ {
 		b.SetMatch(i, nil)
 		b.SetState(i, nil)
 	}
@@ -1127,17 +1390,18 @@ func (b *Buffer) ClearCursors() {
 
 // MoveLinesUp moves the range of lines up one row
 func (b *Buffer) MoveLinesUp(start int, end int) {
-	if start < 1 || start >= end || end > len(b.lines) {
+	if start < 1 || start >= end || end > b.LinesNum() {
 		return
 	}
 	l := string(b.LineBytes(start - 1))
-	if end == len(b.lines) {
+	if end == b.LinesNum() {
 		b.insert(
 			Loc{
-				util.CharacterCount(b.lines[end-1].data),
+				util.CharacterCount(b.LineBytes(end - 1)),
 				end - 1,
 			},
 			[]byte{'\n'},
+			nil,
 		)
 	}
 	b.Insert(
@@ -1152,7 +1416,7 @@ func (b *Buffer) MoveLinesUp(start int, end int) {
 
 // MoveLinesDown moves the range of lines down one row
 func (b *Buffer) MoveLinesDown(start int, end int) {
-	if start < 0 || start >= end || end >= len(b.lines) {
+	if start < 0 || start >= end || end >= b.LinesNum() {
 		return
 	}
 	l := string(b.LineBytes(end))
@@ -1196,7 +1460,7 @@ func (b *Buffer) findMatchingBrace(braceType [2]rune, start Loc, char rune) (Loc
 		}
 	} else if char == braceType[1] {
 		for y := start.Y; y >= 0; y-- {
-			l := []rune(string(b.lines[y].data))
+			l := []rune(string(b.LineBytes(y)))
 			xInit := len(l) - 1
 			if y == start.Y {
 				xInit = start.X
@@ -1281,7 +1545,7 @@ func (b *Buffer) Retab() {
 		l = bytes.TrimLeft(l, " \t")
 
 		b.Lock()
-		b.lines[i].data = append(ws, l...)
+		b.setLineBytes(i, append(ws, l...))
 		b.Unlock()
 
 		b.MarkModified(i, i)
@@ -1290,6 +1554,23 @@ func (b *Buffer) Retab() {
 	b.setModified()
 }
 
+// NormalizeEndings ends every line of the buffer with the line ending of its
+// file format, as a single undoable edit, and returns the number of lines
+// whose line ending was changed
+func (b *Buffer) NormalizeEndings() int {
+	var deltas []Delta
+	// the last lines are changed first so that the locations of the other
+	// ones don't move
+	for i := b.LinesNum() - 2; i >= 0; i-- {
+		if b.LineEnding(i) != b.Endings {
+			end := Loc{util.CharacterCount(b.LineBytes(i)), i}
+			deltas = append(deltas, Delta{[]byte{'\n'}, end, Loc{0, i + 1}, nil})
+		}
+	}
+	b.MultipleReplace(deltas)
+	return len(deltas)
+}
+
 // ParseCursorLocation turns a cursor location like 10:5 (LINE:COL)
 // into a loc
 func ParseCursorLocation(cursorPositions []string) (Loc, error) {
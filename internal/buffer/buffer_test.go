@@ -2,6 +2,9 @@ package buffer
 
 import (
 	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 
@@ -27,6 +30,18 @@ func init() {
 }
 
 func check(t *testing.T, before []string, operations []operation, after []string) {
+	// run every check against both line array backends
+	defer func(threshold any) {
+		config.GlobalSettings["piecetable"] = threshold
+	}(config.GlobalSettings["piecetable"])
+
+	for _, threshold := range []float64{64, 0} {
+		config.GlobalSettings["piecetable"] = threshold
+		checkBuffer(t, before, operations, after)
+	}
+}
+
+func checkBuffer(t *testing.T, before []string, operations []operation, after []string) {
 	assert := assert.New(t)
 
 	b := NewBufferFromString(strings.Join(before, "\n"), "", BTDefault)
@@ -96,6 +111,103 @@ func check(t *testing.T, before []string, operations []operation, after []string
 	b.Close()
 }
 
+func TestLargeFile(t *testing.T) {
+	defer func(threshold any) {
+		config.GlobalSettings["largefile"] = threshold
+	}(config.GlobalSettings["largefile"])
+
+	config.GlobalSettings["largefile"] = float64(1) / 1024 / 1024
+	b := NewBufferFromString("large\nfile", "", BTDefault)
+	assert.True(t, b.LargeFile)
+	assert.Equal(t, false, b.Settings["syntax"])
+	assert.Equal(t, true, b.Settings["fastdirty"])
+	assert.True(t, b.LocalSettings["syntax"])
+
+	assert.NoError(t, b.SetOption("syntax", "on"))
+	assert.Equal(t, true, b.Settings["syntax"])
+	b.Close()
+
+	config.GlobalSettings["largefile"] = float64(0)
+	b = NewBufferFromString("large\nfile", "", BTDefault)
+	assert.False(t, b.LargeFile)
+	b.Close()
+}
+
+func TestMappedFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("memory mapping not supported")
+	}
+	defer func(dir string, threshold any) {
+		config.ConfigDir = dir
+		config.GlobalSettings["piecetable"] = threshold
+	}(config.ConfigDir, config.GlobalSettings["piecetable"])
+	config.ConfigDir = t.TempDir()
+	config.GlobalSettings["piecetable"] = float64(0)
+
+	path := filepath.Join(t.TempDir(), "file.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("\xef\xbb\xbfone\ntwo\n"), 0644))
+
+	b, err := NewBufferFromFile(path, BTDefault)
+	assert.NoError(t, err)
+	defer b.Close()
+	pt := b.store.(*pieceTable)
+	assert.NotNil(t, pt.file)
+	assert.True(t, b.bom)
+	assert.Equal(t, "one\ntwo\n", string(b.Bytes()))
+
+	// the file is copied into memory before it is truncated
+	b.Insert(Loc{3, 0}, " and")
+	assert.NoError(t, b.Save())
+	assert.Nil(t, pt.file)
+	assert.Equal(t, "one and\ntwo\n", string(b.Bytes()))
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "\xef\xbb\xbfone and\ntwo\n", string(data))
+}
+
+func TestContentModified(t *testing.T) {
+	defer func(dir string) {
+		config.ConfigDir = dir
+	}(config.ConfigDir)
+	config.ConfigDir = t.TempDir()
+
+	path := filepath.Join(t.TempDir(), "file.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("one\n"), 0644))
+	b, err := NewBufferFromFile(path, BTDefault)
+	assert.NoError(t, err)
+	defer b.Close()
+	assert.False(t, b.ContentModified())
+
+	// same size and modtime
+	assert.NoError(t, os.WriteFile(path, []byte("two\n"), 0644))
+	assert.NoError(t, os.Chtimes(path, b.ModTime, b.ModTime))
+	assert.False(t, b.ExternallyModified())
+	assert.True(t, b.ContentModified())
+
+	// a deleted file stays modified when it is edited back to its text
+	b.MarkDeleted()
+	b.Insert(Loc{0, 0}, "x")
+	b.Remove(Loc{0, 0}, Loc{1, 0})
+	assert.Equal(t, "one\n", string(b.Bytes()))
+	assert.True(t, b.Modified())
+}
+
+func TestNormalizeEndings(t *testing.T) {
+	b := NewBufferFromString("one\r\ntwo\nthree\r\nfour\rfive", "", BTDefault)
+	defer b.Close()
+	assert.Equal(t, "mixed", b.Settings["fileformat"])
+	assert.Error(t, b.SetOption("fileformat", "mixed"))
+
+	assert.Equal(t, 2, b.NormalizeEndings())
+	assert.Equal(t, "dos", b.Settings["fileformat"])
+	assert.Equal(t, "one\r\ntwo\r\nthree\r\nfour\r\nfive", string(b.Bytes()))
+
+	// the line endings are undone at once
+	b.UndoOneEvent()
+	assert.Equal(t, "mixed", b.Settings["fileformat"])
+	assert.Equal(t, "one\r\ntwo\nthree\r\nfour\rfive", string(b.Bytes()))
+}
+
 const maxLineLength = 200
 
 var alphabet = []rune(" abcdeäم📚")
@@ -192,7 +304,7 @@ func benchEdit(testingB *testing.B, nLines, nCursors int) {
 			b.Insert(cursor.Loc, op.text[0])
 		}
 
-		for b.UndoStack.Peek() != nil {
+		for b.CanUndo() {
 			b.UndoOneEvent()
 		}
 	}
@@ -0,0 +1,46 @@
+package buffer
+
+// maxChanges is the maximum number of locations kept in the change list
+const maxChanges = 100
+
+// addChange adds the location of a new edit to the change list. An edit on
+// the same line as the previous one replaces it, so that typing a word
+// only adds one location.
+func (eh *EventHandler) addChange(loc Loc) {
+	if n := len(eh.changes); n > 0 && eh.changes[n-1].Y == loc.Y {
+		eh.changes[n-1] = loc
+	} else {
+		eh.changes = append(eh.changes, loc)
+		if len(eh.changes) > maxChanges {
+			eh.changes = eh.changes[len(eh.changes)-maxChanges:]
+		}
+	}
+	eh.changeIdx = len(eh.changes)
+}
+
+// Changes returns the change list, i.e. the locations of the last edits,
+// the most recent last
+func (eh *EventHandler) Changes() []Loc {
+	return eh.changes
+}
+
+// PrevChange returns the location of the edit before the last one moved to
+// in the change list, starting from the most recent edit. It returns false
+// if there is no older edit.
+func (eh *EventHandler) PrevChange() (Loc, bool) {
+	if eh.changeIdx <= 0 {
+		return Loc{}, false
+	}
+	eh.changeIdx--
+	return clamp(eh.changes[eh.changeIdx], eh.buf.LineArray), true
+}
+
+// NextChange is the opposite of PrevChange: it returns the location of the
+// edit after the last one moved to in the change list
+func (eh *EventHandler) NextChange() (Loc, bool) {
+	if eh.changeIdx >= len(eh.changes)-1 {
+		return Loc{}, false
+	}
+	eh.changeIdx++
+	return clamp(eh.changes[eh.changeIdx], eh.buf.LineArray), true
+}
@@ -0,0 +1,33 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangeList(t *testing.T) {
+	b := NewBufferFromString("one\ntwo\nthree\nfour", "", BTDefault)
+	defer b.Close()
+
+	b.Insert(Loc{3, 0}, "!")
+	b.Insert(Loc{4, 0}, "!")
+	b.Insert(Loc{0, 3}, "x")
+	b.Insert(Loc{0, 1}, "new\n")
+	// the edits on the same line count once and follow the inserted lines
+	assert.Equal(t, []Loc{{4, 0}, {0, 4}, {0, 1}}, b.Changes())
+
+	loc, ok := b.PrevChange()
+	assert.True(t, ok)
+	assert.Equal(t, Loc{0, 1}, loc)
+	loc, _ = b.PrevChange()
+	assert.Equal(t, Loc{0, 4}, loc)
+	loc, _ = b.PrevChange()
+	assert.Equal(t, Loc{4, 0}, loc)
+	_, ok = b.PrevChange()
+	assert.False(t, ok)
+
+	loc, ok = b.NextChange()
+	assert.True(t, ok)
+	assert.Equal(t, Loc{0, 4}, loc)
+}
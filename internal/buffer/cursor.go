@@ -1,13 +1,15 @@
 package buffer
 
 import (
+	"strings"
+
 	"github.com/micro-editor/micro/v2/internal/clipboard"
 	"github.com/micro-editor/micro/v2/internal/util"
 )
 
 // InBounds returns whether the given location is a valid character position in the given buffer
 func InBounds(pos Loc, buf *Buffer) bool {
-	if pos.Y < 0 || pos.Y >= len(buf.lines) || pos.X < 0 || pos.X > util.CharacterCount(buf.LineBytes(pos.Y)) {
+	if pos.Y < 0 || pos.Y >= buf.LinesNum() || pos.X < 0 || pos.X > util.CharacterCount(buf.LineBytes(pos.Y)) {
 		return false
 	}
 
@@ -31,6 +33,12 @@ type Cursor struct {
 
 	// The current selection as a range of character numbers (inclusive)
 	CurSelection [2]Loc
+	// BlockMode is true if the selection is a rectangular block rather than
+	// a stream of text. CurSelection then spans the whole block.
+	BlockMode bool
+	// The anchor and the moving corner of the block selection. Their X is a
+	// visual column, which may be past the end of the line.
+	Block [2]Loc
 	// The original selection as a range of character numbers
 	// This is used for line and word selection where it is necessary
 	// to know what the original selection was
@@ -142,28 +150,36 @@ func (c *Cursor) End() {
 }
 
 // CopySelection copies the user's selection to either "primary"
-// or "clipboard"
+// or "clipboard". A block selection is remembered as a block, so that
+// it is pasted as a block.
 func (c *Cursor) CopySelection(target clipboard.Register) {
 	if c.HasSelection() {
 		if target != clipboard.PrimaryReg || c.buf.Settings["useprimary"].(bool) {
-			clipboard.WriteMulti(string(c.GetSelection()), target, c.Num, c.buf.NumCursors())
+			if c.BlockMode {
+				clipboard.WriteBlock(string(c.GetSelection()), target)
+			} else {
+				clipboard.WriteMulti(string(c.GetSelection()), target, c.Num, c.buf.NumCursors())
+			}
 		}
 	}
 }
 
 // ResetSelection resets the user's selection
 func (c *Cursor) ResetSelection() {
+	c.BlockMode = false
 	c.CurSelection[0] = c.buf.Start()
 	c.CurSelection[1] = c.buf.Start()
 }
 
 // SetSelectionStart sets the start of the selection
 func (c *Cursor) SetSelectionStart(pos Loc) {
+	c.BlockMode = false
 	c.CurSelection[0] = pos
 }
 
 // SetSelectionEnd sets the end of the selection
 func (c *Cursor) SetSelectionEnd(pos Loc) {
+	c.BlockMode = false
 	c.CurSelection[1] = pos
 }
 
@@ -174,7 +190,9 @@ func (c *Cursor) HasSelection() bool {
 
 // DeleteSelection deletes the currently selected text
 func (c *Cursor) DeleteSelection() {
-	if c.CurSelection[0].GreaterThan(c.CurSelection[1]) {
+	if c.BlockMode {
+		c.DeleteBlockSelection()
+	} else if c.CurSelection[0].GreaterThan(c.CurSelection[1]) {
 		c.buf.Remove(c.CurSelection[1], c.CurSelection[0])
 		c.Loc = c.CurSelection[1]
 	} else if !c.HasSelection() {
@@ -200,8 +218,12 @@ func (c *Cursor) Deselect(start bool) {
 	}
 }
 
-// GetSelection returns the cursor's selection
+// GetSelection returns the cursor's selection. The lines of a block
+// selection are separated by newlines.
 func (c *Cursor) GetSelection() []byte {
+	if c.BlockMode {
+		return []byte(strings.Join(c.GetBlockSelection(), "\n"))
+	}
 	if InBounds(c.CurSelection[0], c.buf) && InBounds(c.CurSelection[1], c.buf) {
 		if c.CurSelection[0].GreaterThan(c.CurSelection[1]) {
 			return c.buf.Substr(c.CurSelection[1], c.CurSelection[0])
@@ -216,7 +238,7 @@ func (c *Cursor) SelectLine() {
 	c.Start()
 	c.SetSelectionStart(c.Loc)
 	c.End()
-	if len(c.buf.lines)-1 > c.Y {
+	if c.buf.LinesNum()-1 > c.Y {
 		c.SetSelectionEnd(c.Loc.Move(1, c.buf))
 	} else {
 		c.SetSelectionEnd(c.Loc)
@@ -245,12 +267,8 @@ func (c *Cursor) AddLineToSelection() {
 
 // UpN moves the cursor up N lines (if possible)
 func (c *Cursor) UpN(amount int) {
-	proposedY := c.Y - amount
-	if proposedY < 0 {
-		proposedY = 0
-	} else if proposedY >= len(c.buf.lines) {
-		proposedY = len(c.buf.lines) - 1
-	}
+	// lines hidden by closed folds are skipped
+	proposedY := c.buf.MoveLines(c.Y, -amount)
 
 	bytes := c.buf.LineBytes(proposedY)
 	c.X = c.GetCharPosInLine(bytes, c.LastVisualX)
@@ -306,9 +324,12 @@ func (c *Cursor) Right() {
 	}
 	if c.X < util.CharacterCount(c.buf.LineBytes(c.Y)) {
 		c.X++
-	} else {
+	} else if c.buf.MoveLines(c.Y, 1) != c.Y {
 		c.Down()
 		c.Start()
+	} else {
+		// the rest of the buffer is hidden by a closed fold
+		return
 	}
 	c.StoreVisualX()
 }
@@ -319,8 +340,8 @@ func (c *Cursor) Right() {
 func (c *Cursor) Relocate() {
 	if c.Y < 0 {
 		c.Y = 0
-	} else if c.Y >= len(c.buf.lines) {
-		c.Y = len(c.buf.lines) - 1
+	} else if c.Y >= c.buf.LinesNum() {
+		c.Y = c.buf.LinesNum() - 1
 	}
 
 	if c.X < 0 {
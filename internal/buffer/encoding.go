@@ -0,0 +1,168 @@
+package buffer
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/micro-editor/micro/v2/internal/config"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/encoding/unicode/utf32"
+	"golang.org/x/text/transform"
+)
+
+// encodingSniffLen is the number of bytes at the start of a file from which
+// its encoding is guessed
+const encodingSniffLen = 64 * 1024
+
+// unicodeEncodings are the encodings which may be detected from the byte
+// order mark at the start of a file. The ones of UTF-32 come first since the
+// byte order mark of UTF-32LE starts with the one of UTF-16LE.
+var unicodeEncodings = []struct {
+	name string
+	enc  encoding.Encoding
+	bom  []byte
+}{
+	{"utf-32le", utf32.UTF32(utf32.LittleEndian, utf32.IgnoreBOM), []byte{0xff, 0xfe, 0, 0}},
+	{"utf-32be", utf32.UTF32(utf32.BigEndian, utf32.IgnoreBOM), []byte{0, 0, 0xfe, 0xff}},
+	{"utf-8", unicode.UTF8, []byte{0xef, 0xbb, 0xbf}},
+	{"utf-16le", unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), []byte{0xff, 0xfe}},
+	{"utf-16be", unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), []byte{0xfe, 0xff}},
+}
+
+// bomOf returns the byte order mark of the given encoding, or nil if it has
+// none
+func bomOf(enc encoding.Encoding) []byte {
+	for _, u := range unicodeEncodings {
+		if enc == u.enc {
+			return u.bom
+		}
+	}
+	return nil
+}
+
+// guessEncoding returns the most likely encoding of a text without a byte
+// order mark from its first bytes: UTF-16 if every other byte is zero,
+// UTF-8 if the text is valid UTF-8 and a legacy single-byte encoding
+// otherwise
+func guessEncoding(head []byte) string {
+	var zeros [2]int
+	// UTF-16 text does not contain NUL characters, binary files do
+	nuls := 0
+	for i := 0; i+1 < len(head); i += 2 {
+		if head[i] == 0 {
+			zeros[0]++
+		}
+		if head[i+1] == 0 {
+			zeros[1]++
+		}
+		if head[i] == 0 && head[i+1] == 0 {
+			nuls++
+		}
+	}
+	pairs := len(head) / 2
+	if nuls == 0 && zeros[1] > pairs/4 && zeros[0]*16 < zeros[1] {
+		return "utf-16le"
+	}
+	if nuls == 0 && zeros[0] > pairs/4 && zeros[1]*16 < zeros[0] {
+		return "utf-16be"
+	}
+
+	if validUTF8Prefix(head) {
+		return "utf-8"
+	}
+
+	// Cyrillic text is mostly made of letters above 0x7f, while Western
+	// European text only has a few accented letters between ASCII ones
+	high, runs := 0, 0
+	for i, c := range head {
+		if c >= 0x80 {
+			high++
+			if i > 0 && head[i-1] >= 0x80 {
+				runs++
+			}
+		}
+	}
+	if runs*2 > high {
+		return "windows-1251"
+	}
+	return "windows-1252"
+}
+
+// validUTF8Prefix returns true if the given bytes are valid UTF-8, except
+// for an incomplete character at the end, which may have been cut
+func validUTF8Prefix(head []byte) bool {
+	for len(head) > 0 {
+		r, size := utf8.DecodeRune(head)
+		if r == utf8.RuneError && size == 1 {
+			return !utf8.FullRune(head)
+		}
+		head = head[size:]
+	}
+	return true
+}
+
+// detectEncoding sets the encoding of the buffer from the byte order mark
+// of its file or, if the encoding option is auto, from the first bytes of
+// the file. The file is not consumed.
+func (b *SharedBuffer) detectEncoding(r *bufio.Reader) {
+	head, _ := r.Peek(encodingSniffLen)
+
+	name := b.Settings["encoding"].(string)
+	detected := ""
+	for _, u := range unicodeEncodings {
+		if bytes.HasPrefix(head, u.bom) {
+			detected = u.name
+			break
+		}
+	}
+	if detected == "" && name == "auto" {
+		detected = guessEncoding(head)
+	}
+	if detected != "" && detected != name {
+		name = detected
+		b.Settings["encoding"] = name
+		// the encoding of the file is kept when the settings are reloaded
+		// or the option is set globally
+		b.LocalSettings["encoding"] = true
+	}
+
+	enc, err := config.GetEncoding(name)
+	if err != nil {
+		enc = unicode.UTF8
+		b.Settings["encoding"] = "utf-8"
+	}
+	b.encoding = enc
+}
+
+// redetectEncoding sets the encoding of the buffer to the one detected from
+// its file on disk, or from an empty file if it cannot be read, as when the
+// file is opened with the encoding option set to auto. The text of the
+// buffer is not decoded again.
+func (b *SharedBuffer) redetectEncoding() {
+	var r io.Reader = strings.NewReader("")
+	if f, err := os.Open(b.Path); err == nil {
+		defer f.Close()
+		r = f
+	}
+	b.Settings["encoding"] = "auto"
+	b.detectEncoding(bufio.NewReaderSize(r, encodingSniffLen))
+}
+
+// decodeFile returns a reader of the text of a file in the encoding of the
+// buffer, without its byte order mark, and whether the file has one
+func (b *SharedBuffer) decodeFile(r io.Reader) (io.Reader, bool) {
+	br := bufio.NewReaderSize(r, encodingSniffLen)
+	hasBOM := false
+	if bom := bomOf(b.encoding); bom != nil {
+		if head, _ := br.Peek(len(bom)); bytes.Equal(head, bom) {
+			br.Discard(len(bom))
+			hasBOM = true
+		}
+	}
+	return transform.NewReader(br, b.encoding.NewDecoder()), hasBOM
+}
@@ -0,0 +1,56 @@
+package buffer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/micro-editor/micro/v2/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGuessEncoding(t *testing.T) {
+	assert.Equal(t, "utf-8", guessEncoding([]byte("plain text\n")))
+	assert.Equal(t, "utf-8", guessEncoding([]byte("caf\xc3\xa9\n")))
+	// the sample may end in the middle of a character
+	assert.Equal(t, "utf-8", guessEncoding([]byte("caf\xc3")))
+	assert.Equal(t, "utf-16le", guessEncoding([]byte("h\x00i\x00\n\x00")))
+	assert.Equal(t, "utf-16be", guessEncoding([]byte("\x00h\x00i\x00\n")))
+	assert.Equal(t, "windows-1252", guessEncoding([]byte("caf\xe9 cr\xe8me\n")))
+	assert.Equal(t, "windows-1251", guessEncoding([]byte("\xef\xf0\xe8\xe2\xe5\xf2 \xec\xe8\xf0\n")))
+}
+
+func TestEncodingBOM(t *testing.T) {
+	defer func(dir string) {
+		config.ConfigDir = dir
+	}(config.ConfigDir)
+	config.ConfigDir = t.TempDir()
+
+	path := filepath.Join(t.TempDir(), "file.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("\xff\xfeh\x00i\x00\n\x00"), 0644))
+
+	b, err := NewBufferFromFile(path, BTDefault)
+	assert.NoError(t, err)
+	defer b.Close()
+	assert.Equal(t, "utf-16le", b.Settings["encoding"])
+	assert.Equal(t, "hi\n", string(b.Bytes()))
+
+	// the byte order mark is kept
+	b.Insert(Loc{2, 0}, "!")
+	assert.NoError(t, b.Save())
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "\xff\xfeh\x00i\x00!\x00\n\x00", string(data))
+
+	// a file without a byte order mark is read with the option
+	assert.NoError(t, os.WriteFile(path, []byte("caf\xe9\n"), 0644))
+	assert.NoError(t, b.SetOptionNative("encoding", "windows-1252"))
+	assert.NoError(t, b.ReOpen())
+	assert.Equal(t, "café\n", string(b.Bytes()))
+
+	// auto detects the encoding of the file again
+	assert.NoError(t, b.SetOptionNative("encoding", "utf-8"))
+	assert.NoError(t, b.SetOptionNative("encoding", "auto"))
+	assert.Equal(t, "windows-1252", b.Settings["encoding"])
+	assert.Equal(t, "café\n", string(b.Bytes()))
+}
@@ -39,6 +39,10 @@ type Delta struct {
 	Text  []byte
 	Start Loc
 	End   Loc
+	// Endings are the line endings of the lines ended by the newlines of
+	// Text, FFAuto being the line ending of the file format. They are nil
+	// if every line has the line ending of the file format.
+	Endings []FileFormat
 }
 
 // DoTextEvent runs a text event
@@ -75,29 +79,30 @@ func (eh *EventHandler) DoTextEvent(t *TextEvent, useUndo bool) {
 	}
 	end := t.Deltas[0].End
 
-	for _, c := range eh.cursors {
-		move := func(loc Loc) Loc {
-			if t.EventType == TextEventInsert {
-				if start.Y != loc.Y && loc.GreaterThan(start) {
-					loc.Y += end.Y - start.Y
-				} else if loc.Y == start.Y && loc.GreaterEqual(start) {
-					loc.Y += end.Y - start.Y
-					if lastnl >= 0 {
-						loc.X += textX - start.X
-					} else {
-						loc.X += textX
-					}
-				}
-				return loc
-			} else {
-				if loc.Y != end.Y && loc.GreaterThan(end) {
-					loc.Y -= end.Y - start.Y
-				} else if loc.Y == end.Y && loc.GreaterEqual(end) {
-					loc = loc.MoveLA(-DiffLA(start, end, eh.buf.LineArray), eh.buf.LineArray)
+	move := func(loc Loc) Loc {
+		if t.EventType == TextEventInsert {
+			if start.Y != loc.Y && loc.GreaterThan(start) {
+				loc.Y += end.Y - start.Y
+			} else if loc.Y == start.Y && loc.GreaterEqual(start) {
+				loc.Y += end.Y - start.Y
+				if lastnl >= 0 {
+					loc.X += textX - start.X
+				} else {
+					loc.X += textX
 				}
-				return loc
 			}
+			return loc
+		} else {
+			if loc.Y != end.Y && loc.GreaterThan(end) {
+				loc.Y -= end.Y - start.Y
+			} else if loc.Y == end.Y && loc.GreaterEqual(end) {
+				loc = loc.MoveLA(-DiffLA(start, end, eh.buf.LineArray), eh.buf.LineArray)
+			}
+			return loc
 		}
+	}
+
+	for _, c := range eh.cursors {
 		c.Loc = move(c.Loc)
 		c.CurSelection[0] = move(c.CurSelection[0])
 		c.CurSelection[1] = move(c.CurSelection[1])
@@ -106,9 +111,13 @@ func (eh *EventHandler) DoTextEvent(t *TextEvent, useUndo bool) {
 		c.Relocate()
 		c.StoreVisualX()
 	}
+	for i, l := range eh.changes {
+		eh.changes[i] = move(l)
+	}
 
 	if useUndo {
 		eh.updateTrailingWs(t)
+		eh.addChange(start)
 	}
 }
 
@@ -116,18 +125,18 @@ func (eh *EventHandler) DoTextEvent(t *TextEvent, useUndo bool) {
 func ExecuteTextEvent(t *TextEvent, buf *SharedBuffer) {
 	if t.EventType == TextEventInsert {
 		for _, d := range t.Deltas {
-			buf.insert(d.Start, d.Text)
+			buf.insert(d.Start, d.Text, d.Endings)
 		}
 	} else if t.EventType == TextEventRemove {
 		for i, d := range t.Deltas {
-			t.Deltas[i].Text = buf.remove(d.Start, d.End)
+			t.Deltas[i].Text, t.Deltas[i].Endings = buf.remove(d.Start, d.End)
 		}
 	} else if t.EventType == TextEventReplace {
 		for i, d := range t.Deltas {
-			t.Deltas[i].Text = buf.remove(d.Start, d.End)
-			buf.insert(d.Start, d.Text)
+			t.Deltas[i].Text, t.Deltas[i].Endings = buf.remove(d.Start, d.End)
+			buf.insert(d.Start, d.Text, d.Endings)
 			t.Deltas[i].Start = d.Start
-			t.Deltas[i].End = Loc{d.Start.X + util.CharacterCount(d.Text), d.Start.Y}
+			t.Deltas[i].End = textEnd(d.Start, d.Text)
 		}
 		for i, j := 0, len(t.Deltas)-1; i < j; i, j = i+1, j-1 {
 			t.Deltas[i], t.Deltas[j] = t.Deltas[j], t.Deltas[i]
@@ -135,6 +144,15 @@ func ExecuteTextEvent(t *TextEvent, buf *SharedBuffer) {
 	}
 }
 
+// textEnd returns the location of the end of the given text if it is
+// inserted at start
+func textEnd(start Loc, text []byte) Loc {
+	if nl := bytes.LastIndexByte(text, '\n'); nl >= 0 {
+		return Loc{util.CharacterCount(text[nl+1:]), start.Y + bytes.Count(text, []byte{'\n'})}
+	}
+	return Loc{start.X + util.CharacterCount(text), start.Y}
+}
+
 // UndoTextEvent undoes a text event
 func (eh *EventHandler) UndoTextEvent(t *TextEvent) {
 	t.EventType = -t.EventType
@@ -143,18 +161,22 @@ func (eh *EventHandler) UndoTextEvent(t *TextEvent) {
 
 // EventHandler executes text manipulations and allows undoing and redoing
 type EventHandler struct {
-	buf       *SharedBuffer
-	cursors   []*Cursor
-	active    int
-	UndoStack *TEStack
-	RedoStack *TEStack
+	buf      *SharedBuffer
+	cursors  []*Cursor
+	active   int
+	UndoTree *UndoTree
+
+	// changes is the change list: the locations of the last edits, the
+	// most recent last, and changeIdx is the position in it of the last
+	// location moved to with PrevChange or NextChange
+	changes   []Loc
+	changeIdx int
 }
 
 // NewEventHandler returns a new EventHandler
 func NewEventHandler(buf *SharedBuffer, cursors []*Cursor) *EventHandler {
 	eh := new(EventHandler)
-	eh.UndoStack = new(TEStack)
-	eh.RedoStack = new(TEStack)
+	eh.UndoTree = NewUndoTree()
 	eh.buf = buf
 	eh.cursors = cursors
 	return eh
@@ -164,9 +186,12 @@ func NewEventHandler(buf *SharedBuffer, cursors []*Cursor) *EventHandler {
 // the buffer equal to that string
 // This means that we can transform the buffer into any string and still preserve undo/redo
 // through insert and delete events
+// The lines of the buffer are compared with the string without their line
+// endings, so its lines should only be separated by '\n'
 func (eh *EventHandler) ApplyDiff(new string) {
 	differ := dmp.New()
-	diff := differ.DiffMain(string(eh.buf.Bytes()), new, false)
+	old := eh.buf.Substr(eh.buf.Start(), eh.buf.End())
+	diff := differ.DiffMain(string(old), new, false)
 	loc := eh.buf.Start()
 	for _, d := range diff {
 		if d.Type == dmp.DiffDelete {
@@ -195,7 +220,7 @@ func (eh *EventHandler) InsertBytes(start Loc, text []byte) {
 	e := &TextEvent{
 		C:         *eh.cursors[eh.active],
 		EventType: TextEventInsert,
-		Deltas:    []Delta{{text, start, Loc{0, 0}}},
+		Deltas:    []Delta{{text, start, Loc{0, 0}, nil}},
 		Time:      time.Now(),
 	}
 	eh.DoTextEvent(e, true)
@@ -211,7 +236,7 @@ func (eh *EventHandler) Remove(start, end Loc) {
 	e := &TextEvent{
 		C:         *eh.cursors[eh.active],
 		EventType: TextEventRemove,
-		Deltas:    []Delta{{[]byte{}, start, end}},
+		Deltas:    []Delta{{[]byte{}, start, end, nil}},
 		Time:      time.Now(),
 	}
 	eh.DoTextEvent(e, true)
@@ -219,6 +244,16 @@ func (eh *EventHandler) Remove(start, end Loc) {
 
 // MultipleReplace creates an multiple insertions executes them
 func (eh *EventHandler) MultipleReplace(deltas []Delta) {
+	if len(deltas) == 0 {
+		return
+	}
+	start := deltas[0].Start
+	for _, d := range deltas {
+		if d.Start.LessThan(start) {
+			start = d.Start
+		}
+	}
+
 	e := &TextEvent{
 		C:         *eh.cursors[eh.active],
 		EventType: TextEventReplace,
@@ -226,6 +261,7 @@ func (eh *EventHandler) MultipleReplace(deltas []Delta) {
 		Time:      time.Now(),
 	}
 	eh.Execute(e)
+	eh.addChange(start)
 }
 
 // Replace deletes from start to end and replaces it with the given string
@@ -234,12 +270,9 @@ func (eh *EventHandler) Replace(start, end Loc, replace string) {
 	eh.Insert(start, replace)
 }
 
-// Execute a textevent and add it to the undo stack
+// Execute a textevent and add it to the undo tree
 func (eh *EventHandler) Execute(t *TextEvent) {
-	if eh.RedoStack.Len() > 0 {
-		eh.RedoStack = new(TEStack)
-	}
-	eh.UndoStack.Push(t)
+	eh.UndoTree.add(t)
 
 	b, err := config.RunPluginFnBool(nil, "onBeforeTextEvent", luar.New(ulua.L, eh.buf), luar.New(ulua.L, t))
 	if err != nil {
@@ -253,9 +286,34 @@ func (eh *EventHandler) Execute(t *TextEvent) {
 	ExecuteTextEvent(t, eh.buf)
 }
 
-// Undo the first event in the undo stack. Returns false if the stack is empty.
+// undoEvent returns the event that the next undo would undo, or nil if the
+// current state is the root of the undo tree
+func (eh *EventHandler) undoEvent() *TextEvent {
+	return eh.UndoTree.Current().Event
+}
+
+// redoEvent returns the event that the next redo would redo, or nil if the
+// current state has no children
+func (eh *EventHandler) redoEvent() *TextEvent {
+	if n := eh.UndoTree.Node(eh.UndoTree.Current().Redo); n != nil {
+		return n.Event
+	}
+	return nil
+}
+
+// CanUndo returns true if there is an event to undo
+func (eh *EventHandler) CanUndo() bool {
+	return eh.undoEvent() != nil
+}
+
+// CanRedo returns true if there is an event to redo
+func (eh *EventHandler) CanRedo() bool {
+	return eh.redoEvent() != nil
+}
+
+// Undo the last group of events. Returns false if there is nothing to undo.
 func (eh *EventHandler) Undo() bool {
-	t := eh.UndoStack.Peek()
+	t := eh.undoEvent()
 	if t == nil {
 		return false
 	}
@@ -264,7 +322,7 @@ func (eh *EventHandler) Undo() bool {
 	endTime := startTime - (startTime % undoThreshold)
 
 	for {
-		t = eh.UndoStack.Peek()
+		t = eh.undoEvent()
 		if t == nil {
 			break
 		}
@@ -281,8 +339,9 @@ func (eh *EventHandler) Undo() bool {
 // UndoOneEvent undoes one event
 func (eh *EventHandler) UndoOneEvent() {
 	// This event should be undone
-	// Pop it off the stack
-	t := eh.UndoStack.Pop()
+	// Move to the parent state
+	n := eh.UndoTree.Current()
+	t := n.Event
 	if t == nil {
 		return
 	}
@@ -296,13 +355,14 @@ func (eh *EventHandler) UndoOneEvent() {
 		eh.cursors[t.C.Num].NewTrailingWsY = t.C.NewTrailingWsY
 	}
 
-	// Push it to the redo stack
-	eh.RedoStack.Push(t)
+	// Remember the branch so that it is redone
+	eh.UndoTree.Cur = n.Parent
+	eh.UndoTree.Current().Redo = n.Seq
 }
 
-// Redo the first event in the redo stack. Returns false if the stack is empty.
+// Redo the next group of events. Returns false if there is nothing to redo.
 func (eh *EventHandler) Redo() bool {
-	t := eh.RedoStack.Peek()
+	t := eh.redoEvent()
 	if t == nil {
 		return false
 	}
@@ -311,7 +371,7 @@ func (eh *EventHandler) Redo() bool {
 	endTime := startTime - (startTime % undoThreshold) + undoThreshold
 
 	for {
-		t = eh.RedoStack.Peek()
+		t = eh.redoEvent()
 		if t == nil {
 			break
 		}
@@ -327,10 +387,11 @@ func (eh *EventHandler) Redo() bool {
 
 // RedoOneEvent redoes one event
 func (eh *EventHandler) RedoOneEvent() {
-	t := eh.RedoStack.Pop()
-	if t == nil {
+	n := eh.UndoTree.Node(eh.UndoTree.Current().Redo)
+	if n == nil {
 		return
 	}
+	t := n.Event
 
 	if t.C.Num >= 0 && t.C.Num < len(eh.cursors) {
 		eh.cursors[t.C.Num].Goto(t.C)
@@ -340,7 +401,52 @@ func (eh *EventHandler) RedoOneEvent() {
 	// Modifies the text event
 	eh.UndoTextEvent(t)
 
-	eh.UndoStack.Push(t)
+	eh.UndoTree.Cur = n.Seq
+}
+
+// GotoState moves the buffer to the state with the given sequence number
+// in the undo tree, undoing and redoing events as needed, possibly across
+// branches. Returns false if there is no such state.
+func (eh *EventHandler) GotoState(seq int) bool {
+	if eh.UndoTree.Node(seq) == nil {
+		return false
+	}
+
+	undo, redo := eh.UndoTree.path(seq)
+	for range undo {
+		eh.UndoOneEvent()
+	}
+	for _, s := range redo {
+		eh.UndoTree.Current().Redo = s
+		eh.RedoOneEvent()
+	}
+	return true
+}
+
+// Earlier moves the buffer the given number of states back in the
+// chronological order of the undo tree
+func (eh *EventHandler) Earlier(steps int) bool {
+	return eh.GotoState(util.Max(eh.UndoTree.Cur-steps, 0))
+}
+
+// Later moves the buffer the given number of states forward in the
+// chronological order of the undo tree
+func (eh *EventHandler) Later(steps int) bool {
+	return eh.GotoState(util.Min(eh.UndoTree.Cur+steps, eh.UndoTree.Len()-1))
+}
+
+// EarlierTime moves the buffer to the state it was in the given duration
+// before the current state was created
+func (eh *EventHandler) EarlierTime(d time.Duration) bool {
+	tm := eh.UndoTree.Current().Time.Add(-d)
+	return eh.GotoState(util.Min(eh.UndoTree.StateAt(tm), eh.UndoTree.Cur))
+}
+
+// LaterTime moves the buffer to the state it was in the given duration
+// after the current state was created
+func (eh *EventHandler) LaterTime(d time.Duration) bool {
+	tm := eh.UndoTree.Current().Time.Add(d)
+	return eh.GotoState(util.Max(eh.UndoTree.StateAt(tm), eh.UndoTree.Cur))
 }
 
 // updateTrailingWs updates the cursor's trailing whitespace status after a text event
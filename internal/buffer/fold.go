@@ -0,0 +1,356 @@
+package buffer
+
+import (
+	"sort"
+
+	"github.com/micro-editor/micro/v2/internal/util"
+	"github.com/micro-editor/micro/v2/pkg/highlight"
+)
+
+// A Fold is a closed fold in the buffer: the line Start stays visible and
+// stands for the whole fold, and the lines after it up to and including End
+// are hidden
+type Fold struct {
+	Start, End int
+}
+
+// Folds returns the closed folds of the buffer, sorted by their position.
+// Closed folds never overlap: closing a fold around other closed folds
+// replaces them.
+func (b *SharedBuffer) Folds() []Fold {
+	return b.folds
+}
+
+// SetFolds replaces the closed folds of the buffer. Folds that are invalid
+// or overlap the previous ones are dropped.
+func (b *SharedBuffer) SetFolds(folds []Fold) {
+	b.folds = b.folds[:0]
+	for _, f := range folds {
+		if f.Start >= 0 && f.End > f.Start && f.End < b.LinesNum() &&
+			(len(b.folds) == 0 || f.Start > b.folds[len(b.folds)-1].End) {
+			b.folds = append(b.folds, f)
+		}
+	}
+}
+
+// findFold returns the index of the first closed fold that ends at or
+// after the given line
+func (b *SharedBuffer) findFold(line int) int {
+	return sort.Search(len(b.folds), func(i int) bool {
+		return b.folds[i].End >= line
+	})
+}
+
+// FoldAt returns the closed fold containing the given line, including its
+// first visible line
+func (b *SharedBuffer) FoldAt(line int) (Fold, bool) {
+	i := b.findFold(line)
+	if i < len(b.folds) && b.folds[i].Start <= line {
+		return b.folds[i], true
+	}
+	return Fold{}, false
+}
+
+// IsHidden returns true if the given line is hidden by a closed fold
+func (b *SharedBuffer) IsHidden(line int) bool {
+	f, ok := b.FoldAt(line)
+	return ok && line > f.Start
+}
+
+// VisibleLine returns the given line if it is visible, or the first line
+// of the closed fold hiding it
+func (b *SharedBuffer) VisibleLine(line int) int {
+	if f, ok := b.FoldAt(line); ok {
+		return f.Start
+	}
+	return line
+}
+
+// MoveLines returns the visible line that is n visible lines after the
+// given one, skipping the lines hidden by closed folds. n can be negative.
+// The result is clamped to the buffer boundaries.
+func (b *SharedBuffer) MoveLines(line, n int) int {
+	if len(b.folds) == 0 {
+		return util.Clamp(line+n, 0, b.LinesNum()-1)
+	}
+
+	line = b.VisibleLine(util.Clamp(line, 0, b.LinesNum()-1))
+	for ; n > 0; n-- {
+		next := line + 1
+		if f, ok := b.FoldAt(line); ok {
+			next = f.End + 1
+		}
+		if next >= b.LinesNum() {
+			break
+		}
+		line = next
+	}
+	for ; n < 0 && line > 0; n++ {
+		line = b.VisibleLine(line - 1)
+	}
+	return line
+}
+
+// VisibleLinesBetween returns the number of visible lines from the line
+// l1 up to, but not including, the line l2. It is negative if l2 is
+// before l1.
+func (b *SharedBuffer) VisibleLinesBetween(l1, l2 int) int {
+	if l1 > l2 {
+		return -b.VisibleLinesBetween(l2, l1)
+	}
+
+	n := l2 - l1
+	for i := b.findFold(l1); i < len(b.folds) && b.folds[i].Start < l2; i++ {
+		f := b.folds[i]
+		n -= util.Max(util.Min(f.End, l2-1)-util.Max(f.Start+1, l1)+1, 0)
+	}
+	return n
+}
+
+// VisibleLinesNum returns the number of lines that are not hidden by
+// closed folds
+func (b *SharedBuffer) VisibleLinesNum() int {
+	return b.VisibleLinesBetween(0, b.LinesNum())
+}
+
+// AddFold closes a fold from the line start to the line end. The closed
+// folds inside it are replaced by it. It does nothing if the fold would
+// be empty or if it is already hidden by another fold.
+func (b *SharedBuffer) AddFold(start, end int) {
+	end = util.Min(end, b.LinesNum()-1)
+	if start < 0 || end <= start || b.IsHidden(start) {
+		return
+	}
+
+	i := b.findFold(start)
+	j := i
+	for j < len(b.folds) && b.folds[j].Start <= end {
+		end = util.Max(end, b.folds[j].End)
+		j++
+	}
+
+	folds := append([]Fold{}, b.folds[:i]...)
+	folds = append(folds, Fold{start, end})
+	b.folds = append(folds, b.folds[j:]...)
+}
+
+// RemoveFold opens the closed fold containing the given line. It returns
+// false if there is no such fold.
+func (b *SharedBuffer) RemoveFold(line int) bool {
+	i := b.findFold(line)
+	if i < len(b.folds) && b.folds[i].Start <= line {
+		b.folds = append(b.folds[:i], b.folds[i+1:]...)
+		return true
+	}
+	return false
+}
+
+// ClearFolds opens all the closed folds
+func (b *SharedBuffer) ClearFolds() {
+	b.folds = nil
+}
+
+// insertFoldLines updates the closed folds after n lines have been
+// inserted after the given line
+func (b *SharedBuffer) insertFoldLines(line, n int) {
+	if n == 0 {
+		return
+	}
+	for i := b.findFold(line); i < len(b.folds); i++ {
+		if b.folds[i].Start > line {
+			b.folds[i].Start += n
+		}
+		b.folds[i].End += n
+	}
+}
+
+// removeFoldLines updates the closed folds after the lines from start to
+// end have been joined into the line start. A fold that was only partly
+// removed is opened.
+func (b *SharedBuffer) removeFoldLines(start, end int) {
+	n := end - start
+	if n == 0 {
+		return
+	}
+
+	folds := b.folds[:0]
+	for _, f := range b.folds {
+		switch {
+		case f.End < start:
+		case f.Start >= end:
+			f.Start -= n
+			f.End -= n
+		case f.Start <= start && f.End >= end:
+			f.End -= n
+			if f.End <= f.Start {
+				continue
+			}
+		default:
+			continue
+		}
+		folds = append(folds, f)
+	}
+	b.folds = folds
+}
+
+// lineIndent returns the width of the indentation of the given line, or
+// -1 if the line is blank
+func (b *Buffer) lineIndent(line int) int {
+	l := b.LineBytes(line)
+	ws := util.GetLeadingWhitespace(l)
+	if len(ws) == len(l) {
+		return -1
+	}
+	return util.StringWidth(ws, util.CharacterCount(ws), util.IntOpt(b.Settings["tabsize"]))
+}
+
+// indentFoldRange returns the end of the fold starting at the given line
+// computed from the indentation: the fold contains all the following lines
+// that are more indented, and the blank lines between them
+func (b *Buffer) indentFoldRange(line int) (int, bool) {
+	ind := b.lineIndent(line)
+	if ind < 0 {
+		return 0, false
+	}
+
+	end := line
+	for l := line + 1; l < b.LinesNum(); l++ {
+		li := b.lineIndent(l)
+		if li < 0 {
+			continue
+		}
+		if li <= ind {
+			break
+		}
+		end = l
+	}
+	return end, end > line
+}
+
+// regionFoldRange returns the end of the fold of the syntax region r
+// starting at the given line, i.e. the line where the region ends
+func (b *Buffer) regionFoldRange(line int, r highlight.State) (int, bool) {
+	end := b.LinesNum() - 1
+	for l := line + 1; l < b.LinesNum(); l++ {
+		if !highlight.InRegion(b.State(l), r) {
+			end = l
+			break
+		}
+	}
+	return end, end > line
+}
+
+// syntaxFoldRange returns the fold of the syntax region containing the
+// given line, if the line is in a region spanning multiple lines
+func (b *Buffer) syntaxFoldRange(line int) (int, int, bool) {
+	if !b.Settings["syntax"].(bool) || b.SyntaxDef == nil {
+		return 0, 0, false
+	}
+
+	var prev highlight.State
+	if line > 0 {
+		prev = b.State(line - 1)
+	}
+
+	// a region starting on this line
+	if r := b.State(line); r != nil && !highlight.InRegion(prev, r) {
+		if end, ok := b.regionFoldRange(line, r); ok {
+			return line, end, true
+		}
+	}
+
+	// a region this line is inside of
+	if prev != nil {
+		start := line - 1
+		for start > 0 && highlight.InRegion(b.State(start-1), prev) {
+			start--
+		}
+		if end, ok := b.regionFoldRange(start, prev); ok {
+			return start, end, true
+		}
+	}
+	return 0, 0, false
+}
+
+// FoldRange returns the innermost fold containing the given line that can
+// be closed, computed according to the `foldmethod` option. The `syntax`
+// method uses the multi-line regions of the syntax highlighting and falls
+// back to the indentation outside of them.
+func (b *Buffer) FoldRange(line int) (Fold, bool) {
+	if b.Settings["foldmethod"].(string) == "syntax" {
+		if start, end, ok := b.syntaxFoldRange(line); ok {
+			return Fold{start, end}, true
+		}
+	}
+
+	if end, ok := b.indentFoldRange(line); ok {
+		return Fold{line, end}, true
+	}
+
+	// look for the enclosing block, i.e. the closest line above that is
+	// less indented
+	minIndent := b.lineIndent(line)
+	for l := line - 1; l >= 0 && minIndent != 0; l-- {
+		ind := b.lineIndent(l)
+		if ind < 0 || (minIndent >= 0 && ind >= minIndent) {
+			continue
+		}
+		if end, ok := b.indentFoldRange(l); ok && end >= line {
+			return Fold{l, end}, true
+		}
+		minIndent = ind
+	}
+	return Fold{}, false
+}
+
+// Fold closes the innermost fold containing the given line. It returns
+// false if there is no fold to close.
+func (b *Buffer) Fold(line int) bool {
+	f, ok := b.FoldRange(line)
+	if !ok {
+		return false
+	}
+	b.AddFold(f.Start, f.End)
+	return true
+}
+
+// Unfold opens the closed fold containing the given line. It returns false
+// if there is no such fold.
+func (b *Buffer) Unfold(line int) bool {
+	return b.RemoveFold(line)
+}
+
+// ToggleFold opens the closed fold containing the given line, or closes
+// the innermost fold containing it if there is none
+func (b *Buffer) ToggleFold(line int) bool {
+	if b.Unfold(line) {
+		return true
+	}
+	return b.Fold(line)
+}
+
+// FoldAll closes all the outermost folds of the buffer
+func (b *Buffer) FoldAll() {
+	b.ClearFolds()
+	for l := 0; l < b.LinesNum(); l++ {
+		var end int
+		ok := false
+		if b.Settings["foldmethod"].(string) == "syntax" {
+			var start int
+			start, end, ok = b.syntaxFoldRange(l)
+			ok = ok && start == l
+		}
+		if !ok {
+			end, ok = b.indentFoldRange(l)
+		}
+		if ok {
+			b.AddFold(l, end)
+			l = end
+		}
+	}
+}
+
+// UnfoldAll opens all the closed folds of the buffer
+func (b *Buffer) UnfoldAll() {
+	b.ClearFolds()
+}
@@ -0,0 +1,99 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const foldText = `func a() {
+	if x {
+		y()
+	}
+
+	z()
+}
+
+func b() {
+	w()
+}`
+
+func TestFoldRange(t *testing.T) {
+	b := NewBufferFromString(foldText, "", BTDefault)
+	defer b.Close()
+
+	f, ok := b.FoldRange(0)
+	assert.True(t, ok)
+	assert.Equal(t, Fold{0, 5}, f)
+
+	f, ok = b.FoldRange(2)
+	assert.True(t, ok)
+	assert.Equal(t, Fold{1, 2}, f)
+
+	// a blank line belongs to the enclosing block
+	f, ok = b.FoldRange(4)
+	assert.True(t, ok)
+	assert.Equal(t, Fold{0, 5}, f)
+
+	_, ok = b.FoldRange(7)
+	assert.False(t, ok)
+
+	b.FoldAll()
+	assert.Equal(t, []Fold{{0, 5}, {8, 9}}, b.Folds())
+	b.UnfoldAll()
+	assert.Empty(t, b.Folds())
+}
+
+func TestFoldLines(t *testing.T) {
+	b := NewBufferFromString(foldText, "", BTDefault)
+	defer b.Close()
+
+	b.AddFold(1, 3)
+	b.AddFold(8, 10)
+	assert.True(t, b.IsHidden(2))
+	assert.False(t, b.IsHidden(1))
+	assert.Equal(t, 1, b.VisibleLine(3))
+
+	assert.Equal(t, 4, b.MoveLines(1, 1))
+	assert.Equal(t, 1, b.MoveLines(4, -1))
+	assert.Equal(t, 7, b.MoveLines(0, 5))
+	assert.Equal(t, 8, b.MoveLines(0, 100))
+	assert.Equal(t, 0, b.MoveLines(8, -100))
+
+	assert.Equal(t, 2, b.VisibleLinesBetween(0, 4))
+	assert.Equal(t, -2, b.VisibleLinesBetween(4, 0))
+	assert.Equal(t, 7, b.VisibleLinesNum())
+
+	// closing a fold around closed folds replaces them
+	b.AddFold(0, 6)
+	assert.Equal(t, []Fold{{0, 6}, {8, 10}}, b.Folds())
+	assert.True(t, b.Unfold(3))
+	assert.Equal(t, []Fold{{8, 10}}, b.Folds())
+
+	// there is no line to move right to after the last fold
+	c := b.GetActiveCursor()
+	c.GotoLoc(Loc{10, 8})
+	c.Right()
+	assert.Equal(t, Loc{10, 8}, c.Loc)
+}
+
+func TestFoldEdits(t *testing.T) {
+	b := NewBufferFromString(foldText, "", BTDefault)
+	defer b.Close()
+
+	b.AddFold(1, 3)
+	b.AddFold(8, 9)
+
+	b.Insert(Loc{0, 5}, "\n\n")
+	assert.Equal(t, []Fold{{1, 3}, {10, 11}}, b.Folds())
+
+	b.Insert(Loc{0, 2}, "\n")
+	assert.Equal(t, []Fold{{1, 4}, {11, 12}}, b.Folds())
+
+	b.Remove(Loc{0, 5}, Loc{0, 8})
+	assert.Equal(t, []Fold{{1, 4}, {8, 9}}, b.Folds())
+
+	// a fold that is only partly removed is opened
+	b.Remove(Loc{0, 4}, Loc{0, 6})
+	assert.Equal(t, []Fold{{6, 7}}, b.Folds())
+}
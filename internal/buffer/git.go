@@ -0,0 +1,252 @@
+package buffer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/micro-editor/micro/v2/internal/git"
+	"github.com/micro-editor/micro/v2/internal/util"
+	"github.com/micro-editor/micro/v2/internal/watcher"
+	"golang.org/x/text/transform"
+)
+
+// BlameWidth is the width of the annotations shown by ShowBlame
+const BlameWidth = 35
+
+// gitDirectory returns the git directory of the repository of the file of the
+// buffer, or an empty string if it is not in a repository. The index of the
+// repository is watched to update the buffer when it changes.
+func (b *SharedBuffer) gitDirectory() string {
+	if b.gitChecked || b.Path == "" || b.Type.Kind != BTDefault.Kind {
+		return b.gitDir
+	}
+	b.gitChecked = true
+	dir, err := git.GitDir(b.AbsPath)
+	if err != nil {
+		return ""
+	}
+	b.gitDir = dir
+	watcher.Watch(git.IndexPath(dir))
+	return dir
+}
+
+// GitIndexPath returns the index of the repository of the file of the
+// buffer, or an empty string if it is not in a known repository
+func (b *SharedBuffer) GitIndexPath() string {
+	if b.gitDir == "" {
+		return ""
+	}
+	return git.IndexPath(b.gitDir)
+}
+
+// unwatchGit forgets the repository of the file of the buffer
+func (b *SharedBuffer) unwatchGit() {
+	if b.gitDir != "" {
+		watcher.Unwatch(git.IndexPath(b.gitDir))
+	}
+	b.gitDir = ""
+	b.gitChecked = false
+}
+
+// decode converts the content of a file in the encoding of the buffer to
+// UTF-8, without its byte order mark
+func (b *SharedBuffer) decode(text []byte) ([]byte, error) {
+	r, _ := b.decodeFile(bytes.NewReader(text))
+	return io.ReadAll(r)
+}
+
+// encode converts a text in UTF-8 to the content of the file of the buffer
+// in its encoding, with its byte order mark if it has one
+func (b *SharedBuffer) encode(text []byte) ([]byte, error) {
+	text, _, err := transform.Bytes(b.encoding.NewEncoder(), text)
+	if err != nil || !b.bom {
+		return text, err
+	}
+	return append(append([]byte{}, bomOf(b.encoding)...), text...), nil
+}
+
+// UpdateGitDiffBase sets the version of the file in its git repository
+// chosen by the gitdiffbase option, the last commit or the index, as the
+// diff base of the buffer. A file which is not in a repository is compared
+// with its text when it was opened.
+func (b *Buffer) UpdateGitDiffBase() {
+	mode := b.Settings["gitdiffbase"].(string)
+	if mode == "off" || !b.Settings["diffgutter"].(bool) || b.Path == "" ||
+		b.Type.Kind != BTDefault.Kind || b.EventHandler == nil {
+		return
+	}
+
+	var base []byte
+	err := errors.New("not in a repository")
+	if b.gitDirectory() != "" {
+		if mode == "index" {
+			base, err = git.Staged(b.AbsPath)
+		} else {
+			base, err = git.Head(b.AbsPath)
+		}
+	}
+	if err == nil {
+		base, err = b.decode(base)
+	}
+	if err != nil {
+		if b.diffBase == nil {
+			b.SetDiffBase(b.Bytes())
+		}
+		return
+	}
+	b.SetDiffBase(base)
+}
+
+// hunkAtLine returns the hunk changing the given line, or the hunk removing
+// the lines before it
+func hunkAtLine(hunks []lineHunk, line int) (lineHunk, bool) {
+	for _, h := range hunks {
+		if line >= h.start && (line < h.end || line == h.start) {
+			return h, true
+		}
+	}
+	return lineHunk{}, false
+}
+
+// StageHunk adds the change of the buffer at the given line to the index of
+// its git repository, leaving the other changes unstaged
+func (b *Buffer) StageHunk(line int) error {
+	if b.gitDirectory() == "" {
+		return errors.New(b.GetName() + " is not in a git repository")
+	}
+	// a file which is not in the index yet is staged from an empty file
+	staged, err := git.Staged(b.AbsPath)
+	if err == nil {
+		staged, err = b.decode(staged)
+		if err != nil {
+			return err
+		}
+	}
+	index, ours := string(staged), string(b.Bytes())
+
+	h, ok := hunkAtLine(diffLines(index, ours), line)
+	if !ok {
+		return errors.New("No unstaged change at this line")
+	}
+	indexLines, ourLines := splitLines(index), splitLines(ours)
+	text := strings.Join(indexLines[:h.baseStart], "") +
+		strings.Join(ourLines[h.start:h.end], "") +
+		strings.Join(indexLines[h.baseEnd:], "")
+
+	encoded, err := b.encode([]byte(text))
+	if err != nil {
+		return err
+	}
+	if err := git.Stage(b.AbsPath, encoded); err != nil {
+		return err
+	}
+	if b.Settings["gitdiffbase"] == "index" {
+		b.UpdateGitDiffBase()
+	}
+	return nil
+}
+
+// RevertHunk replaces the change of the buffer at the given line, as shown
+// by the diff gutter, with the text of the diff base, as a single undoable
+// edit
+func (b *Buffer) RevertHunk(line int) error {
+	if b.diffBase == nil {
+		return errors.New("The buffer has no diff base")
+	}
+	base := string(b.diffBase)
+	h, ok := hunkAtLine(diffLines(base, string(b.Bytes())), line)
+	if !ok {
+		return errors.New("No change at this line")
+	}
+	text := strings.Join(splitLines(base)[h.baseStart:h.baseEnd], "")
+	b.MultipleReplace([]Delta{{[]byte(text), b.lineStart(h.start), b.lineStart(h.end), nil}})
+	b.RelocateCursors()
+	return nil
+}
+
+// ShowBlame annotates every line of the buffer with the commit which last
+// changed it, its author and its date
+func (b *Buffer) ShowBlame() error {
+	if b.gitDirectory() == "" {
+		return errors.New(b.GetName() + " is not in a git repository")
+	}
+	text, err := b.encode(b.Bytes())
+	if err != nil {
+		return err
+	}
+	lines, err := git.Blame(b.AbsPath, text)
+	if err != nil {
+		return err
+	}
+
+	b.blame = make([]string, b.LinesNum())
+	for i := range b.blame {
+		if i < len(lines) {
+			b.blame[i] = formatBlame(lines[i])
+		}
+	}
+	return nil
+}
+
+func formatBlame(l git.BlameLine) string {
+	if !l.Committed() {
+		return fmt.Sprintf("%8s %s", "", "Not committed yet")
+	}
+	author := []rune(l.Author)
+	if len(author) > 15 {
+		author = append(author[:14], '…')
+	}
+	commit := l.Commit[:util.Min(8, len(l.Commit))]
+	return fmt.Sprintf("%s %-15s %s", commit, string(author), l.Time.Format("2006-01-02"))
+}
+
+// HideBlame removes the annotations shown by ShowBlame
+func (b *SharedBuffer) HideBlame() {
+	b.blame = nil
+}
+
+// BlameShown returns true if the lines of the buffer are annotated by
+// ShowBlame
+func (b *SharedBuffer) BlameShown() bool {
+	return b.blame != nil
+}
+
+// BlameAt returns the annotation of the given line, which is empty for the
+// lines added since ShowBlame was called
+func (b *SharedBuffer) BlameAt(line int) string {
+	if line < 0 || line >= len(b.blame) {
+		return ""
+	}
+	return b.blame[line]
+}
+
+// insertBlameLines updates the annotations after n lines have been inserted
+// after the given line
+func (b *SharedBuffer) insertBlameLines(line, n int) {
+	if b.blame == nil || n == 0 || line >= len(b.blame) {
+		return
+	}
+	b.blame = append(b.blame[:line+1], append(make([]string, n), b.blame[line+1:]...)...)
+}
+
+// removeBlameLines updates the annotations after the lines from start to end
+// have been joined into the line start
+func (b *SharedBuffer) removeBlameLines(start, end int) {
+	if b.blame == nil || end <= start || start >= len(b.blame) {
+		return
+	}
+	end = util.Min(end, len(b.blame)-1)
+	b.blame = append(b.blame[:start+1], b.blame[end+1:]...)
+}
+
+// gitSaved updates the diff base and the annotations of the buffer after
+// its file has been saved
+func (b *Buffer) gitSaved() {
+	b.UpdateGitDiffBase()
+	if b.BlameShown() {
+		b.ShowBlame()
+	}
+}
@@ -0,0 +1,57 @@
+package buffer
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/micro-editor/micro/v2/internal/config"
+	"github.com/micro-editor/micro/v2/internal/git"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitHunks(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	defer func(dir string) {
+		config.ConfigDir = dir
+	}(config.ConfigDir)
+	config.ConfigDir = t.TempDir()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("one\ntwo\nthree\n"), 0644))
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "file.txt"},
+		{"-c", "user.name=Tester", "-c", "user.email=tester@example.com", "commit", "-q", "-m", "first"},
+	} {
+		out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+		assert.NoError(t, err, string(out))
+	}
+
+	b, err := NewBufferFromFile(path, BTDefault)
+	assert.NoError(t, err)
+	defer b.Close()
+	b.SetOptionNative("diffgutter", true)
+	assert.Equal(t, "one\ntwo\nthree\n", string(b.diffBase))
+
+	b.Replace(Loc{0, 1}, Loc{3, 1}, "TWO")
+	b.Insert(b.End(), "four\n")
+	b.UpdateDiff()
+	assert.Equal(t, DiffStatus(DSModified), b.DiffStatus(1))
+	assert.Equal(t, DiffStatus(DSAdded), b.DiffStatus(3))
+
+	// only the change at the line is staged
+	assert.NoError(t, b.StageHunk(1))
+	staged, err := git.Staged(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "one\nTWO\nthree\n", string(staged))
+	assert.Error(t, b.StageHunk(1))
+
+	assert.NoError(t, b.RevertHunk(3))
+	assert.Equal(t, "one\nTWO\nthree\n", string(b.Bytes()))
+	assert.Error(t, b.RevertHunk(0))
+}
@@ -0,0 +1,157 @@
+package buffer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/micro-editor/micro/v2/internal/util"
+)
+
+// binaryCheckLen is the number of bytes at the beginning of a file that are
+// checked for a null byte to decide if the file is binary, like git does
+const binaryCheckLen = 8000
+
+// grepMaxSize is the size above which files are not searched, since they
+// are read entirely into memory
+const grepMaxSize = 16 * 1024 * 1024
+
+// A GrepMatch is a match of a search in files
+type GrepMatch struct {
+	Path string
+	// Loc is the location of the start of the match, and End of its end
+	Loc, End Loc
+	// Line is the text of the line where the match starts
+	Line string
+	// Text is the matched text
+	Text string
+	// Replacement is the text replacing the match with GrepReplace
+	Replacement string
+}
+
+// Grep searches r in the files under the given paths, skipping the files
+// ignored by git, the binary files and the files larger than 16 MB. The matches of every file are passed
+// to found, from the goroutine calling Grep. The search stops when ctx is
+// canceled, in which case its error is returned.
+func Grep(ctx context.Context, r *regexp.Regexp, paths []string, found func(path string, matches []GrepMatch)) error {
+	return grep(ctx, r, nil, paths, found)
+}
+
+// GrepReplace is like Grep but also sets the replacement of every match,
+// which is the template replace expanded like with ReplaceRegex, or used
+// as is if captureGroups is false.
+func GrepReplace(ctx context.Context, r *regexp.Regexp, replace []byte, captureGroups bool, paths []string, found func(path string, matches []GrepMatch)) error {
+	if !captureGroups {
+		return grep(ctx, r, func(*regexp.Regexp, []byte, []int) []byte { return replace }, paths, found)
+	}
+	return grep(ctx, r, func(r *regexp.Regexp, data []byte, m []int) []byte {
+		return r.Expand(nil, replace, data, m)
+	}, paths, found)
+}
+
+func grep(ctx context.Context, r *regexp.Regexp, expand expandFunc, paths []string, found func(path string, matches []GrepMatch)) error {
+	// "^" and "$" match at the beginning and end of every line in files
+	r, err := regexp.Compile("(?m)" + r.String())
+	if err != nil {
+		return err
+	}
+
+	for _, root := range paths {
+		var ignore util.GitIgnore
+		ignore.AddParents(root)
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				// skip the files that can't be read
+				return nil
+			}
+
+			if d.IsDir() {
+				if path != root && ignore.Ignored(path, true) {
+					return filepath.SkipDir
+				}
+				ignore.AddDir(path)
+				return nil
+			}
+			if !d.Type().IsRegular() || path != root && ignore.Ignored(path, false) {
+				return nil
+			}
+
+			if matches := grepFile(r, expand, path); len(matches) > 0 {
+				found(path, matches)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// An expandFunc returns the replacement of the match m of r in data
+type expandFunc func(r *regexp.Regexp, data []byte, m []int) []byte
+
+// grepFile returns the matches of r in the given file, or nothing if the
+// file is binary or too large. The replacements of the matches are set with
+// expand if it is not nil.
+func grepFile(r *regexp.Regexp, expand expandFunc, path string) []GrepMatch {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || info.Size() > grepMaxSize {
+		return nil
+	}
+
+	// the beginning of the file is checked before reading the rest
+	br := bufio.NewReaderSize(f, binaryCheckLen)
+	if head, _ := br.Peek(binaryCheckLen); bytes.IndexByte(head, 0) >= 0 {
+		return nil
+	}
+	data, err := io.ReadAll(br)
+	if err != nil {
+		return nil
+	}
+
+	var matches []GrepMatch
+	line, lineStart := 0, 0
+	loc := func(offset int) Loc {
+		line += bytes.Count(data[lineStart:offset], []byte{'\n'})
+		if nl := bytes.LastIndexByte(data[lineStart:offset], '\n'); nl >= 0 {
+			lineStart += nl + 1
+		}
+		return Loc{util.CharacterCount(data[lineStart:offset]), line}
+	}
+
+	for _, m := range r.FindAllSubmatchIndex(data, -1) {
+		if m[0] == m[1] {
+			continue
+		}
+		start := loc(m[0])
+		text := data[lineStart:]
+		if nl := bytes.IndexByte(text, '\n'); nl >= 0 {
+			text = text[:nl]
+		}
+		match := GrepMatch{
+			Path: path,
+			Loc:  start,
+			End:  loc(m[1]),
+			Line: string(bytes.TrimSuffix(text, []byte{'\r'})),
+			Text: string(data[m[0]:m[1]]),
+		}
+		if expand != nil {
+			match.Replacement = string(expand(r, data, m))
+		}
+		matches = append(matches, match)
+	}
+	return matches
+}
@@ -0,0 +1,101 @@
+package buffer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrep(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		".gitignore":       "*.log\nbuild/\n!keep.log\n",
+		"a.txt":            "foo\nbar foo\n",
+		"sub/b.go":         "// föö foo\r\nfoo",
+		"sub/.gitignore":   "/c.txt\n",
+		"sub/c.txt":        "foo",
+		"sub/d/c.txt":      "foo",
+		"x.log":            "foo",
+		"sub/y.log":        "foo",
+		"keep.log":         "foo",
+		"build/e.txt":      "foo",
+		"bin.dat":          "foo\x00",
+		"big.txt":          "foo" + strings.Repeat(" ", grepMaxSize),
+		".git/config":      "foo",
+		"multi/lines.txt":  "foo\nbar",
+		"multi/single.txt": "bar",
+	}
+	for name, text := range files {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(text), 0644))
+	}
+
+	grepIn := func(root, expr string) []GrepMatch {
+		var matches []GrepMatch
+		err := Grep(context.Background(), regexp.MustCompile(expr), []string{root}, func(path string, m []GrepMatch) {
+			matches = append(matches, m...)
+		})
+		assert.NoError(t, err)
+		for i := range matches {
+			matches[i].Path, _ = filepath.Rel(dir, matches[i].Path)
+			matches[i].Path = filepath.ToSlash(matches[i].Path)
+		}
+		sort.SliceStable(matches, func(i, j int) bool {
+			return matches[i].Path < matches[j].Path
+		})
+		return matches
+	}
+	grep := func(expr string) []GrepMatch {
+		return grepIn(dir, expr)
+	}
+
+	assert.Equal(t, []GrepMatch{
+		{"a.txt", Loc{0, 0}, Loc{3, 0}, "foo", "foo", ""},
+		{"a.txt", Loc{4, 1}, Loc{7, 1}, "bar foo", "foo", ""},
+		{"keep.log", Loc{0, 0}, Loc{3, 0}, "foo", "foo", ""},
+		{"multi/lines.txt", Loc{0, 0}, Loc{3, 0}, "foo", "foo", ""},
+		{"sub/b.go", Loc{7, 0}, Loc{10, 0}, "// föö foo", "foo", ""},
+		{"sub/b.go", Loc{0, 1}, Loc{3, 1}, "foo", "foo", ""},
+		{"sub/d/c.txt", Loc{0, 0}, Loc{3, 0}, "foo", "foo", ""},
+	}, grep("foo"))
+
+	assert.Equal(t, []GrepMatch{
+		{"a.txt", Loc{0, 0}, Loc{3, 1}, "foo", "foo\nbar", ""},
+		{"multi/lines.txt", Loc{0, 0}, Loc{3, 1}, "foo", "foo\nbar", ""},
+	}, grep("foo\nbar"))
+	assert.Equal(t, []GrepMatch{
+		{"a.txt", Loc{0, 1}, Loc{3, 1}, "bar foo", "bar", ""},
+		{"multi/lines.txt", Loc{0, 1}, Loc{3, 1}, "bar", "bar", ""},
+		{"multi/single.txt", Loc{0, 0}, Loc{3, 0}, "bar", "bar", ""},
+	}, grep("^bar"))
+
+	// the .gitignore files above a subdirectory of the repository apply
+	assert.Equal(t, []GrepMatch{
+		{"sub/b.go", Loc{7, 0}, Loc{10, 0}, "// föö foo", "foo", ""},
+		{"sub/b.go", Loc{0, 1}, Loc{3, 1}, "foo", "foo", ""},
+		{"sub/d/c.txt", Loc{0, 0}, Loc{3, 0}, "foo", "foo", ""},
+	}, grepIn(filepath.Join(dir, "sub"), "foo"))
+
+	var replaced []GrepMatch
+	err := GrepReplace(context.Background(), regexp.MustCompile(`f(o+)\n`), []byte("${1}x"), true, []string{filepath.Join(dir, "multi")}, func(path string, m []GrepMatch) {
+		replaced = append(replaced, m...)
+	})
+	assert.NoError(t, err)
+	assert.Len(t, replaced, 1)
+	assert.Equal(t, "foo\n", replaced[0].Text)
+	assert.Equal(t, "oox", replaced[0].Replacement)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = Grep(ctx, regexp.MustCompile("foo"), []string{dir}, func(string, []GrepMatch) {
+		t.Error("file searched after cancel")
+	})
+	assert.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,269 @@
+package buffer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/micro-editor/micro/v2/internal/util"
+)
+
+// The text of a hex view shows hexBytesPerLine bytes on each line, like
+// hexdump -C does:
+//
+//	00000000  48 65 6c 6c 6f 2c 20 77  6f 72 6c 64 21 0a 00 01  |Hello, world!...|
+//
+// The text always ends with a line showing less than hexBytesPerLine bytes,
+// which may be empty, so that bytes can be added at the end.
+const (
+	hexBytesPerLine = 16
+	// hexASCIIColumn is the column of the first byte in the ASCII column
+	hexASCIIColumn = 61
+)
+
+// hexColumn returns the column of the hex digits of the i-th byte of a line
+// of a hex view
+func hexColumn(i int) int {
+	if i >= hexBytesPerLine/2 {
+		return 11 + 3*i
+	}
+	return 10 + 3*i
+}
+
+// hexDumpLine formats the line of a hex view showing the given bytes at the
+// given offset
+func hexDumpLine(off int, data []byte) []byte {
+	line := make([]byte, 0, hexASCIIColumn+hexBytesPerLine+1)
+	line = append(line, fmt.Sprintf("%08x ", off)...)
+	for i := 0; i < hexBytesPerLine; i++ {
+		line = append(line, ' ')
+		if i == hexBytesPerLine/2 {
+			line = append(line, ' ')
+		}
+		if i < len(data) {
+			line = append(line, hex.EncodeToString(data[i:i+1])...)
+		} else {
+			line = append(line, ' ', ' ')
+		}
+	}
+	line = append(line, ' ', ' ', '|')
+	for _, c := range data {
+		if c < 0x20 || c >= 0x7f {
+			c = '.'
+		}
+		line = append(line, c)
+	}
+	return append(line, '|')
+}
+
+// hexDump returns the text of a hex view of the given bytes, starting at
+// the given offset. If final is false the bytes must fill whole lines and
+// the text does not end with a line for new bytes.
+func hexDump(off int, data []byte, final bool) []byte {
+	var text []byte
+	for {
+		n := util.Min(len(data), hexBytesPerLine)
+		if n < hexBytesPerLine && !final {
+			return text
+		}
+		if len(text) > 0 {
+			text = append(text, '\n')
+		}
+		text = append(text, hexDumpLine(off, data[:n])...)
+		if n < hexBytesPerLine {
+			return text
+		}
+		off += n
+		data = data[n:]
+	}
+}
+
+// hexLineBytes parses the bytes shown on a line of a hex view
+func hexLineBytes(line []byte) ([]byte, error) {
+	data := make([]byte, 0, hexBytesPerLine)
+	for i := 0; i < hexBytesPerLine; i++ {
+		c := hexColumn(i)
+		if c+2 > len(line) || line[c] == ' ' {
+			break
+		}
+		b, err := strconv.ParseUint(string(line[c:c+2]), 16, 8)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, byte(b))
+	}
+	return data, nil
+}
+
+// newHexLineArray returns the text of a hex view of the bytes read from the
+// given reader. The bytes which cannot be read are not shown.
+func newHexLineArray(r io.Reader) *LineArray {
+	data, _ := io.ReadAll(r)
+	text := hexDump(0, data, true)
+	return NewLineArray(uint64(len(text)), FFUnix, bytes.NewReader(text))
+}
+
+// hasNulBytes returns true if one of the first detectlimit lines of the
+// buffer contains a NUL byte, which text files do not contain
+func (b *SharedBuffer) hasNulBytes() bool {
+	n := b.LinesNum()
+	if limit := util.IntOpt(b.Settings["detectlimit"]); limit > 0 {
+		n = util.Min(n, limit)
+	}
+	for i := 0; i < n; i++ {
+		if bytes.IndexByte(b.LineBytes(i), 0) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// hexBytes returns the bytes shown by the text of a hex view
+func (la *LineArray) hexBytes() ([]byte, error) {
+	data := make([]byte, 0, la.store.len()*hexBytesPerLine)
+	for i := 0; i < la.store.len(); i++ {
+		b, err := hexLineBytes(la.store.data(i))
+		if err != nil {
+			return nil, fmt.Errorf("line %d of the hex view is invalid: %v", i+1, err)
+		}
+		data = append(data, b...)
+	}
+	return data, nil
+}
+
+// A HexPos is a position in a hex view: the byte at Offset, either its low
+// or high hex digit, or its character in the ASCII column
+type HexPos struct {
+	Offset int
+	Low    bool
+	ASCII  bool
+}
+
+// HexLen returns the number of bytes shown by a hex view
+func (b *Buffer) HexLen() int {
+	last := b.LinesNum() - 1
+	data, _ := hexLineBytes(b.LineBytes(last))
+	return last*hexBytesPerLine + len(data)
+}
+
+// HexByte returns the byte at the given offset of a hex view
+func (b *Buffer) HexByte(off int) byte {
+	data, _ := hexLineBytes(b.LineBytes(off / hexBytesPerLine))
+	if i := off % hexBytesPerLine; i < len(data) {
+		return data[i]
+	}
+	return 0
+}
+
+// HexPosAt returns the position in a hex view of the given location, which
+// is moved to the closest byte
+func (b *Buffer) HexPosAt(loc Loc) HexPos {
+	var p HexPos
+	if loc.X >= hexASCIIColumn-1 {
+		p.ASCII = true
+		p.Offset = util.Max(loc.X-hexASCIIColumn, 0)
+	} else {
+		for i := hexBytesPerLine - 1; i >= 0; i-- {
+			if loc.X >= hexColumn(i) {
+				p.Offset = i
+				p.Low = loc.X > hexColumn(i)
+				break
+			}
+		}
+	}
+	p.Offset = util.Min(p.Offset, hexBytesPerLine-1)
+	p.Offset += loc.Y * hexBytesPerLine
+	if n := b.HexLen(); p.Offset >= n {
+		p.Offset, p.Low = n, false
+	}
+	return p
+}
+
+// HexLoc returns the location of the given position in a hex view
+func (b *Buffer) HexLoc(p HexPos) Loc {
+	p.Offset = util.Clamp(p.Offset, 0, b.HexLen())
+	y, i := p.Offset/hexBytesPerLine, p.Offset%hexBytesPerLine
+	if p.ASCII {
+		return Loc{hexASCIIColumn + i, y}
+	}
+	x := hexColumn(i)
+	if p.Low {
+		x++
+	}
+	return Loc{x, y}
+}
+
+// ReplaceHexBytes replaces the n bytes at the given offset of a hex view with
+// the given bytes, as a single undoable edit
+func (b *Buffer) ReplaceHexBytes(off, n int, data []byte) {
+	if b.Type.Readonly {
+		return
+	}
+	off = util.Clamp(off, 0, b.HexLen())
+	n = util.Clamp(n, 0, b.HexLen()-off)
+
+	first := off / hexBytesPerLine
+	last := b.LinesNum() - 1
+	if len(data) == n {
+		// only the lines of the replaced bytes change
+		last = util.Min((off+n)/hexBytesPerLine, last)
+	}
+	var old []byte
+	for y := first; y <= last; y++ {
+		l, _ := hexLineBytes(b.LineBytes(y))
+		old = append(old, l...)
+	}
+	rel := off - first*hexBytesPerLine
+	text := make([]byte, 0, len(old)-n+len(data))
+	text = append(text, old[:rel]...)
+	text = append(text, data...)
+	text = append(text, old[rel+n:]...)
+
+	final := last == b.LinesNum()-1
+	start, end := Loc{0, first}, Loc{util.CharacterCount(b.LineBytes(last)), last}
+	b.MultipleReplace([]Delta{{hexDump(first*hexBytesPerLine, text, final), start, end, nil}})
+}
+
+// ParseHex parses a sequence of bytes written in hexadecimal, which may be
+// separated by spaces
+func ParseHex(s string) ([]byte, error) {
+	s = strings.Join(strings.Fields(s), "")
+	if s == "" {
+		return nil, errors.New("No bytes given")
+	}
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("Invalid hex bytes: " + s)
+	}
+	return data, nil
+}
+
+// FindHex searches a hex view for the given bytes from the given offset,
+// forwards or backwards, wrapping around, and returns the offset of the
+// first match
+func (b *Buffer) FindHex(pattern []byte, from int, down bool) (int, bool) {
+	data, err := b.hexBytes()
+	if err != nil || len(pattern) == 0 {
+		return 0, false
+	}
+	from = util.Clamp(from, 0, len(data))
+	if down {
+		if i := bytes.Index(data[from:], pattern); i >= 0 {
+			return from + i, true
+		}
+		i := bytes.Index(data[:util.Min(from+len(pattern)-1, len(data))], pattern)
+		return i, i >= 0
+	}
+	if i := bytes.LastIndex(data[:util.Min(from+len(pattern)-1, len(data))], pattern); i >= 0 && i < from {
+		return i, true
+	}
+	i := bytes.LastIndex(data[from:], pattern)
+	if i < 0 {
+		return 0, false
+	}
+	return from + i, true
+}
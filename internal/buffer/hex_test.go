@@ -0,0 +1,83 @@
+package buffer
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/micro-editor/micro/v2/internal/config"
+	"github.com/micro-editor/micro/v2/internal/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHexDump(t *testing.T) {
+	assert.Equal(t,
+		"00000000  48 65 6c 6c 6f 2c 20 77  6f 72 6c 64 21 0a 00 01  |Hello, world!...|\n"+
+			"00000010  ff                                                |.|",
+		string(hexDump(0, []byte("Hello, world!\n\x00\x01\xff"), true)))
+	// a full last line is followed by a line for new bytes
+	text := hexDump(0, make([]byte, 16), true)
+	la := NewLineArray(uint64(len(text)), FFUnix, bytes.NewReader(text))
+	assert.Equal(t, 2, la.LinesNum())
+	data, err := la.hexBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, make([]byte, 16), data)
+}
+
+func TestHexView(t *testing.T) {
+	defer func(dir string) {
+		config.ConfigDir = dir
+	}(config.ConfigDir)
+	config.ConfigDir = t.TempDir()
+
+	path := filepath.Join(t.TempDir(), "file.bin")
+	orig := []byte("\x00\x01\x02\x03\x04\x05\x06\x07\x08\x09\x0a\x0b\x0c\x0d\x0e\x0f\r\n\xde\xad")
+	assert.NoError(t, os.WriteFile(path, orig, 0644))
+
+	text, err := NewBufferFromFile(path, BTDefault)
+	assert.NoError(t, err)
+	defer text.Close()
+	assert.True(t, text.Binary)
+
+	b, err := NewBufferFromFile(path, BTHex)
+	assert.NoError(t, err)
+	defer b.Close()
+	assert.Equal(t, 20, b.HexLen())
+	assert.Equal(t, HexPos{Offset: 17, Low: true}, b.HexPosAt(Loc{14, 1}))
+	assert.Equal(t, Loc{hexASCIIColumn + 3, 1}, b.HexLoc(HexPos{Offset: 19, ASCII: true}))
+
+	off, found := b.FindHex([]byte{0xde, 0xad}, 0, true)
+	assert.True(t, found)
+	assert.Equal(t, 18, off)
+	off, found = b.FindHex([]byte{0x0f, 0x0d}, 18, false)
+	assert.True(t, found)
+	assert.Equal(t, 15, off)
+
+	// inserting a byte shifts the following ones to the next line
+	b.ReplaceHexBytes(0, 0, []byte{0xff})
+	b.ReplaceHexBytes(20, 1, []byte{0xef})
+	assert.Equal(t, 21, b.HexLen())
+	assert.Equal(t, byte(0xef), b.HexByte(20))
+	b.ReplaceHexBytes(1, 1, nil)
+
+	// the backup holds the bytes, not the text of the view
+	b.SetOptionNative("backup", true)
+	assert.NoError(t, b.Backup())
+	backupfile, _ := util.DetermineEscapePath(b.backupDir(), b.AbsPath)
+	data, err := os.ReadFile(backupfile)
+	assert.NoError(t, err)
+	assert.Equal(t, "\xff\x01\x02\x03\x04\x05\x06\x07\x08\x09\x0a\x0b\x0c\x0d\x0e\x0f\r\n\xde\xef", string(data))
+
+	// the bytes are saved unchanged
+	assert.NoError(t, b.Save())
+	data, err = os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "\xff\x01\x02\x03\x04\x05\x06\x07\x08\x09\x0a\x0b\x0c\x0d\x0e\x0f\r\n\xde\xef", string(data))
+
+	for i := 0; i < 3; i++ {
+		b.Undo()
+	}
+	assert.Equal(t, 20, b.HexLen())
+	assert.Equal(t, byte(0), b.HexByte(0))
+}
@@ -0,0 +1,112 @@
+package buffer
+
+import (
+	"reflect"
+
+	"github.com/micro-editor/micro/v2/internal/util"
+)
+
+// minIndentLines is the number of indented lines needed to detect the
+// indentation of a text
+const minIndentLines = 5
+
+// guessIndent guesses from the given lines whether a text is indented with
+// tabs, and the width of a level of indentation if it is indented with
+// spaces, which is 0 if it is not clear. It returns false if the
+// indentation is not clear at all.
+func guessIndent(lines [][]byte) (bool, int, bool) {
+	tabLines, spaceLines := 0, 0
+	// steps counts the lines by the number of spaces they are indented by
+	// more than the previous line
+	steps := make(map[int]int)
+	prev := 0
+	for _, l := range lines {
+		ws := util.GetLeadingWhitespace(l)
+		if len(ws) == len(l) {
+			// blank lines do not tell anything
+			continue
+		}
+		n := len(ws)
+		switch {
+		case n == 0:
+		case ws[0] == '\t':
+			tabLines++
+			n = 0
+		case n == 1:
+			// e.g. the stars of a block comment
+			continue
+		default:
+			spaceLines++
+			if step := n - prev; step >= 2 && step <= 8 {
+				steps[step]++
+			}
+		}
+		prev = n
+	}
+
+	if tabLines >= minIndentLines && tabLines > 2*spaceLines {
+		return true, 0, true
+	}
+	if spaceLines < minIndentLines || spaceLines <= 2*tabLines {
+		return false, 0, false
+	}
+	size, total := 0, 0
+	for step, count := range steps {
+		total += count
+		if count > steps[size] || (count == steps[size] && step < size) {
+			size = step
+		}
+	}
+	if steps[size] < 2 || 2*steps[size] <= total {
+		size = 0
+	}
+	return false, size, true
+}
+
+// detectIndent returns the tabstospaces and tabsize options given by the
+// indentation of the first detectlimit lines of the buffer
+func (b *SharedBuffer) detectIndent() map[string]any {
+	n := b.LinesNum()
+	if limit := util.IntOpt(b.Settings["detectlimit"]); limit > 0 {
+		n = util.Min(n, limit)
+	}
+	lines := make([][]byte, n)
+	for i := range lines {
+		lines[i] = b.LineBytes(i)
+	}
+
+	tabs, size, ok := guessIndent(lines)
+	if !ok {
+		return nil
+	}
+	options := map[string]any{"tabstospaces": !tabs}
+	if size > 0 {
+		options["tabsize"] = float64(size)
+	}
+	return options
+}
+
+// DetectIndent sets the tabstospaces and tabsize options of the buffer
+// locally from the indentation of its first detectlimit lines. It returns
+// false if the indentation is not clear enough.
+func (b *Buffer) DetectIndent() bool {
+	options := b.detectIndent()
+	for k, v := range options {
+		b.SetOptionNative(k, v)
+	}
+	if options != nil {
+		b.detectedIndent = options
+	}
+	return options != nil
+}
+
+// IndentDetected returns true if the current indentation options of the
+// buffer have been detected from its text
+func (b *SharedBuffer) IndentDetected() bool {
+	for k, v := range b.detectedIndent {
+		if !reflect.DeepEqual(b.Settings[k], v) {
+			return false
+		}
+	}
+	return len(b.detectedIndent) > 0
+}
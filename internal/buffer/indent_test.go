@@ -0,0 +1,49 @@
+package buffer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGuessIndent(t *testing.T) {
+	guess := func(text string) (bool, int, bool) {
+		var lines [][]byte
+		for _, l := range strings.Split(text, "\n") {
+			lines = append(lines, []byte(l))
+		}
+		return guessIndent(lines)
+	}
+
+	tabs, size, ok := guess("a:\n  b:\n    c: 1\n    d: 2\n  e:\n    - f\n\n    - g\nh: 3\n  i: 4\n")
+	assert.True(t, ok)
+	assert.False(t, tabs)
+	assert.Equal(t, 2, size)
+
+	tabs, _, ok = guess("func f() {\n\tif x {\n\t\ty()\n\t}\n\tz()\n\treturn\n}\n/*\n * comment\n */\n")
+	assert.True(t, ok)
+	assert.True(t, tabs)
+
+	// too few indented lines
+	_, _, ok = guess("a\n    b\n    c\n")
+	assert.False(t, ok)
+
+	// as many lines indented with tabs as with spaces
+	_, _, ok = guess("a\n\tb\n\tc\n\td\n\te\n\tf\n    g\n    h\n    i\n    j\n    k\n")
+	assert.False(t, ok)
+}
+
+func TestDetectIndent(t *testing.T) {
+	b := NewBufferFromString("def f():\n    if x:\n        y()\n    z()\n    return\n\ndef g():\n    pass\n", "", BTDefault)
+	defer b.Close()
+	b.SetOptionNative("tabsize", float64(8))
+
+	assert.True(t, b.DetectIndent())
+	assert.Equal(t, true, b.Settings["tabstospaces"])
+	assert.Equal(t, float64(4), b.Settings["tabsize"])
+	assert.True(t, b.IndentDetected())
+
+	b.SetOptionNative("tabsize", float64(2))
+	assert.False(t, b.IndentDetected())
+}
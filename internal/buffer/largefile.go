@@ -0,0 +1,31 @@
+package buffer
+
+// largeFileSettings are the options that are overridden for buffers opened
+// in large file mode, because the features behind them are too slow for
+// huge files
+var largeFileSettings = map[string]any{
+	"backup":     false,
+	"diffgutter": false,
+	"fastdirty":  true,
+	"savecursor": false,
+	"saveundo":   false,
+	"syntax":     false,
+}
+
+// isLargeFile returns whether a file of the given size must be opened in
+// large file mode, according to the `largefile` option
+func isLargeFile(size int64, settings map[string]any) bool {
+	threshold := settings["largefile"].(float64)
+	return threshold > 0 && float64(size) >= threshold*1024*1024
+}
+
+// applyLargeFileSettings disables the expensive features of a buffer in
+// large file mode. The options are marked as set locally, so that reloading
+// the settings does not turn them back on, but the user can still enable
+// any of them for this buffer with `setlocal`.
+func (b *SharedBuffer) applyLargeFileSettings() {
+	for k, v := range largeFileSettings {
+		b.Settings[k] = v
+		b.LocalSettings[k] = true
+	}
+}
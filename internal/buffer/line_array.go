@@ -3,6 +3,7 @@ package buffer
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"io"
 	"sync"
 
@@ -41,11 +42,9 @@ type searchState struct {
 	done       bool
 }
 
-// A Line contains the data in bytes as well as a highlight state, match
-// and a flag for whether the highlighting needs to be updated
-type Line struct {
-	data []byte
-
+// A lineMeta contains the per-line information that is not part of the
+// text itself: the highlight state, match and the search matches
+type lineMeta struct {
 	state highlight.State
 	match highlight.LineMatch
 	lock  sync.Mutex
@@ -59,24 +58,138 @@ type Line struct {
 	search map[*Buffer]*searchState
 }
 
+// A Line contains the data in bytes as well as a highlight state, match
+// and a flag for whether the highlighting needs to be updated
+type Line struct {
+	data []byte
+	// eol is the line ending of the line, or FFAuto if it is the one of
+	// the file format
+	eol FileFormat
+
+	lineMeta
+}
+
 const (
 	// Line ending file formats
 	FFAuto = 0 // Autodetect format
 	FFUnix = 1 // LF line endings (unix style '\n')
 	FFDos  = 2 // CRLF line endings (dos style '\r\n')
+	FFMac  = 3 // CR line endings (old mac style '\r')
 )
 
 type FileFormat byte
 
+// eolBytes returns the bytes which end a line in the given file format
+func eolBytes(ff FileFormat) []byte {
+	switch ff {
+	case FFDos:
+		return []byte{'\r', '\n'}
+	case FFMac:
+		return []byte{'\r'}
+	}
+	return []byte{'\n'}
+}
+
+// A lineStore is the storage backend of a LineArray. The LineArray
+// implements all the editing operations on top of these primitives, so
+// the backends only need to know how to store and retrieve whole lines.
+type lineStore interface {
+	// len returns the number of lines
+	len() int
+	// data returns the text of line n
+	data(n int) []byte
+	// setData replaces the text of line n. The store may keep the slice.
+	setData(n int, data []byte)
+	// insertLines inserts the given lines before line n, with empty metadata
+	insertLines(n int, lines [][]byte)
+	// deleteLines deletes the lines from y1 to y2 inclusive
+	deleteLines(y1, y2 int)
+	// ending returns the line ending of line n, or FFAuto if the line ends
+	// with the line ending of the file format
+	ending(n int) FileFormat
+	// setEnding sets the line ending of line n
+	setEnding(n int, eol FileFormat)
+	// meta returns the metadata of line n. If create is false and the
+	// store does not hold any metadata for the line yet, it returns nil.
+	meta(n int, create bool) *lineMeta
+	// info returns a short description of the store for the user
+	info() string
+}
+
 // A LineArray simply stores and array of lines and makes it easy to insert
 // and delete in it
 type LineArray struct {
-	lines    []Line
-	Endings  FileFormat
+	store   lineStore
+	Endings FileFormat
+	// mixed is the number of lines whose line ending is not the one of
+	// the file format
+	mixed    int
 	initsize uint64
 	lock     sync.Mutex
 }
 
+// lineSlice is the default line store, which keeps every line in its own
+// Line struct with its own byte slice
+type lineSlice struct {
+	lines []Line
+}
+
+func (ls *lineSlice) len() int {
+	return len(ls.lines)
+}
+
+func (ls *lineSlice) data(n int) []byte {
+	return ls.lines[n].data
+}
+
+func (ls *lineSlice) setData(n int, data []byte) {
+	ls.lines[n].data = data
+}
+
+// insertBytes inserts a value without newlines in line n at byte x, in
+// place if the line has room for it
+func (ls *lineSlice) insertBytes(n, x int, value []byte) {
+	data := append(ls.lines[n].data, value...)
+	copy(data[x+len(value):], data[x:])
+	copy(data[x:], value)
+	ls.lines[n].data = data
+}
+
+func (ls *lineSlice) insertLines(n int, lines [][]byte) {
+	l := len(ls.lines)
+	if l+len(lines) > cap(ls.lines) {
+		newSlice := make([]Line, l+len(lines), l+len(lines)+10000)
+		copy(newSlice, ls.lines)
+		ls.lines = newSlice
+	} else {
+		ls.lines = ls.lines[:l+len(lines)]
+	}
+	copy(ls.lines[n+len(lines):], ls.lines[n:l])
+	for i, data := range lines {
+		ls.lines[n+i] = Line{data: data}
+	}
+}
+
+func (ls *lineSlice) deleteLines(y1, y2 int) {
+	ls.lines = ls.lines[:y1+copy(ls.lines[y1:], ls.lines[y2+1:])]
+}
+
+func (ls *lineSlice) ending(n int) FileFormat {
+	return ls.lines[n].eol
+}
+
+func (ls *lineSlice) setEnding(n int, eol FileFormat) {
+	ls.lines[n].eol = eol
+}
+
+func (ls *lineSlice) meta(n int, create bool) *lineMeta {
+	return &ls.lines[n].lineMeta
+}
+
+func (ls *lineSlice) info() string {
+	return fmt.Sprintf("line array, %d lines", len(ls.lines))
+}
+
 // Append efficiently appends lines together
 // It allocates an additional 10000 lines if the original estimate
 // is incorrect
@@ -98,32 +211,41 @@ func Append(slice []Line, data ...Line) []Line {
 func NewLineArray(size uint64, endings FileFormat, reader io.Reader) *LineArray {
 	la := new(LineArray)
 
-	la.lines = make([]Line, 0, 1000)
+	ls := new(lineSlice)
+	ls.lines = make([]Line, 0, 1000)
+	la.store = ls
 	la.initsize = size
 
 	br := bufio.NewReader(reader)
 	var loaded int
 
-	la.Endings = endings
+	var counts endingCounts
 
 	n := 0
 	for {
 		data, err := br.ReadBytes('\n')
+		dlen := len(data)
+
+		// the lines ending with a lone '\r' come before the '\n'
+		for {
+			i := bytes.IndexByte(data, '\r')
+			if i < 0 || i+1 < len(data) && data[i+1] == '\n' {
+				break
+			}
+			ls.lines = Append(ls.lines, Line{data: data[:i:i], eol: FFMac})
+			counts.add(FFMac)
+			data = data[i+1:]
+			n++
+		}
+
 		// Detect the line ending by checking to see if there is a '\r' char
 		// before the '\n'
 		// Even if the file format is set to DOS, the '\r' is removed so
 		// that all lines end with '\n'
-		dlen := len(data)
-		if dlen > 1 && data[dlen-2] == '\r' {
-			data = append(data[:dlen-2], '\n')
-			if la.Endings == FFAuto {
-				la.Endings = FFDos
-			}
-			dlen = len(data)
-		} else if dlen > 0 {
-			if la.Endings == FFAuto {
-				la.Endings = FFUnix
-			}
+		eol := FileFormat(FFUnix)
+		if len(data) > 1 && data[len(data)-2] == '\r' {
+			data = append(data[:len(data)-2], '\n')
+			eol = FFDos
 		}
 
 		// If we are loading a large file (greater than 1000) we use the file
@@ -133,9 +255,9 @@ func NewLineArray(size uint64, endings FileFormat, reader io.Reader) *LineArray
 		// plenty of room for expansion
 		if n >= 1000 && loaded >= 0 {
 			totalLinesNum := int(float64(size) * (float64(n) / float64(loaded)))
-			newSlice := make([]Line, len(la.lines), totalLinesNum+10000)
-			copy(newSlice, la.lines)
-			la.lines = newSlice
+			newSlice := make([]Line, len(ls.lines), totalLinesNum+10000)
+			copy(newSlice, ls.lines)
+			ls.lines = newSlice
 			loaded = -1
 		}
 
@@ -146,169 +268,288 @@ func NewLineArray(size uint64, endings FileFormat, reader io.Reader) *LineArray
 
 		if err != nil {
 			if err == io.EOF {
-				la.lines = Append(la.lines, Line{
-					data:  data,
-					state: nil,
-					match: nil,
-				})
+				ls.lines = Append(ls.lines, Line{data: data})
 			}
 			// Last line was read
 			break
 		} else {
-			la.lines = Append(la.lines, Line{
-				data:  data[:dlen-1],
-				state: nil,
-				match: nil,
-			})
+			ls.lines = Append(ls.lines, Line{data: data[:len(data)-1], eol: eol})
+			counts.add(eol)
 		}
 		n++
 	}
 
+	la.detectEndings(endings, counts)
 	return la
 }
 
+// endingCounts counts the lines of a file ending with each line ending, and
+// remembers which line ending comes first
+type endingCounts struct {
+	n     [4]int
+	first FileFormat
+}
+
+func (c *endingCounts) add(eol FileFormat) {
+	c.n[eol]++
+	if c.first == FFAuto {
+		c.first = eol
+	}
+}
+
+// detectEndings sets the file format of a line array which has just been
+// read to the given one or, if it is FFAuto, to the most common line ending
+// of its lines. The lines keep their line ending, which is stored as it was
+// read.
+func (la *LineArray) detectEndings(endings FileFormat, counts endingCounts) {
+	la.Endings = endings
+	if la.Endings == FFAuto {
+		la.Endings = counts.first
+		for ff, n := range counts.n {
+			if n > counts.n[la.Endings] {
+				la.Endings = FileFormat(ff)
+			}
+		}
+	}
+	if la.Endings == FFAuto && la.store.len() > 0 && len(la.store.data(0)) > 0 {
+		la.Endings = FFUnix
+	}
+
+	la.mixed = 0
+	for ff, n := range counts.n {
+		if FileFormat(ff) != la.Endings {
+			la.mixed += n
+		}
+	}
+}
+
 // Bytes returns the string that should be written to disk when
 // the line array is saved
 func (la *LineArray) Bytes() []byte {
 	b := new(bytes.Buffer)
 	// initsize should provide a good estimate
 	b.Grow(int(la.initsize + 4096))
-	for i, l := range la.lines {
-		b.Write(l.data)
-		if i != len(la.lines)-1 {
-			if la.Endings == FFDos {
-				b.WriteByte('\r')
-			}
-			b.WriteByte('\n')
+	n := la.store.len()
+	for i := 0; i < n; i++ {
+		b.Write(la.store.data(i))
+		if i != n-1 {
+			b.Write(eolBytes(la.LineEnding(i)))
 		}
 	}
 	return b.Bytes()
 }
 
-// newlineBelow adds a newline below the given line number
-func (la *LineArray) newlineBelow(y int) {
-	la.lines = append(la.lines, Line{
-		data:  []byte{' '},
-		state: nil,
-		match: nil,
-	})
-	copy(la.lines[y+2:], la.lines[y+1:])
-	la.lines[y+1] = Line{
-		data:  []byte{},
-		state: la.lines[y].state,
-		match: nil,
+// LineEnding returns the line ending of line n
+func (la *LineArray) LineEnding(n int) FileFormat {
+	if eol := la.store.ending(n); eol != FFAuto {
+		return eol
 	}
+	return la.Endings
 }
 
-// Inserts a byte array at a given location
-func (la *LineArray) insert(pos Loc, value []byte) {
-	la.lock.Lock()
-	defer la.lock.Unlock()
+// ownEnding returns the line ending of line n if it is not the one of the
+// file format, and FFAuto otherwise
+func (la *LineArray) ownEnding(n int) FileFormat {
+	if eol := la.store.ending(n); eol != la.Endings {
+		return eol
+	}
+	return FFAuto
+}
 
-	x, y := runeToByteIndex(pos.X, la.lines[pos.Y].data), pos.Y
-	for i := 0; i < len(value); i++ {
-		if value[i] == '\n' || (value[i] == '\r' && i < len(value)-1 && value[i+1] == '\n') {
-			la.split(Loc{x, y})
-			x = 0
-			y++
+// setLineEnding sets the line ending of line n, FFAuto meaning the one of
+// the file format
+func (la *LineArray) setLineEnding(n int, eol FileFormat) {
+	if eol == la.Endings {
+		eol = FFAuto
+	}
+	if la.ownEnding(n) != FFAuto {
+		la.mixed--
+	}
+	if eol != FFAuto {
+		la.mixed++
+	}
+	la.store.setEnding(n, eol)
+}
 
-			if value[i] == '\r' {
-				i++
-			}
+// MixedEndings returns true if some lines do not end with the line ending
+// of the file format
+func (la *LineArray) MixedEndings() bool {
+	return la.mixed > 0
+}
+
+// SetEndings sets the file format of the line array and ends all of its
+// lines with its line ending
+func (la *LineArray) SetEndings(ff FileFormat) {
+	la.lock.Lock()
+	defer la.lock.Unlock()
 
-			continue
+	la.Endings = ff
+	for i := 0; i < la.store.len(); i++ {
+		if la.store.ending(i) != FFAuto {
+			la.store.setEnding(i, FFAuto)
 		}
-		la.insertByte(Loc{x, y}, value[i])
-		x++
 	}
+	la.mixed = 0
 }
 
-// InsertByte inserts a byte at a given location
-func (la *LineArray) insertByte(pos Loc, value byte) {
-	la.lines[pos.Y].data = append(la.lines[pos.Y].data, 0)
-	copy(la.lines[pos.Y].data[pos.X+1:], la.lines[pos.Y].data[pos.X:])
-	la.lines[pos.Y].data[pos.X] = value
+// concat returns a newly allocated slice containing all the given slices
+func concat(parts ...[]byte) []byte {
+	n := 0
+	for _, p := range parts {
+		n += len(p)
+	}
+	data := make([]byte, 0, n)
+	for _, p := range parts {
+		data = append(data, p...)
+	}
+	return data
 }
 
-// joinLines joins the two lines a and b
-func (la *LineArray) joinLines(a, b int) {
-	la.lines[a].data = append(la.lines[a].data, la.lines[b].data...)
-	la.deleteLine(b)
-}
+// copyEndings gives the lines of the line array the file format and the line
+// endings of the lines of another line array with the same lines
+func (la *LineArray) copyEndings(other *LineArray) {
+	la.lock.Lock()
+	defer la.lock.Unlock()
 
-// split splits a line at a given position
-func (la *LineArray) split(pos Loc) {
-	la.newlineBelow(pos.Y)
-	la.lines[pos.Y+1].data = append(la.lines[pos.Y+1].data, la.lines[pos.Y].data[pos.X:]...)
-	la.lines[pos.Y+1].state = la.lines[pos.Y].state
-	la.lines[pos.Y].state = nil
-	la.lines[pos.Y].match = nil
-	la.lines[pos.Y+1].match = nil
-	la.deleteToEnd(Loc{pos.X, pos.Y})
+	if other.Endings != FFAuto {
+		la.Endings = other.Endings
+	}
+	la.mixed = 0
+	for i := 0; i < la.store.len() && i < other.store.len(); i++ {
+		eol := other.ownEnding(i)
+		if eol != FFAuto {
+			la.mixed++
+		}
+		la.store.setEnding(i, eol)
+	}
 }
 
-// removes from start to end
-func (la *LineArray) remove(start, end Loc) []byte {
+// Inserts a byte array at a given location. The lines ended by the newlines
+// of the value get the given line endings, or the one of the file format if
+// there are not enough of them.
+func (la *LineArray) insert(pos Loc, value []byte, endings []FileFormat) {
 	la.lock.Lock()
 	defer la.lock.Unlock()
 
-	sub := la.Substr(start, end)
-	startX := runeToByteIndex(start.X, la.lines[start.Y].data)
-	endX := runeToByteIndex(end.X, la.lines[end.Y].data)
-	if start.Y == end.Y {
-		la.lines[start.Y].data = append(la.lines[start.Y].data[:startX], la.lines[start.Y].data[endX:]...)
-	} else {
-		la.deleteLines(start.Y+1, end.Y-1)
-		la.deleteToEnd(Loc{startX, start.Y})
-		la.deleteFromStart(Loc{endX - 1, start.Y + 1})
-		la.joinLines(start.Y, start.Y+1)
+	line := la.store.data(pos.Y)
+	x := runeToByteIndex(pos.X, line)
+
+	// split the value into lines, treating both '\n' and '\r\n' as newlines
+	var segs [][]byte
+	start := 0
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\n' {
+			segs = append(segs, value[start:i])
+			start = i + 1
+		} else if value[i] == '\r' && i < len(value)-1 && value[i+1] == '\n' {
+			segs = append(segs, value[start:i])
+			start = i + 2
+			i++
+		}
 	}
-	return sub
-}
+	segs = append(segs, value[start:])
 
-// deleteToEnd deletes from the end of a line to the position
-func (la *LineArray) deleteToEnd(pos Loc) {
-	la.lines[pos.Y].data = la.lines[pos.Y].data[:pos.X]
-}
+	if len(segs) == 1 {
+		if ls, ok := la.store.(*lineSlice); ok {
+			ls.insertBytes(pos.Y, x, value)
+		} else {
+			la.store.setData(pos.Y, concat(line[:x], value, line[x:]))
+		}
+		return
+	}
 
-// deleteFromStart deletes from the start of a line to the position
-func (la *LineArray) deleteFromStart(pos Loc) {
-	la.lines[pos.Y].data = la.lines[pos.Y].data[pos.X+1:]
-}
+	last := len(segs) - 1
+	newLines := make([][]byte, last)
+	for i := 1; i < last; i++ {
+		newLines[i-1] = concat(segs[i])
+	}
+	newLines[last-1] = concat(segs[last], line[x:])
+
+	la.store.setData(pos.Y, concat(line[:x], segs[0]))
+	la.store.insertLines(pos.Y+1, newLines)
+
+	// the line ending of the split line now ends the last line
+	la.store.setEnding(pos.Y+last, la.store.ending(pos.Y))
+	la.store.setEnding(pos.Y, FFAuto)
+	for i := 0; i < last; i++ {
+		eol := FileFormat(FFAuto)
+		if i < len(endings) {
+			eol = endings[i]
+		}
+		la.setLineEnding(pos.Y+i, eol)
+	}
 
-// deleteLine deletes the line number
-func (la *LineArray) deleteLine(y int) {
-	la.lines = la.lines[:y+copy(la.lines[y:], la.lines[y+1:])]
+	// the highlight state of the split line now belongs to the last line
+	if m := la.store.meta(pos.Y, false); m != nil {
+		state := m.state
+		m.state = nil
+		m.match = nil
+		if state != nil {
+			la.store.meta(pos.Y+last, true).state = state
+		}
+	}
 }
 
-func (la *LineArray) deleteLines(y1, y2 int) {
-	la.lines = la.lines[:y1+copy(la.lines[y1:], la.lines[y2+1:])]
+// removes from start to end, and returns the removed text and the line
+// endings of the lines it ended if one of them is not the one of the file
+// format
+func (la *LineArray) remove(start, end Loc) ([]byte, []FileFormat) {
+	la.lock.Lock()
+	defer la.lock.Unlock()
+
+	sub := la.Substr(start, end)
+	var endings []FileFormat
+	for y := start.Y; y < end.Y; y++ {
+		if eol := la.ownEnding(y); eol != FFAuto {
+			if endings == nil {
+				endings = make([]FileFormat, end.Y-start.Y)
+			}
+			endings[y-start.Y] = eol
+			la.mixed--
+		}
+	}
+	if end.Y > start.Y {
+		// the joined line ends with the line ending of the last line
+		la.store.setEnding(start.Y, la.store.ending(end.Y))
+	}
+
+	first := la.store.data(start.Y)
+	last := la.store.data(end.Y)
+	startX := runeToByteIndex(start.X, first)
+	endX := runeToByteIndex(end.X, last)
+	la.store.setData(start.Y, concat(first[:startX], last[endX:]))
+	if end.Y > start.Y {
+		la.store.deleteLines(start.Y+1, end.Y)
+	}
+	return sub, endings
 }
 
 // Substr returns the string representation between two locations
 func (la *LineArray) Substr(start, end Loc) []byte {
-	startX := runeToByteIndex(start.X, la.lines[start.Y].data)
-	endX := runeToByteIndex(end.X, la.lines[end.Y].data)
+	first := la.store.data(start.Y)
+	last := la.store.data(end.Y)
+	startX := runeToByteIndex(start.X, first)
+	endX := runeToByteIndex(end.X, last)
 	if start.Y == end.Y {
-		src := la.lines[start.Y].data[startX:endX]
+		src := first[startX:endX]
 		dest := make([]byte, len(src))
 		copy(dest, src)
 		return dest
 	}
-	str := make([]byte, 0, len(la.lines[start.Y+1].data)*(end.Y-start.Y))
-	str = append(str, la.lines[start.Y].data[startX:]...)
+	str := make([]byte, 0, len(la.store.data(start.Y+1))*(end.Y-start.Y))
+	str = append(str, first[startX:]...)
 	str = append(str, '\n')
 	for i := start.Y + 1; i <= end.Y-1; i++ {
-		str = append(str, la.lines[i].data...)
+		str = append(str, la.store.data(i)...)
 		str = append(str, '\n')
 	}
-	str = append(str, la.lines[end.Y].data[:endX]...)
+	str = append(str, last[:endX]...)
 	return str
 }
 
 // LinesNum returns the number of lines in the buffer
 func (la *LineArray) LinesNum() int {
-	return len(la.lines)
+	return la.store.len()
 }
 
 // Start returns the start of the buffer
@@ -318,44 +559,71 @@ func (la *LineArray) Start() Loc {
 
 // End returns the location of the last character in the buffer
 func (la *LineArray) End() Loc {
-	numlines := len(la.lines)
-	return Loc{util.CharacterCount(la.lines[numlines-1].data), numlines - 1}
+	numlines := la.store.len()
+	return Loc{util.CharacterCount(la.store.data(numlines - 1)), numlines - 1}
 }
 
 // LineBytes returns line n as an array of bytes
 func (la *LineArray) LineBytes(lineN int) []byte {
-	if lineN >= len(la.lines) || lineN < 0 {
+	if lineN >= la.store.len() || lineN < 0 {
 		return []byte{}
 	}
-	return la.lines[lineN].data
+	return la.store.data(lineN)
+}
+
+// setLineBytes replaces the contents of line n. The caller must hold
+// the lock of the LineArray.
+func (la *LineArray) setLineBytes(lineN int, data []byte) {
+	la.store.setData(lineN, data)
+}
+
+// StorageInfo returns a short description of how the lines are stored
+func (la *LineArray) StorageInfo() string {
+	return la.store.info()
 }
 
 // State gets the highlight state for the given line number
 func (la *LineArray) State(lineN int) highlight.State {
-	la.lines[lineN].lock.Lock()
-	defer la.lines[lineN].lock.Unlock()
-	return la.lines[lineN].state
+	m := la.store.meta(lineN, false)
+	if m == nil {
+		return nil
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.state
 }
 
 // SetState sets the highlight state at the given line number
 func (la *LineArray) SetState(lineN int, s highlight.State) {
-	la.lines[lineN].lock.Lock()
-	defer la.lines[lineN].lock.Unlock()
-	la.lines[lineN].state = s
+	m := la.store.meta(lineN, s != nil)
+	if m == nil {
+		return
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.state = s
 }
 
 // SetMatch sets the match at the given line number
 func (la *LineArray) SetMatch(lineN int, m highlight.LineMatch) {
-	la.lines[lineN].lock.Lock()
-	defer la.lines[lineN].lock.Unlock()
-	la.lines[lineN].match = m
+	lm := la.store.meta(lineN, m != nil)
+	if lm == nil {
+		return
+	}
+	lm.lock.Lock()
+	defer lm.lock.Unlock()
+	lm.match = m
 }
 
 // Match retrieves the match for the given line number
 func (la *LineArray) Match(lineN int) highlight.LineMatch {
-	la.lines[lineN].lock.Lock()
-	defer la.lines[lineN].lock.Unlock()
-	return la.lines[lineN].match
+	m := la.store.meta(lineN, false)
+	if m == nil {
+		return nil
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.match
 }
 
 // Locks the whole LineArray
@@ -384,19 +652,23 @@ func (la *LineArray) SearchMatch(b *Buffer, pos Loc) bool {
 	if b.LastSearch == "" {
 		return false
 	}
+	if isMultiLine(b.LastSearch) {
+		return b.multiLineSearchMatch(pos)
+	}
 
 	lineN := pos.Y
-	if la.lines[lineN].search == nil {
-		la.lines[lineN].search = make(map[*Buffer]*searchState)
+	m := la.store.meta(lineN, true)
+	if m.search == nil {
+		m.search = make(map[*Buffer]*searchState)
 	}
-	s, ok := la.lines[lineN].search[b]
+	s, ok := m.search[b]
 	if !ok {
 		// Note: here is a small harmless leak: when the buffer `b` is closed,
 		// `s` is not deleted from the map. It means that the buffer
 		// will not be garbage-collected until the line array is garbage-collected,
 		// i.e. until all the buffers sharing this file are closed.
 		s = new(searchState)
-		la.lines[lineN].search[b] = s
+		m.search[b] = s
 	}
 	if !ok || s.search != b.LastSearch || s.useRegex != b.LastSearchRegex ||
 		s.ignorecase != b.Settings["ignorecase"].(bool) {
@@ -409,7 +681,7 @@ func (la *LineArray) SearchMatch(b *Buffer, pos Loc) bool {
 	if !s.done {
 		s.match = nil
 		start := Loc{0, lineN}
-		end := Loc{util.CharacterCount(la.lines[lineN].data), lineN}
+		end := Loc{util.CharacterCount(la.store.data(lineN)), lineN}
 		for start.X < end.X {
 			m, found, _ := b.FindNext(b.LastSearch, start, end, start, true, b.LastSearchRegex)
 			if !found {
@@ -437,8 +709,8 @@ func (la *LineArray) SearchMatch(b *Buffer, pos Loc) bool {
 // invalidateSearchMatches marks search matches for the given line as outdated.
 // It is called when the line is modified.
 func (la *LineArray) invalidateSearchMatches(lineN int) {
-	if la.lines[lineN].search != nil {
-		for _, s := range la.lines[lineN].search {
+	if m := la.store.meta(lineN, false); m != nil && m.search != nil {
+		for _, s := range m.search {
 			s.done = false
 		}
 	}
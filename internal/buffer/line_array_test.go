@@ -13,49 +13,133 @@ He wonede at Ernleȝe at æðelen are chirechen,
 Uppen Sevarne staþe, sel þar him þuhte,
 Onfest Radestone, þer he bock radde.`
 
-var la *LineArray
+// the tests below are run in order against every line array backend
+var las []*LineArray
 
 func init() {
-	reader := strings.NewReader(unicode_txt)
-	la = NewLineArray(uint64(len(unicode_txt)), FFAuto, reader)
+	las = []*LineArray{
+		NewLineArray(uint64(len(unicode_txt)), FFAuto, strings.NewReader(unicode_txt)),
+		NewPieceTableLineArray(uint64(len(unicode_txt)), FFAuto, strings.NewReader(unicode_txt)),
+	}
 }
 
 func TestSplit(t *testing.T) {
-	la.insert(Loc{17, 1}, []byte{'\n'})
-	assert.Equal(t, len(la.lines), 6)
-	sub1 := la.Substr(Loc{0, 1}, Loc{17, 1})
-	sub2 := la.Substr(Loc{0, 2}, Loc{30, 2})
+	for _, la := range las {
+		la.insert(Loc{17, 1}, []byte{'\n'}, nil)
+		assert.Equal(t, la.LinesNum(), 6)
+		sub1 := la.Substr(Loc{0, 1}, Loc{17, 1})
+		sub2 := la.Substr(Loc{0, 2}, Loc{30, 2})
 
-	assert.Equal(t, []byte("He wes Leovenaðes"), sub1)
-	assert.Equal(t, []byte(" sone -- liðe him be Drihten."), sub2)
+		assert.Equal(t, []byte("He wes Leovenaðes"), sub1)
+		assert.Equal(t, []byte(" sone -- liðe him be Drihten."), sub2)
+	}
 }
 
 func TestJoin(t *testing.T) {
-	la.remove(Loc{47, 1}, Loc{0, 2})
-	assert.Equal(t, len(la.lines), 5)
-	sub := la.Substr(Loc{0, 1}, Loc{47, 1})
-	bytes := la.Bytes()
+	for _, la := range las {
+		la.remove(Loc{47, 1}, Loc{0, 2})
+		assert.Equal(t, la.LinesNum(), 5)
+		sub := la.Substr(Loc{0, 1}, Loc{47, 1})
+		bytes := la.Bytes()
 
-	assert.Equal(t, []byte("He wes Leovenaðes sone -- liðe him be Drihten."), sub)
-	assert.Equal(t, unicode_txt, string(bytes))
+		assert.Equal(t, []byte("He wes Leovenaðes sone -- liðe him be Drihten."), sub)
+		assert.Equal(t, unicode_txt, string(bytes))
+	}
 }
 
 func TestInsert(t *testing.T) {
-	la.insert(Loc{20, 3}, []byte(" foobar"))
-	sub1 := la.Substr(Loc{0, 3}, Loc{50, 3})
+	for _, la := range las {
+		la.insert(Loc{20, 3}, []byte(" foobar"), nil)
+		sub1 := la.Substr(Loc{0, 3}, Loc{50, 3})
 
-	assert.Equal(t, []byte("Uppen Sevarne staþe, foobar sel þar him þuhte,"), sub1)
+		assert.Equal(t, []byte("Uppen Sevarne staþe, foobar sel þar him þuhte,"), sub1)
 
-	la.insert(Loc{25, 2}, []byte("H̼̥̯͇͙̕͘͞e̸̦̞̠̣̰͙̼̥̦̼̖̬͕͕̰̯̫͇̕ĺ̜̠̩̯̯͙̼̭̠͕̮̞͜l̶͓̫̞̮͈͞ͅo̸͔͙̳̠͈̮̼̳͙̥̲͜͠"))
+		la.insert(Loc{25, 2}, []byte("H̼̥̯͇͙̕͘͞e̸̦̞̠̣̰͙̼̥̦̼̖̬͕͕̰̯̫͇̕ĺ̜̠̩̯̯͙̼̭̠͕̮̞͜l̶͓̫̞̮͈͞ͅo̸͔͙̳̠͈̮̼̳͙̥̲͜͠"), nil)
 
-	sub2 := la.Substr(Loc{0, 2}, Loc{60, 2})
-	assert.Equal(t, []byte("He wonede at Ernleȝe at æH̼̥̯͇͙̕͘͞e̸̦̞̠̣̰͙̼̥̦̼̖̬͕͕̰̯̫͇̕ĺ̜̠̩̯̯͙̼̭̠͕̮̞͜l̶͓̫̞̮͈͞ͅo̸͔͙̳̠͈̮̼̳͙̥̲͜͠ðelen are chirechen,"), sub2)
+		sub2 := la.Substr(Loc{0, 2}, Loc{60, 2})
+		assert.Equal(t, []byte("He wonede at Ernleȝe at æH̼̥̯͇͙̕͘͞e̸̦̞̠̣̰͙̼̥̦̼̖̬͕͕̰̯̫͇̕ĺ̜̠̩̯̯͙̼̭̠͕̮̞͜l̶͓̫̞̮͈͞ͅo̸͔͙̳̠͈̮̼̳͙̥̲͜͠ðelen are chirechen,"), sub2)
+	}
 }
 
 func TestRemove(t *testing.T) {
-	la.remove(Loc{20, 3}, Loc{27, 3})
-	la.remove(Loc{25, 2}, Loc{30, 2})
+	for _, la := range las {
+		la.remove(Loc{20, 3}, Loc{27, 3})
+		la.remove(Loc{25, 2}, Loc{30, 2})
 
-	bytes := la.Bytes()
-	assert.Equal(t, unicode_txt, string(bytes))
+		bytes := la.Bytes()
+		assert.Equal(t, unicode_txt, string(bytes))
+	}
+}
+
+func TestPieceTableBlocks(t *testing.T) {
+	n := 3 * pieceBlockSize
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = strings.Repeat("x", i%7)
+	}
+	txt := strings.Join(lines, "\r\n")
+	la := NewPieceTableLineArray(uint64(len(txt)), FFAuto, strings.NewReader(txt))
+
+	assert.Equal(t, FileFormat(FFDos), la.Endings)
+	assert.Equal(t, n, la.LinesNum())
+	assert.Equal(t, []byte(txt), la.Bytes())
+
+	// grow a single block past twice the block size so that it is split
+	la.insert(Loc{0, 10}, []byte(strings.Repeat("y\n", 2*pieceBlockSize)), nil)
+	assert.Equal(t, n+2*pieceBlockSize, la.LinesNum())
+	assert.Equal(t, []byte("y"), la.LineBytes(10+2*pieceBlockSize-1))
+	assert.Equal(t, []byte(lines[10]), la.LineBytes(10+2*pieceBlockSize))
+
+	// delete across several blocks
+	la.remove(Loc{0, 10}, Loc{0, 10 + 2*pieceBlockSize})
+	assert.Equal(t, []byte(txt), la.Bytes())
+
+	la.remove(Loc{1, 1}, Loc{0, n - 1})
+	assert.Equal(t, 2, la.LinesNum())
+	assert.Equal(t, []byte(lines[0]+"\r\nx"+lines[n-1]), la.Bytes())
+}
+
+func TestMixedEndings(t *testing.T) {
+	txt := "one\r\ntwo\nthree\r\nfour\rfive\r\n"
+	for _, la := range []*LineArray{
+		NewLineArray(uint64(len(txt)), FFAuto, strings.NewReader(txt)),
+		NewPieceTableLineArray(uint64(len(txt)), FFAuto, strings.NewReader(txt)),
+	} {
+		assert.Equal(t, FileFormat(FFDos), la.Endings)
+		assert.True(t, la.MixedEndings())
+		assert.Equal(t, 6, la.LinesNum())
+		assert.Equal(t, FileFormat(FFUnix), la.LineEnding(1))
+		assert.Equal(t, FileFormat(FFMac), la.LineEnding(3))
+		assert.Equal(t, []byte(txt), la.Bytes())
+
+		// a split line keeps its line ending after the new line
+		la.insert(Loc{1, 1}, []byte{'\n'}, nil)
+		assert.Equal(t, []byte("one\r\nt\r\nwo\nthree\r\nfour\rfive\r\n"), la.Bytes())
+
+		// the line endings of the removed lines are given back
+		text, endings := la.remove(Loc{0, 2}, Loc{0, 5})
+		assert.Equal(t, []byte("wo\nthree\nfour\n"), text)
+		assert.Equal(t, []FileFormat{FFUnix, FFAuto, FFMac}, endings)
+		assert.False(t, la.MixedEndings())
+		la.insert(Loc{0, 2}, text, endings)
+		assert.Equal(t, []byte("one\r\nt\r\nwo\nthree\r\nfour\rfive\r\n"), la.Bytes())
+
+		la.SetEndings(FFUnix)
+		assert.False(t, la.MixedEndings())
+		assert.Equal(t, []byte("one\nt\nwo\nthree\nfour\nfive\n"), la.Bytes())
+	}
+}
+
+func TestPieceTableCompact(t *testing.T) {
+	la := NewPieceTableLineArray(3, FFAuto, strings.NewReader("a\nb"))
+	pt := la.store.(*pieceTable)
+
+	// every edit appends the whole line, until the old copies are dropped
+	value := []byte(strings.Repeat("x", pieceCompactSize/4))
+	for i := 0; i < 16; i++ {
+		la.insert(Loc{0, 0}, value, nil)
+	}
+	assert.Equal(t, 16*len(value)+1, len(la.LineBytes(0)))
+	assert.Equal(t, []byte("b"), la.LineBytes(1))
+	assert.Less(t, len(pt.add), 3*16*len(value))
 }
@@ -0,0 +1,68 @@
+package buffer
+
+import (
+	"strings"
+
+	"github.com/micro-editor/micro/v2/internal/util"
+	dmp "github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// A DiffHunk replaces the lines [AStart, AEnd) of a buffer with the lines
+// [BStart, BEnd) of another buffer
+type DiffHunk struct {
+	AStart, AEnd int
+	BStart, BEnd int
+}
+
+// lineText returns the text of the buffer with every line terminated by a
+// newline, so that the lines of the text are the lines of the buffer
+func (b *Buffer) lineText() string {
+	var sb strings.Builder
+	for i := 0; i < b.LinesNum(); i++ {
+		sb.Write(b.LineBytes(i))
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// DiffHunks returns the hunks changing the lines of the buffer a into the
+// lines of the buffer b
+func DiffHunks(a, b *Buffer) []DiffHunk {
+	lhunks := diffLines(a.lineText(), b.lineText())
+	hunks := make([]DiffHunk, len(lhunks))
+	for i, h := range lhunks {
+		hunks[i] = DiffHunk{h.baseStart, h.baseEnd, h.start, h.end}
+	}
+	return hunks
+}
+
+// LineChanges returns the ranges of characters which differ between the
+// lines a and b, in a and in b respectively
+func LineChanges(a, b string) ([][2]int, [][2]int) {
+	differ := dmp.New()
+	diffs := differ.DiffCleanupSemantic(differ.DiffMain(a, b, false))
+
+	var aRanges, bRanges [][2]int
+	i, j := 0, 0
+	for _, d := range diffs {
+		n := util.CharacterCountInString(d.Text)
+		switch d.Type {
+		case dmp.DiffEqual:
+			i += n
+			j += n
+		case dmp.DiffDelete:
+			aRanges = append(aRanges, [2]int{i, i + n})
+			i += n
+		case dmp.DiffInsert:
+			bRanges = append(bRanges, [2]int{j, j + n})
+			j += n
+		}
+	}
+	return aRanges, bRanges
+}
+
+// Version returns a number which changes whenever the text of the buffer is
+// modified
+func (b *SharedBuffer) Version() uint64 {
+	return b.version
+}
@@ -0,0 +1,32 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffHunks(t *testing.T) {
+	a := NewBufferFromString("a\nb\nc\nd\ne", "", BTDefault)
+	b := NewBufferFromString("a\nB\nc\ne\nf", "", BTDefault)
+	defer a.Close()
+	defer b.Close()
+
+	assert.Equal(t, []DiffHunk{
+		{1, 2, 1, 2},
+		{3, 4, 3, 3},
+		{5, 5, 4, 5},
+	}, DiffHunks(a, b))
+
+	assert.Empty(t, DiffHunks(a, a))
+}
+
+func TestLineChanges(t *testing.T) {
+	aRanges, bRanges := LineChanges("the quick brown fox", "the slow brown fox jumps")
+	assert.Equal(t, [][2]int{{4, 9}}, aRanges)
+	assert.Equal(t, [][2]int{{4, 8}, {18, 24}}, bRanges)
+
+	aRanges, bRanges = LineChanges("héllo", "héllo")
+	assert.Empty(t, aRanges)
+	assert.Empty(t, bRanges)
+}
@@ -0,0 +1,160 @@
+package buffer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/micro-editor/micro/v2/internal/config"
+	"github.com/micro-editor/micro/v2/internal/screen"
+	"github.com/micro-editor/micro/v2/internal/util"
+)
+
+const LockMsg = `The file
+
+%s
+
+is being edited by another instance of micro (process %d on %s).
+Saving it from both instances will overwrite the changes of one of them.
+
+* 'read-only' will open the file read-only.
+* 'edit' will open the file for editing anyway.
+* 'recover' will open the file with the unsaved changes of the other
+  instance, taken from its backup if there is one.
+
+Options: [r]ead-only, [e]dit, re[c]over: `
+
+// A fileLock records the instance of micro editing a file
+type fileLock struct {
+	Pid  int
+	Host string
+	Path string
+}
+
+func lockDir() string {
+	return filepath.Join(config.ConfigDir, "locks")
+}
+
+// lockPath returns the lock file of the file at the given absolute path
+func lockPath(absPath string) string {
+	name, _ := util.DetermineEscapePath(lockDir(), absPath)
+	return name
+}
+
+// currentLock returns the lock of this instance
+func currentLock(absPath string) fileLock {
+	host, _ := os.Hostname()
+	return fileLock{os.Getpid(), host, absPath}
+}
+
+// readLock returns the lock of the file at the given absolute path, or nil
+// if it has no lock or its lock is stale, i.e. its instance is not running
+// anymore. Stale locks are removed.
+func readLock(absPath string) *fileLock {
+	path := lockPath(absPath)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var l fileLock
+	if err := json.Unmarshal(data, &l); err != nil {
+		os.Remove(path)
+		return nil
+	}
+
+	// the process of a lock of another host can't be checked, so such a
+	// lock is never stale
+	cur := currentLock(absPath)
+	if l.Host == cur.Host && l.Pid != cur.Pid && !processRunning(l.Pid) {
+		os.Remove(path)
+		return nil
+	}
+	return &l
+}
+
+// lockOwner returns the lock of the file of the buffer if another instance
+// is editing it
+func (b *SharedBuffer) lockOwner() *fileLock {
+	if !b.lockable() {
+		return nil
+	}
+	l := readLock(b.AbsPath)
+	if l == nil || *l == currentLock(b.AbsPath) {
+		return nil
+	}
+	return l
+}
+
+func (b *SharedBuffer) lockable() bool {
+	return b.Settings["lockfile"].(bool) && b.Path != "" && (b.Type == BTDefault || b.Type == BTHex)
+}
+
+// LockFile creates the lock file of the file of the buffer, which tells other
+// instances that this one is editing it
+func (b *SharedBuffer) LockFile() error {
+	if !b.lockable() {
+		return nil
+	}
+	// the lock of another instance is never taken over, even if the user
+	// chose to edit the file anyway
+	if l := readLock(b.AbsPath); l != nil && *l != currentLock(b.AbsPath) {
+		return nil
+	}
+	if err := os.MkdirAll(lockDir(), os.ModePerm); err != nil {
+		return err
+	}
+	data, err := json.Marshal(currentLock(b.AbsPath))
+	if err != nil {
+		return err
+	}
+	if err := util.SafeWrite(lockPath(b.AbsPath), data, true); err != nil {
+		return err
+	}
+	b.locked = true
+	return nil
+}
+
+// UnlockFile removes the lock file of the file of the buffer, if the buffer
+// created it and no other buffer of this instance still holds it
+func (b *SharedBuffer) UnlockFile() {
+	if !b.locked {
+		return
+	}
+	b.locked = false
+	for _, buf := range OpenBuffers {
+		if buf.SharedBuffer != b && buf.AbsPath == b.AbsPath && buf.locked {
+			return
+		}
+	}
+	if l := readLock(b.AbsPath); l != nil && *l == currentLock(b.AbsPath) {
+		os.Remove(lockPath(b.AbsPath))
+	}
+}
+
+// resolveLock asks the user what to do with a file which is locked by
+// another instance. It returns true if the backup of the other instance has
+// been applied to the buffer. The buffer is made read-only if the user
+// chooses not to edit the file.
+func (b *SharedBuffer) resolveLock(l *fileLock, fsize int64) bool {
+	msg := fmt.Sprintf(LockMsg, b.Path, l.Pid, l.Host)
+	choice := screen.TermPrompt(msg, []string{"r", "e", "c", "read-only", "edit", "recover"}, true)
+
+	switch choice % 3 {
+	case 0:
+		b.Type.Readonly = true
+		return false
+	case 2:
+		// the backup is left for the other instance, which keeps updating it
+		backupfile, _ := util.DetermineEscapePath(b.backupDir(), b.AbsPath)
+		backup, err := os.Open(backupfile)
+		if err != nil {
+			screen.TermMessage("No backup found for ", b.Path)
+			break
+		}
+		defer backup.Close()
+		b.recoverBackup(fsize, backup)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,15 @@
+//go:build plan9 || nacl || windows
+
+package buffer
+
+import "os"
+
+// processRunning returns true if a process with the given pid exists
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
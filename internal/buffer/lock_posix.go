@@ -0,0 +1,14 @@
+//go:build linux || darwin || dragonfly || solaris || openbsd || netbsd || freebsd
+
+package buffer
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processRunning returns true if a process with the given pid exists
+func processRunning(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,63 @@
+package buffer
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/micro-editor/micro/v2/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeLock(t *testing.T, l fileLock) {
+	data, err := json.Marshal(l)
+	assert.NoError(t, err)
+	assert.NoError(t, os.MkdirAll(lockDir(), os.ModePerm))
+	assert.NoError(t, os.WriteFile(lockPath(l.Path), data, 0644))
+}
+
+func TestLockFile(t *testing.T) {
+	defer func(dir string) {
+		config.ConfigDir = dir
+	}(config.ConfigDir)
+	config.ConfigDir = t.TempDir()
+
+	path := filepath.Join(t.TempDir(), "file.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("text"), 0644))
+
+	b, err := NewBufferFromFile(path, BTDefault)
+	assert.NoError(t, err)
+	cur := currentLock(b.AbsPath)
+	if assert.NotNil(t, readLock(b.AbsPath)) {
+		assert.Equal(t, cur, *readLock(b.AbsPath))
+	}
+	assert.Nil(t, b.lockOwner())
+
+	b.Close()
+	assert.Nil(t, readLock(b.AbsPath))
+
+	// the process of another host is assumed to be running
+	other := fileLock{cur.Pid, cur.Host + "-other", b.AbsPath}
+	writeLock(t, other)
+	if assert.NotNil(t, readLock(b.AbsPath)) {
+		assert.Equal(t, other, *readLock(b.AbsPath))
+	}
+
+	// a foreign lock is not taken over nor removed
+	assert.NoError(t, b.LockFile())
+	assert.False(t, b.locked)
+	b.UnlockFile()
+	if assert.NotNil(t, readLock(b.AbsPath)) {
+		assert.Equal(t, other, *readLock(b.AbsPath))
+	}
+
+	// the lock of a process which has exited is stale
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	assert.NoError(t, cmd.Run())
+	writeLock(t, fileLock{cmd.ProcessState.Pid(), cur.Host, b.AbsPath})
+	assert.Nil(t, readLock(b.AbsPath))
+	_, err = os.Stat(lockPath(b.AbsPath))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,194 @@
+package buffer
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/micro-editor/micro/v2/internal/util"
+	dmp "github.com/sergi/go-diff/diffmatchpatch"
+)
+
+const (
+	conflictOurs   = "<<<<<<< buffer\n"
+	conflictSep    = "=======\n"
+	conflictTheirs = ">>>>>>> file on disk\n"
+)
+
+// A lineHunk replaces the lines [baseStart, baseEnd) of a base text with
+// the lines [start, end) of another text
+type lineHunk struct {
+	baseStart, baseEnd int
+	start, end         int
+}
+
+// A mergeHunk replaces the lines [start, end) of our text with text
+type mergeHunk struct {
+	start, end int
+	text       string
+}
+
+// splitLines splits a text after every newline
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns the hunks changing the lines of base into the lines of
+// other
+func diffLines(base, other string) []lineHunk {
+	differ := dmp.New()
+	baseRunes, otherRunes, _ := differ.DiffLinesToRunes(base, other)
+	diffs := differ.DiffMainRunes(baseRunes, otherRunes, false)
+
+	var hunks []lineHunk
+	i, j := 0, 0
+	for _, d := range diffs {
+		n := len([]rune(d.Text))
+		if d.Type == dmp.DiffEqual {
+			i += n
+			j += n
+			continue
+		}
+		// a deletion followed by an insertion is a single hunk
+		if len(hunks) == 0 || hunks[len(hunks)-1].baseEnd != i || hunks[len(hunks)-1].end != j {
+			hunks = append(hunks, lineHunk{i, i, j, j})
+		}
+		h := &hunks[len(hunks)-1]
+		if d.Type == dmp.DiffDelete {
+			i += n
+			h.baseEnd = i
+		} else {
+			j += n
+			h.end = j
+		}
+	}
+	return hunks
+}
+
+// withNewline terminates a non-empty text with a newline
+func withNewline(s string) string {
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		return s + "\n"
+	}
+	return s
+}
+
+// merge3 merges the changes from base to theirs into ours, which has been
+// changed from base too. It returns the hunks to apply to ours and the
+// number of conflicts, i.e. of places changed differently by both sides,
+// which are replaced by both versions between conflict markers. Changes
+// touching the same or adjacent lines conflict.
+func merge3(base, ours, theirs string) ([]mergeHunk, int) {
+	oursLines, theirsLines := splitLines(ours), splitLines(theirs)
+	oursHunks, theirsHunks := diffLines(base, ours), diffLines(base, theirs)
+
+	var hunks []mergeHunk
+	conflicts := 0
+	// shift is the difference between the line numbers of a side and the
+	// ones of the base before the current group of hunks
+	oursShift, theirsShift := 0, 0
+	i, j := 0, 0
+	for i < len(oursHunks) || j < len(theirsHunks) {
+		// a group starts with the first hunk of either side, and gathers the
+		// hunks of both sides which overlap or touch it
+		var start, end int
+		if j == len(theirsHunks) || i < len(oursHunks) && oursHunks[i].baseStart <= theirsHunks[j].baseStart {
+			start, end = oursHunks[i].baseStart, oursHunks[i].baseEnd
+		} else {
+			start, end = theirsHunks[j].baseStart, theirsHunks[j].baseEnd
+		}
+		var og, tg []lineHunk
+		for {
+			if i < len(oursHunks) && oursHunks[i].baseStart <= end {
+				end = util.Max(end, oursHunks[i].baseEnd)
+				og = append(og, oursHunks[i])
+				i++
+			} else if j < len(theirsHunks) && theirsHunks[j].baseStart <= end {
+				end = util.Max(end, theirsHunks[j].baseEnd)
+				tg = append(tg, theirsHunks[j])
+				j++
+			} else {
+				break
+			}
+		}
+
+		// the lines of both sides which replace the base lines [start, end)
+		oStart, oEnd := start+oursShift, end+oursShift
+		for _, h := range og {
+			oEnd += (h.end - h.start) - (h.baseEnd - h.baseStart)
+		}
+		tStart, tEnd := start+theirsShift, end+theirsShift
+		for _, h := range tg {
+			tEnd += (h.end - h.start) - (h.baseEnd - h.baseStart)
+		}
+		oursShift = oEnd - end
+		theirsShift = tEnd - end
+
+		if len(tg) == 0 {
+			continue
+		}
+		theirsText := strings.Join(theirsLines[tStart:tEnd], "")
+		if len(og) == 0 {
+			hunks = append(hunks, mergeHunk{oStart, oEnd, theirsText})
+			continue
+		}
+		oursText := strings.Join(oursLines[oStart:oEnd], "")
+		if oursText == theirsText {
+			continue
+		}
+		conflicts++
+		hunks = append(hunks, mergeHunk{oStart, oEnd, conflictOurs + withNewline(oursText) +
+			conflictSep + withNewline(theirsText) + conflictTheirs})
+	}
+	return hunks, conflicts
+}
+
+// MergeFromDisk merges the changes of the file on disk since it was last
+// loaded or saved into the buffer, keeping the unsaved changes of the
+// buffer, as a single undoable edit. The places changed both in the buffer
+// and on disk are replaced by both versions between conflict markers, and
+// their number is returned.
+func (b *Buffer) MergeFromDisk() (int, error) {
+	if b.mergeBase == nil {
+		return 0, errors.New("The text of the file when it was loaded is not known")
+	}
+	theirs, _, err := b.readFile()
+	if err != nil {
+		return 0, err
+	}
+
+	hunks, conflicts := merge3(string(b.mergeBase), string(b.Bytes()), theirs)
+	deltas := make([]Delta, 0, len(hunks))
+	// the last hunks are applied first so that the locations of the other
+	// ones don't move
+	for k := len(hunks) - 1; k >= 0; k-- {
+		h := hunks[k]
+		deltas = append(deltas, Delta{[]byte(h.text), b.lineStart(h.start), b.lineStart(h.end), nil})
+	}
+	b.MultipleReplace(deltas)
+
+	b.mergeBase = []byte(theirs)
+	b.DeletedOnDisk = false
+	b.UpdateModTime()
+	b.RelocateCursors()
+	return conflicts, nil
+}
+
+// CanMerge returns true if the changes of the file on disk can be merged
+// into the buffer, i.e. if the text of the file when it was last loaded or
+// saved is known
+func (b *Buffer) CanMerge() bool {
+	return b.mergeBase != nil
+}
+
+// lineStart returns the location of the start of a line, or the end of the
+// buffer for the line after the last one
+func (b *Buffer) lineStart(line int) Loc {
+	if line >= b.LinesNum() {
+		return b.End()
+	}
+	return Loc{0, line}
+}
@@ -0,0 +1,79 @@
+package buffer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// applyMerge returns ours with the hunks of merge3 applied
+func applyMerge(base, ours, theirs string) (string, int) {
+	hunks, conflicts := merge3(base, ours, theirs)
+	lines := splitLines(ours)
+	for k := len(hunks) - 1; k >= 0; k-- {
+		h := hunks[k]
+		lines = append(lines[:h.start], append([]string{h.text}, lines[h.end:]...)...)
+	}
+	merged := ""
+	for _, l := range lines {
+		merged += l
+	}
+	return merged, conflicts
+}
+
+func TestMerge3(t *testing.T) {
+	base := "a\nb\nc\nd\ne\nf\n"
+
+	// changes far enough from each other are both kept
+	merged, conflicts := applyMerge(base, "A\nb\nc\nd\ne\nf\n", "a\nb\nc\nd\nE\nf\nG\n")
+	assert.Equal(t, "A\nb\nc\nd\nE\nf\nG\n", merged)
+	assert.Equal(t, 0, conflicts)
+
+	// lines added and removed on both sides
+	merged, conflicts = applyMerge(base, "a\nx\nb\nc\nd\ne\nf\n", "a\nb\nc\nd\nf\n")
+	assert.Equal(t, "a\nx\nb\nc\nd\nf\n", merged)
+	assert.Equal(t, 0, conflicts)
+
+	// the same change on both sides
+	merged, conflicts = applyMerge(base, "a\nb\nC\nd\ne\nf\n", "a\nb\nC\nd\ne\nf\n")
+	assert.Equal(t, "a\nb\nC\nd\ne\nf\n", merged)
+	assert.Equal(t, 0, conflicts)
+
+	// different changes of the same line
+	merged, conflicts = applyMerge(base, "a\nb\nours\nd\ne\nf\n", "a\nb\ntheirs\nd\ne\nF\n")
+	assert.Equal(t, "a\nb\n"+conflictOurs+"ours\n"+conflictSep+"theirs\n"+conflictTheirs+"d\ne\nF\n", merged)
+	assert.Equal(t, 1, conflicts)
+
+	// the last line has no newline
+	merged, conflicts = applyMerge("a\nb\nc", "A\nb\nc", "a\nb\nc\nd")
+	assert.Equal(t, "A\nb\nc\nd", merged)
+	assert.Equal(t, 0, conflicts)
+
+	merged, conflicts = applyMerge("a\nb", "a\nB", "a\nb\nc")
+	assert.Equal(t, "a\n"+conflictOurs+"B\n"+conflictSep+"b\nc\n"+conflictTheirs, merged)
+	assert.Equal(t, 1, conflicts)
+}
+
+func TestMergeFromDisk(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("one\ntwo\nthree\nfour\n"), 0644))
+
+	b, err := NewBufferFromFile(path, BTDefault)
+	assert.NoError(t, err)
+	defer b.Close()
+
+	b.Insert(Loc{0, 0}, "zero\n")
+	assert.NoError(t, os.WriteFile(path, []byte("one\ntwo\nthree\nFOUR\n"), 0644))
+
+	conflicts, err := b.MergeFromDisk()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, conflicts)
+	assert.Equal(t, "zero\none\ntwo\nthree\nFOUR\n", string(b.Bytes()))
+	assert.True(t, b.Modified())
+
+	// the merge is undone at once
+	b.UndoOneEvent()
+	assert.Equal(t, "zero\none\ntwo\nthree\nfour\n", string(b.Bytes()))
+}
@@ -92,3 +92,80 @@ var prompt Messager
 func SetMessager(m Messager) {
 	prompt = m
 }
+
+// CountMessages returns the number of messages of the given type
+func (b *Buffer) CountMessages(kind MsgType) int {
+	n := 0
+	for _, m := range b.Messages {
+		if m.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// MessageLoc returns the location where a message starts, clamped to the
+// buffer. A message of a whole line starts at the beginning of the line.
+func (b *Buffer) MessageLoc(m *Message) Loc {
+	loc := m.Start
+	if loc.X < 0 {
+		loc.X = 0
+	}
+	return clamp(loc, b.LineArray)
+}
+
+// NextMessage returns the first message after loc of the given type or of
+// a more severe type, wrapping around the end of the buffer. It returns
+// nil if there is no such message.
+func (b *Buffer) NextMessage(loc Loc, kind MsgType) *Message {
+	var next, first *Message
+	for _, m := range b.Messages {
+		if m.Kind < kind {
+			continue
+		}
+		start := b.MessageLoc(m)
+		if start.GreaterThan(loc) && (next == nil || start.LessThan(b.MessageLoc(next))) {
+			next = m
+		}
+		if first == nil || start.LessThan(b.MessageLoc(first)) {
+			first = m
+		}
+	}
+	if next == nil {
+		return first
+	}
+	return next
+}
+
+// PrevMessage is the opposite of NextMessage: it returns the last message
+// before loc of the given type or of a more severe type
+func (b *Buffer) PrevMessage(loc Loc, kind MsgType) *Message {
+	var prev, last *Message
+	for _, m := range b.Messages {
+		if m.Kind < kind {
+			continue
+		}
+		start := b.MessageLoc(m)
+		if start.LessThan(loc) && (prev == nil || start.GreaterThan(b.MessageLoc(prev))) {
+			prev = m
+		}
+		if last == nil || start.GreaterThan(b.MessageLoc(last)) {
+			last = m
+		}
+	}
+	if prev == nil {
+		return last
+	}
+	return prev
+}
+
+// MessagesAt returns the messages on the given line
+func (b *Buffer) MessagesAt(line int) []*Message {
+	var msgs []*Message
+	for _, m := range b.Messages {
+		if line >= m.Start.Y && line <= m.End.Y {
+			msgs = append(msgs, m)
+		}
+	}
+	return msgs
+}
@@ -0,0 +1,38 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextMessage(t *testing.T) {
+	b := NewBufferFromString("one\ntwo\nthree\nfour", "", BTDefault)
+	defer b.Close()
+
+	warning := NewMessageAtLine("lint", "unused", 2, MTWarning)
+	err1 := NewMessage("lint", "bad\ncall", Loc{2, 2}, Loc{4, 2}, MTError)
+	err2 := NewMessageAtLine("lint", "missing", 4, MTError)
+	b.AddMessage(err2)
+	b.AddMessage(warning)
+	b.AddMessage(err1)
+
+	assert.Equal(t, warning, b.NextMessage(Loc{0, 0}, MTInfo))
+	assert.Equal(t, err1, b.NextMessage(Loc{0, 1}, MTInfo))
+	assert.Equal(t, err1, b.NextMessage(Loc{0, 0}, MTError))
+	assert.Equal(t, err2, b.NextMessage(Loc{2, 2}, MTError))
+	// wraps around the end
+	assert.Equal(t, warning, b.NextMessage(Loc{0, 3}, MTInfo))
+
+	assert.Equal(t, err1, b.PrevMessage(Loc{0, 3}, MTInfo))
+	assert.Equal(t, warning, b.PrevMessage(Loc{2, 2}, MTInfo))
+	assert.Equal(t, err2, b.PrevMessage(Loc{2, 2}, MTError))
+
+	assert.Equal(t, Loc{0, 1}, b.MessageLoc(warning))
+	assert.Equal(t, []*Message{err1}, b.MessagesAt(2))
+	assert.Equal(t, 2, b.CountMessages(MTError))
+	assert.Equal(t, 1, b.CountMessages(MTWarning))
+
+	b.ClearMessages("lint")
+	assert.Nil(t, b.NextMessage(Loc{0, 0}, MTInfo))
+}
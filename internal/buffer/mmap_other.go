@@ -0,0 +1,15 @@
+//go:build plan9 || nacl || windows
+
+package buffer
+
+import (
+	"errors"
+	"os"
+)
+
+// mapFile is not supported, so the file is read instead
+func mapFile(f *os.File, size int64) ([]byte, error) {
+	return nil, errors.New("memory mapping not supported")
+}
+
+func unmapFile(data []byte) {}
@@ -0,0 +1,18 @@
+//go:build linux || darwin || dragonfly || solaris || openbsd || netbsd || freebsd
+
+package buffer
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps the first size bytes of a file into memory, read-only
+func mapFile(f *os.File, size int64) ([]byte, error) {
+	return syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+// unmapFile removes a mapping returned by mapFile
+func unmapFile(data []byte) {
+	syscall.Munmap(data)
+}
@@ -0,0 +1,371 @@
+package buffer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"unicode/utf8"
+
+	humanize "github.com/dustin/go-humanize"
+	"github.com/micro-editor/micro/v2/internal/util"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// pieceBlockSize is the number of lines a piece table block is split into
+// when it grows beyond twice this size
+const pieceBlockSize = 4096
+
+// A piece references the text of a single line, either in the original
+// contents of the file or in the append buffer
+type piece struct {
+	// off is the offset of the line in the original contents, or
+	// -(offset+1) of the line in the append buffer if the line was edited
+	off int
+	len int
+	// eol is the line ending of the line, or FFAuto if it is the one of
+	// the file format
+	eol FileFormat
+	// meta is allocated only once the line gets a highlight state,
+	// a match or search results
+	meta *lineMeta
+}
+
+// pieceCompactSize is the size of the garbage in the append buffer of a
+// piece table above which the buffer is compacted
+const pieceCompactSize = 1 << 20
+
+// pieceTable is a line store meant for very large files. The file is
+// mapped into memory or read once into a single read-only slice (the
+// original) and never copied again: every line is just a small piece
+// pointing into it. Edited lines are appended to a separate append buffer,
+// and their piece is pointed there. The pieces are kept in blocks so that
+// inserting or deleting lines only moves the pieces of one block.
+type pieceTable struct {
+	orig []byte
+	add  []byte
+	// garbage is the number of bytes of the append buffer which are no
+	// longer used by any line
+	garbage int
+
+	// mapped is the memory mapping of the file if the original is mapped,
+	// and file is the file, which must not be truncated while it is mapped
+	mapped []byte
+	file   os.FileInfo
+
+	blocks [][]piece
+	// starts[i] is the number of the first line in blocks[i]
+	starts []int
+	nlines int
+
+	// lock protects the blocks and the append buffer, since the highlighter
+	// reads the lines and sets their metadata from its own goroutine
+	lock sync.RWMutex
+}
+
+// NewPieceTableLineArray returns a new line array from a reader, backed
+// by a piece table instead of a slice of lines. The whole text is read at
+// once.
+func NewPieceTableLineArray(size uint64, endings FileFormat, reader io.Reader) *LineArray {
+	buf := bytes.NewBuffer(make([]byte, 0, size+bytes.MinRead))
+	buf.ReadFrom(reader)
+	return newPieceTableLineArray(buf.Bytes(), endings)
+}
+
+// mapPieceTableLineArray returns a new line array backed by a piece table
+// whose original is the given file mapped into memory. It fails if the file
+// cannot be mapped or if it is not valid UTF-8, which would need to be
+// decoded. The mapping is kept until the line array is garbage collected.
+func mapPieceTableLineArray(f *os.File, size int64, endings FileFormat) (*LineArray, bool, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, false, err
+	}
+	data, err := mapFile(f, size)
+	if err != nil {
+		return nil, false, err
+	}
+	text, bom := data, false
+	if utf8BOM := bomOf(unicode.UTF8); bytes.HasPrefix(text, utf8BOM) {
+		text, bom = text[len(utf8BOM):], true
+	}
+	if !utf8.Valid(text) {
+		unmapFile(data)
+		return nil, false, errors.New("invalid UTF-8")
+	}
+
+	la := newPieceTableLineArray(text, endings)
+	pt := la.store.(*pieceTable)
+	pt.mapped, pt.file = data, info
+	runtime.SetFinalizer(pt, func(pt *pieceTable) {
+		unmapFile(pt.mapped)
+	})
+	return la, bom, nil
+}
+
+func newPieceTableLineArray(orig []byte, endings FileFormat) *LineArray {
+	la := new(LineArray)
+	la.initsize = uint64(len(orig))
+
+	pt := new(pieceTable)
+	pt.orig = orig
+	pt.add = []byte{}
+
+	var counts endingCounts
+
+	block := make([]piece, 0, pieceBlockSize)
+	start := 0
+	for {
+		i := bytes.IndexAny(pt.orig[start:], "\r\n")
+		if i < 0 {
+			block = append(block, piece{off: start, len: len(pt.orig) - start})
+			break
+		}
+
+		// Even if the file format is set to DOS, the '\r' is not a part
+		// of the line
+		end := start + i
+		eol := FileFormat(FFUnix)
+		if pt.orig[end] == '\r' {
+			eol = FFMac
+			if end+1 < len(pt.orig) && pt.orig[end+1] == '\n' {
+				eol = FFDos
+				i++
+			}
+		}
+		counts.add(eol)
+
+		block = append(block, piece{off: start, len: end - start, eol: eol})
+		start += i + 1
+
+		if len(block) == pieceBlockSize {
+			pt.blocks = append(pt.blocks, block)
+			block = make([]piece, 0, pieceBlockSize)
+		}
+	}
+	pt.blocks = append(pt.blocks, block)
+	pt.updateStarts(0)
+
+	la.store = pt
+	la.detectEndings(endings, counts)
+	return la
+}
+
+// updateStarts recomputes the line numbers of the blocks from block bi on
+func (pt *pieceTable) updateStarts(bi int) {
+	pt.starts = pt.starts[:util.Min(bi, len(pt.starts))]
+	n := 0
+	if bi > 0 {
+		n = pt.starts[bi-1] + len(pt.blocks[bi-1])
+	}
+	for i := bi; i < len(pt.blocks); i++ {
+		pt.starts = append(pt.starts, n)
+		n += len(pt.blocks[i])
+	}
+	pt.nlines = n
+}
+
+// find returns the block containing line n and the index of the line in it
+func (pt *pieceTable) find(n int) (int, int) {
+	bi := sort.Search(len(pt.starts), func(i int) bool {
+		return pt.starts[i] > n
+	}) - 1
+	return bi, n - pt.starts[bi]
+}
+
+func (pt *pieceTable) piece(n int) *piece {
+	bi, i := pt.find(n)
+	return &pt.blocks[bi][i]
+}
+
+func (pt *pieceTable) len() int {
+	pt.lock.RLock()
+	defer pt.lock.RUnlock()
+	return pt.nlines
+}
+
+func (pt *pieceTable) data(n int) []byte {
+	pt.lock.RLock()
+	defer pt.lock.RUnlock()
+	return pt.pieceData(pt.piece(n))
+}
+
+func (pt *pieceTable) pieceData(p *piece) []byte {
+	if p.off >= 0 {
+		end := p.off + p.len
+		return pt.orig[p.off:end:end]
+	}
+	off := -p.off - 1
+	end := off + p.len
+	return pt.add[off:end:end]
+}
+
+func (pt *pieceTable) setData(n int, data []byte) {
+	pt.lock.Lock()
+	defer pt.lock.Unlock()
+
+	// the old text is not overwritten since the slices returned by data
+	// may still be in use, it is only dropped when compacting
+	p := pt.piece(n)
+	if p.off < 0 {
+		pt.garbage += p.len
+	}
+	p.off = -len(pt.add) - 1
+	p.len = len(data)
+	pt.add = append(pt.add, data...)
+	pt.compact()
+}
+
+// compact copies the lines of the append buffer to a new one without the
+// text no longer used, once it makes up most of the buffer
+func (pt *pieceTable) compact() {
+	if pt.garbage < pieceCompactSize || 2*pt.garbage < len(pt.add) {
+		return
+	}
+	add := make([]byte, 0, len(pt.add)-pt.garbage)
+	for _, block := range pt.blocks {
+		for i := range block {
+			if p := &block[i]; p.off < 0 {
+				data := pt.pieceData(p)
+				p.off = -len(add) - 1
+				add = append(add, data...)
+			}
+		}
+	}
+	pt.add = add
+	pt.garbage = 0
+}
+
+// detach copies the original to memory if it is the mapping of the given
+// file, which is about to be truncated. The mapping itself is kept since
+// the slices returned by data may still point into it.
+func (pt *pieceTable) detach(file os.FileInfo) {
+	pt.lock.Lock()
+	defer pt.lock.Unlock()
+
+	if pt.file == nil || !os.SameFile(pt.file, file) {
+		return
+	}
+	pt.orig = append([]byte(nil), pt.orig...)
+	pt.file = nil
+}
+
+// detachFile stops the line array from reading the given file if its
+// original is mapped from it
+func (la *LineArray) detachFile(file os.FileInfo) {
+	if pt, ok := la.store.(*pieceTable); ok {
+		pt.detach(file)
+	}
+}
+
+func (pt *pieceTable) insertLines(n int, lines [][]byte) {
+	pt.lock.Lock()
+	defer pt.lock.Unlock()
+
+	var bi, i int
+	if n == pt.nlines {
+		bi = len(pt.blocks) - 1
+		i = len(pt.blocks[bi])
+	} else {
+		bi, i = pt.find(n)
+	}
+
+	pieces := make([]piece, len(lines))
+	for j, data := range lines {
+		pieces[j] = piece{off: -len(pt.add) - 1, len: len(data)}
+		pt.add = append(pt.add, data...)
+	}
+
+	block := pt.blocks[bi]
+	newBlock := make([]piece, 0, len(block)+len(pieces))
+	newBlock = append(newBlock, block[:i]...)
+	newBlock = append(newBlock, pieces...)
+	newBlock = append(newBlock, block[i:]...)
+
+	// split the block if it has grown too large
+	var split [][]piece
+	for len(newBlock) > 2*pieceBlockSize {
+		split = append(split, newBlock[:pieceBlockSize:pieceBlockSize])
+		newBlock = newBlock[pieceBlockSize:]
+	}
+	split = append(split, newBlock)
+
+	blocks := make([][]piece, 0, len(pt.blocks)+len(split)-1)
+	blocks = append(blocks, pt.blocks[:bi]...)
+	blocks = append(blocks, split...)
+	blocks = append(blocks, pt.blocks[bi+1:]...)
+	pt.blocks = blocks
+
+	pt.updateStarts(bi)
+}
+
+func (pt *pieceTable) deleteLines(y1, y2 int) {
+	pt.lock.Lock()
+	defer pt.lock.Unlock()
+
+	b1, i1 := pt.find(y1)
+	b2, i2 := pt.find(y2)
+	for y := y1; y <= y2; y++ {
+		if p := pt.piece(y); p.off < 0 {
+			pt.garbage += p.len
+		}
+	}
+
+	if b1 == b2 {
+		block := pt.blocks[b1]
+		pt.blocks[b1] = append(block[:i1:i1], block[i2+1:]...)
+	} else {
+		pt.blocks[b1] = pt.blocks[b1][:i1:i1]
+		pt.blocks[b2] = pt.blocks[b2][i2+1:]
+		pt.blocks = append(pt.blocks[:b1+1], pt.blocks[b2:]...)
+		b2 = b1 + 1
+	}
+
+	// remove the blocks that became empty, but always keep one block
+	for bi := util.Min(b2, len(pt.blocks)-1); bi >= b1; bi-- {
+		if len(pt.blocks[bi]) == 0 && len(pt.blocks) > 1 {
+			pt.blocks = append(pt.blocks[:bi], pt.blocks[bi+1:]...)
+		}
+	}
+
+	pt.updateStarts(util.Min(b1, len(pt.blocks)))
+}
+
+func (pt *pieceTable) ending(n int) FileFormat {
+	pt.lock.RLock()
+	defer pt.lock.RUnlock()
+	return pt.piece(n).eol
+}
+
+func (pt *pieceTable) setEnding(n int, eol FileFormat) {
+	pt.lock.Lock()
+	defer pt.lock.Unlock()
+	pt.piece(n).eol = eol
+}
+
+func (pt *pieceTable) meta(n int, create bool) *lineMeta {
+	pt.lock.Lock()
+	defer pt.lock.Unlock()
+
+	p := pt.piece(n)
+	if p.meta == nil && create {
+		p.meta = new(lineMeta)
+	}
+	return p.meta
+}
+
+func (pt *pieceTable) info() string {
+	pt.lock.RLock()
+	defer pt.lock.RUnlock()
+
+	orig := "original"
+	if pt.file != nil {
+		orig = "mapped original"
+	}
+	return fmt.Sprintf("piece table, %d lines, %s %s, %s appended", pt.nlines,
+		humanize.Bytes(uint64(len(pt.orig))), orig, humanize.Bytes(uint64(len(pt.add))))
+}
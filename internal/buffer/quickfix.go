@@ -0,0 +1,172 @@
+package buffer
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A QuickfixEntry is a location in a file with a message, like an error
+// reported by a compiler or a linter
+type QuickfixEntry struct {
+	Path string
+	// Loc is the location in the file, starting from 0
+	Loc  Loc
+	Msg  string
+	Kind MsgType
+}
+
+// String returns the entry as "path:line:col: kind: message"
+func (e QuickfixEntry) String() string {
+	kind := "error"
+	switch e.Kind {
+	case MTWarning:
+		kind = "warning"
+	case MTInfo:
+		kind = "info"
+	}
+	return fmt.Sprintf("%s:%d:%d: %s: %s", e.Path, e.Loc.Y+1, e.Loc.X+1, kind, e.Msg)
+}
+
+// MessagesQuickfix returns the entries of the messages of the open buffers
+// of files, e.g. the ones added by the linter plugin, sorted by file and
+// location
+func MessagesQuickfix() []QuickfixEntry {
+	var entries []QuickfixEntry
+	seen := make(map[string]bool)
+	for _, b := range OpenBuffers {
+		// buffers of the same file share their messages
+		if b.Path == "" || seen[b.AbsPath] {
+			continue
+		}
+		seen[b.AbsPath] = true
+		for _, m := range b.Messages {
+			loc := m.Start
+			if loc.X < 0 {
+				loc.X = 0
+			}
+			entries = append(entries, QuickfixEntry{b.Path, loc, m.Msg, m.Kind})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Path != entries[j].Path {
+			return entries[i].Path < entries[j].Path
+		}
+		return entries[i].Loc.LessThan(entries[j].Loc)
+	})
+	return entries
+}
+
+// ParseErrorformat returns the entries described by the lines of the output
+// of a command which match the given errorformat. An errorformat is a comma
+// separated list of formats tried in order on every line, where %f is the
+// file, %l the line, %c the column, %m the message, %t the type of the
+// message (e for error, w for warning, i for info) and %% a percent sign.
+// A comma in a format is escaped as \,.
+func ParseErrorformat(errorformat, output string) ([]QuickfixEntry, error) {
+	var formats []*regexp.Regexp
+	for _, f := range splitErrorformat(errorformat) {
+		r, err := compileErrorformat(f)
+		if err != nil {
+			return nil, err
+		}
+		formats = append(formats, r)
+	}
+
+	var entries []QuickfixEntry
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		for _, r := range formats {
+			m := r.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			e := QuickfixEntry{Kind: MTError}
+			for i, name := range r.SubexpNames() {
+				switch name {
+				case "f":
+					e.Path = m[i]
+				case "l":
+					n, _ := strconv.Atoi(m[i])
+					e.Loc.Y = n - 1
+				case "c":
+					n, _ := strconv.Atoi(m[i])
+					e.Loc.X = n - 1
+				case "m":
+					e.Msg = m[i]
+				case "t":
+					switch strings.ToLower(m[i]) {
+					case "w":
+						e.Kind = MTWarning
+					case "i":
+						e.Kind = MTInfo
+					}
+				}
+			}
+			if e.Loc.Y < 0 {
+				e.Loc.Y = 0
+			}
+			if e.Loc.X < 0 {
+				e.Loc.X = 0
+			}
+			entries = append(entries, e)
+			break
+		}
+	}
+	return entries, nil
+}
+
+// splitErrorformat splits an errorformat at the commas which are not
+// escaped
+func splitErrorformat(errorformat string) []string {
+	var formats []string
+	var cur strings.Builder
+	for i := 0; i < len(errorformat); i++ {
+		switch {
+		case strings.HasPrefix(errorformat[i:], `\,`):
+			cur.WriteByte(',')
+			i++
+		case errorformat[i] == ',':
+			formats = append(formats, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(errorformat[i])
+		}
+	}
+	return append(formats, cur.String())
+}
+
+// compileErrorformat converts a single format of an errorformat to a
+// regular expression matching a whole line, with a named group for every
+// item of the format
+func compileErrorformat(format string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			b.WriteString(regexp.QuoteMeta(format[i : i+1]))
+			continue
+		}
+		i++
+		switch format[i] {
+		case 'f':
+			b.WriteString(`(?P<f>.+?)`)
+		case 'l':
+			b.WriteString(`(?P<l>\d+)`)
+		case 'c':
+			b.WriteString(`(?P<c>\d+)`)
+		case 'm':
+			b.WriteString(`(?P<m>.*)`)
+		case 't':
+			b.WriteString(`(?P<t>[a-zA-Z])`)
+		case '%':
+			b.WriteString("%")
+		default:
+			return nil, fmt.Errorf("Invalid errorformat item %%%c in %s", format[i], format)
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
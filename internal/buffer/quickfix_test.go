@@ -0,0 +1,31 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseErrorformat(t *testing.T) {
+	output := "# example\r\n" +
+		"main.go:12:5: undefined: foo\r\n" +
+		"util/a b.go:3: missing return\n" +
+		"w:lib.c:7:1: unused variable\n" +
+		"100% done\n"
+
+	entries, err := ParseErrorformat(`%t:%f:%l:%c: %m,%f:%l:%c: %m,%f:%l: %m`, output)
+	assert.NoError(t, err)
+	assert.Equal(t, []QuickfixEntry{
+		{"main.go", Loc{4, 11}, "undefined: foo", MTError},
+		{"util/a b.go", Loc{0, 2}, "missing return", MTError},
+		{"lib.c", Loc{0, 6}, "unused variable", MTWarning},
+	}, entries)
+	assert.Equal(t, "lib.c:7:1: warning: unused variable", entries[2].String())
+
+	entries, err = ParseErrorformat(`%m\, 100%% %f`, "almost, 100% done")
+	assert.NoError(t, err)
+	assert.Equal(t, []QuickfixEntry{{"done", Loc{0, 0}, "almost", MTError}}, entries)
+
+	_, err = ParseErrorformat(`%f:%x`, output)
+	assert.Error(t, err)
+}
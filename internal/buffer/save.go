@@ -133,42 +133,54 @@ func (wf wrappedFile) Truncate() error {
 }
 
 func (wf wrappedFile) Write(b *SharedBuffer) (int, error) {
+	if b.Type.Kind == BTHex.Kind {
+		return wf.writeHex(b)
+	}
+
 	file := bufio.NewWriter(transform.NewWriter(wf.writeCloser, b.encoding.NewEncoder()))
 
 	b.Lock()
 	defer b.Unlock()
 
-	if len(b.lines) == 0 {
+	if b.LinesNum() == 0 {
 		return 0, nil
 	}
 
-	// end of line
-	var eol []byte
-	if b.Endings == FFDos {
-		eol = []byte{'\r', '\n'}
-	} else {
-		eol = []byte{'\n'}
+	// the original text of the buffer may be mapped from the file
+	if info, err := os.Stat(wf.name); err == nil {
+		b.detachFile(info)
 	}
-
 	err := wf.Truncate()
 	if err != nil {
 		return 0, err
 	}
 
+	// the byte order mark is not encoded
+	var bom []byte
+	if b.bom {
+		bom = bomOf(b.encoding)
+	}
+	if _, err = wf.writeCloser.Write(bom); err != nil {
+		return 0, err
+	}
+
 	// write lines
-	size, err := file.Write(b.lines[0].data)
+	size, err := file.Write(b.LineBytes(0))
 	if err != nil {
 		return 0, err
 	}
+	size += len(bom)
 
-	for _, l := range b.lines[1:] {
+	for i := 1; i < b.LinesNum(); i++ {
+		l := b.LineBytes(i)
+		eol := eolBytes(b.LineEnding(i - 1))
 		if _, err = file.Write(eol); err != nil {
 			return 0, err
 		}
-		if _, err = file.Write(l.data); err != nil {
+		if _, err = file.Write(l); err != nil {
 			return 0, err
 		}
-		size += len(eol) + len(l.data)
+		size += len(eol) + len(l)
 	}
 
 	err = file.Flush()
@@ -180,6 +192,25 @@ func (wf wrappedFile) Write(b *SharedBuffer) (int, error) {
 	return size, err
 }
 
+// writeHex writes the bytes shown by a hex view, unchanged
+func (wf wrappedFile) writeHex(b *SharedBuffer) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+
+	data, err := b.hexBytes()
+	if err != nil {
+		return 0, err
+	}
+	if err = wf.Truncate(); err != nil {
+		return 0, err
+	}
+	size, err := wf.writeCloser.Write(data)
+	if err == nil && !wf.withSudo {
+		err = wf.writeCloser.(*os.File).Sync()
+	}
+	return size, err
+}
+
 func (wf wrappedFile) Close() error {
 	err := wf.writeCloser.Close()
 	if wf.withSudo {
@@ -247,21 +278,25 @@ func (b *Buffer) saveToFile(filename string, withSudo bool, autoSave bool) error
 		return errors.New("Cannot save scratch buffer")
 	}
 
-	if !autoSave && b.Settings["rmtrailingws"].(bool) {
-		for i, l := range b.lines {
-			leftover := util.CharacterCount(bytes.TrimRightFunc(l.data, unicode.IsSpace))
+	// the text of a hex view is not the text of the file
+	hex := b.Type.Kind == BTHex.Kind
+
+	if !autoSave && !hex && b.Settings["rmtrailingws"].(bool) {
+		for i := 0; i < b.LinesNum(); i++ {
+			l := b.LineBytes(i)
+			leftover := util.CharacterCount(bytes.TrimRightFunc(l, unicode.IsSpace))
 
-			linelen := util.CharacterCount(l.data)
+			linelen := util.CharacterCount(l)
 			b.Remove(Loc{leftover, i}, Loc{linelen, i})
 		}
 
 		b.RelocateCursors()
 	}
 
-	if b.Settings["eofnewline"].(bool) {
+	if !hex && b.Settings["eofnewline"].(bool) {
 		end := b.End()
 		if b.RuneAt(Loc{end.X - 1, end.Y}) != '\n' {
-			b.insert(end, []byte{'\n'})
+			b.insert(end, []byte{'\n'}, nil)
 		}
 	}
 
@@ -333,17 +368,24 @@ func (b *Buffer) saveToFile(filename string, withSudo bool, autoSave bool) error
 	newPath := b.Path != filename
 	if newPath {
 		b.RemoveBackup()
+		b.UnlockFile()
+		b.unwatch()
 	}
 
 	b.Path = filename
 	b.AbsPath = absFilename
 	b.isModified = false
+	b.DeletedOnDisk = false
 	b.UpdateModTime()
+	b.setMergeBase(nil)
 
 	if newPath {
 		// need to update glob-based and filetype-based settings
 		b.ReloadSettings(true)
+		b.LockFile()
+		b.watch()
 	}
+	b.gitSaved()
 
 	err = b.Serialize()
 	return err
@@ -1,7 +1,10 @@
 package buffer
 
 import (
+	"bytes"
 	"regexp"
+	"sort"
+	"strings"
 	"unicode/utf8"
 
 	"github.com/micro-editor/micro/v2/internal/util"
@@ -41,24 +44,28 @@ func findLineParams(b *Buffer, start, end Loc, i int, r *regexp.Regexp) ([]byte,
 		}
 	}
 
-	if padMode != 0 {
-		re, err := regexp.Compile(r.String() + `\E`)
-		if err == nil {
-			// r contains \Q without closing \E
-			r = re
-		}
+	return l, charpos, padMode, padRegexp(r, padMode)
+}
 
-		if padMode == padStart {
-			r = regexp.MustCompile(".(?:" + r.String() + ")")
-		} else if padMode == padEnd {
-			r = regexp.MustCompile("(?:" + r.String() + ").")
-		} else {
-			// padMode == padStart|padEnd
-			r = regexp.MustCompile(".(?:" + r.String() + ").")
-		}
+// padRegexp returns the regexp r padded according to padMode
+func padRegexp(r *regexp.Regexp, padMode int) *regexp.Regexp {
+	if padMode == 0 {
+		return r
+	}
+
+	re, err := regexp.Compile(r.String() + `\E`)
+	if err == nil {
+		// r contains \Q without closing \E
+		r = re
 	}
 
-	return l, charpos, padMode, r
+	if padMode == padStart {
+		return regexp.MustCompile(".(?:" + r.String() + ")")
+	} else if padMode == padEnd {
+		return regexp.MustCompile("(?:" + r.String() + ").")
+	}
+	// padMode == padStart|padEnd
+	return regexp.MustCompile(".(?:" + r.String() + ").")
 }
 
 func (b *Buffer) findDown(r *regexp.Regexp, start, end Loc) ([2]Loc, bool) {
@@ -76,6 +83,15 @@ func (b *Buffer) findDown(r *regexp.Regexp, start, end Loc) ([2]Loc, bool) {
 		start, end = end, start
 	}
 
+	if isMultiLine(r.String()) {
+		lr := b.newLineRegion(start.Y, end.Y)
+		m, found := lr.find(r, padRegexp(r, padStart), lr.offset(start), lr.offset(end))
+		if !found {
+			return [2]Loc{}, false
+		}
+		return [2]Loc{lr.loc(m[0]), lr.loc(m[1])}, true
+	}
+
 	for i := start.Y; i <= end.Y; i++ {
 		l, charpos, padMode, rPadded := findLineParams(b, start, end, i, r)
 
@@ -113,6 +129,14 @@ func (b *Buffer) findUp(r *regexp.Regexp, start, end Loc) ([2]Loc, bool) {
 		start, end = end, start
 	}
 
+	if isMultiLine(r.String()) {
+		matches := b.findAll(r, start, end)
+		if len(matches) == 0 {
+			return [2]Loc{}, false
+		}
+		return matches[len(matches)-1], true
+	}
+
 	for i := end.Y; i >= start.Y; i-- {
 		charCount := util.CharacterCount(b.LineBytes(i))
 		from := Loc{0, i}.Clamp(start, end)
@@ -128,6 +152,15 @@ func (b *Buffer) findUp(r *regexp.Regexp, start, end Loc) ([2]Loc, bool) {
 }
 
 func (b *Buffer) findAll(r *regexp.Regexp, start, end Loc) [][2]Loc {
+	if isMultiLine(r.String()) {
+		var matches [][2]Loc
+		lr := b.newLineRegion(start.Y, end.Y)
+		for _, m := range lr.findAll(r, lr.offset(start), lr.offset(end)) {
+			matches = append(matches, [2]Loc{lr.loc(m[0]), lr.loc(m[1])})
+		}
+		return matches
+	}
+
 	var matches [][2]Loc
 	loc := start
 	for {
@@ -147,6 +180,33 @@ func (b *Buffer) findAll(r *regexp.Regexp, start, end Loc) [][2]Loc {
 	return matches
 }
 
+// CompileSearch compiles the regexp searching for the given string, with
+// the same conventions as FindNext: s is quoted unless useRegex is true,
+// and the search ignores the case if ignorecase is true
+func CompileSearch(s string, useRegex, ignorecase bool) (*regexp.Regexp, error) {
+	if !useRegex {
+		s = regexp.QuoteMeta(s)
+	}
+
+	flags := ""
+	if ignorecase {
+		flags = "i"
+	}
+	if isMultiLine(s) {
+		// "^" and "$" still match at the beginning and end of every line
+		flags += "m"
+	}
+	if flags != "" {
+		s = "(?" + flags + ")" + s
+	}
+	return regexp.Compile(s)
+}
+
+// searchRegexp compiles the regexp searching for the given string
+func (b *Buffer) searchRegexp(s string, useRegex bool) (*regexp.Regexp, error) {
+	return CompileSearch(s, useRegex, b.Settings["ignorecase"].(bool))
+}
+
 // FindNext finds the next occurrence of a given string in the buffer
 // It returns the start and end location of the match (if found) and
 // a boolean indicating if it was found
@@ -156,19 +216,7 @@ func (b *Buffer) FindNext(s string, start, end, from Loc, down bool, useRegex bo
 		return [2]Loc{}, false, nil
 	}
 
-	var r *regexp.Regexp
-	var err error
-
-	if !useRegex {
-		s = regexp.QuoteMeta(s)
-	}
-
-	if b.Settings["ignorecase"].(bool) {
-		r, err = regexp.Compile("(?i)" + s)
-	} else {
-		r, err = regexp.Compile(s)
-	}
-
+	r, err := b.searchRegexp(s, useRegex)
 	if err != nil {
 		return [2]Loc{}, false, err
 	}
@@ -198,9 +246,31 @@ func (b *Buffer) ReplaceRegex(start, end Loc, search *regexp.Regexp, replace []b
 	}
 
 	charsEnd := util.CharacterCount(b.LineBytes(end.Y))
+	linesAfter := b.LinesNum() - 1 - end.Y
 	found := 0
 	var deltas []Delta
 
+	if isMultiLine(search.String()) {
+		lr := b.newLineRegion(start.Y, end.Y)
+		matches := lr.findAll(search, lr.offset(start), lr.offset(end))
+		found = len(matches)
+
+		for j := len(matches) - 1; j >= 0; j-- {
+			match := matches[j]
+			newText := replace
+			if captureGroups {
+				newText = search.Expand(nil, replace, lr.text, match)
+			}
+			deltas = append(deltas, Delta{newText, lr.loc(match[0]), lr.loc(match[1]), nil})
+		}
+		if len(deltas) > 0 {
+			b.MultipleReplace(deltas)
+		}
+
+		endY := b.LinesNum() - 1 - linesAfter
+		return found, util.CharacterCount(b.LineBytes(endY)) - charsEnd
+	}
+
 	for i := start.Y; i <= end.Y; i++ {
 		l := b.LineBytes(i)
 		charCount := util.CharacterCount(l)
@@ -223,7 +293,7 @@ func (b *Buffer) ReplaceRegex(start, end Loc, search *regexp.Regexp, replace []b
 				} else {
 					newText = replace
 				}
-				deltas = append(deltas, Delta{newText, match[0], match[1]})
+				deltas = append(deltas, Delta{newText, match[0], match[1], nil})
 			}
 		} else {
 			newLine := search.ReplaceAllFunc(l, func(in []byte) []byte {
@@ -237,7 +307,7 @@ func (b *Buffer) ReplaceRegex(start, end Loc, search *regexp.Regexp, replace []b
 				}
 				return result
 			})
-			deltas = append(deltas, Delta{newLine, Loc{0, i}, Loc{charCount, i}})
+			deltas = append(deltas, Delta{newLine, Loc{0, i}, Loc{charCount, i}, nil})
 		}
 	}
 
@@ -245,3 +315,165 @@ func (b *Buffer) ReplaceRegex(start, end Loc, search *regexp.Regexp, replace []b
 
 	return found, util.CharacterCount(b.LineBytes(end.Y)) - charsEnd
 }
+
+// isMultiLine returns true if the search pattern contains a line break, so
+// that it must be matched against the text of several lines at once
+// instead of line by line
+func isMultiLine(pattern string) bool {
+	return strings.Contains(pattern, `\n`) || strings.Contains(pattern, "\n")
+}
+
+// A lineRegion holds the text of a range of lines joined with line breaks,
+// to match patterns spanning several lines
+type lineRegion struct {
+	text []byte
+	// y is the first line of the region and starts the offsets of the
+	// lines in text
+	y      int
+	starts []int
+}
+
+func (b *Buffer) newLineRegion(y1, y2 int) *lineRegion {
+	lr := &lineRegion{y: y1}
+	for i := y1; i <= y2; i++ {
+		if i > y1 {
+			lr.text = append(lr.text, '\n')
+		}
+		lr.starts = append(lr.starts, len(lr.text))
+		lr.text = append(lr.text, b.LineBytes(i)...)
+	}
+	return lr
+}
+
+// line returns the text of the line at the given index of the region
+func (lr *lineRegion) line(i int) []byte {
+	l := lr.text[lr.starts[i]:]
+	if nl := bytes.IndexByte(l, '\n'); nl >= 0 {
+		l = l[:nl]
+	}
+	return l
+}
+
+// offset returns the offset in the text of the given location
+func (lr *lineRegion) offset(l Loc) int {
+	i := util.Clamp(l.Y-lr.y, 0, len(lr.starts)-1)
+	return lr.starts[i] + runeToByteIndex(util.Max(l.X, 0), lr.line(i))
+}
+
+// loc returns the location of the given offset in the text
+func (lr *lineRegion) loc(offset int) Loc {
+	i := sort.SearchInts(lr.starts, offset+1) - 1
+	return Loc{util.CharacterCount(lr.text[lr.starts[i]:offset]), lr.y + i}
+}
+
+// find returns the submatch offsets of the first match of r starting at or
+// after the offset from and ending at or before the offset to. padded is r
+// padded with padStart, used when from is in the middle of a line so that
+// "^" and "\b" do not match there.
+func (lr *lineRegion) find(r, padded *regexp.Regexp, from, to int) ([]int, bool) {
+	for from <= to {
+		m := lr.findFrom(r, padded, from)
+		if m == nil || m[0] > to {
+			return nil, false
+		}
+		if m[1] <= to {
+			return m, true
+		}
+
+		// the match ends too far, look for one starting further
+		_, size := utf8.DecodeRune(lr.text[m[0]:])
+		from = m[0] + util.Max(size, 1)
+	}
+	return nil, false
+}
+
+func (lr *lineRegion) findFrom(r, padded *regexp.Regexp, from int) []int {
+	shift := func(m []int, n int) []int {
+		for i := range m {
+			if m[i] >= 0 {
+				m[i] += n
+			}
+		}
+		return m
+	}
+
+	if from == 0 || lr.text[from-1] == '\n' {
+		return shift(r.FindSubmatchIndex(lr.text[from:]), from)
+	}
+
+	// the padding rune can't be a line break, so the matches at the
+	// beginning of the next lines are searched without it
+	nl := bytes.IndexByte(lr.text[from:], '\n')
+	_, size := utf8.DecodeLastRune(lr.text[:from])
+	if m := padded.FindSubmatchIndex(lr.text[from-size:]); m != nil {
+		m = shift(m, from-size)
+		_, padSize := utf8.DecodeRune(lr.text[m[0]:])
+		m[0] += padSize
+		if nl < 0 || m[0] <= from+nl {
+			return m
+		}
+	}
+	if nl < 0 {
+		return nil
+	}
+	return shift(r.FindSubmatchIndex(lr.text[from+nl+1:]), from+nl+1)
+}
+
+// findAll returns the submatch offsets of all the matches of r between the
+// offsets from and to
+func (lr *lineRegion) findAll(r *regexp.Regexp, from, to int) [][]int {
+	var matches [][]int
+	padded := padRegexp(r, padStart)
+	for from <= to {
+		m, found := lr.find(r, padded, from, to)
+		if !found {
+			break
+		}
+		matches = append(matches, m)
+		if m[1] > m[0] {
+			from = m[1]
+		} else if m[1] < to {
+			_, size := utf8.DecodeRune(lr.text[m[1]:])
+			from = m[1] + size
+		} else {
+			break
+		}
+	}
+	return matches
+}
+
+// multiLineMatches holds all the matches of a search spanning several lines
+// in a version of the buffer
+type multiLineMatches struct {
+	search     string
+	useRegex   bool
+	ignorecase bool
+	version    uint64
+	matches    [][2]Loc
+}
+
+// multiLineSearchMatch is SearchMatch for the searches that can span
+// several lines. Since a match may start many lines above the given
+// location, all the matches of the buffer are searched at once and kept
+// until the buffer or the search changes.
+func (b *Buffer) multiLineSearchMatch(pos Loc) bool {
+	m := b.multiLineMatches
+	if m == nil || m.search != b.LastSearch || m.useRegex != b.LastSearchRegex ||
+		m.ignorecase != b.Settings["ignorecase"].(bool) || m.version != b.version {
+		m = &multiLineMatches{
+			search:     b.LastSearch,
+			useRegex:   b.LastSearchRegex,
+			ignorecase: b.Settings["ignorecase"].(bool),
+			version:    b.version,
+		}
+		if r, err := b.searchRegexp(b.LastSearch, b.LastSearchRegex); err == nil {
+			m.matches = b.findAll(r, b.Start(), b.End())
+		}
+		b.multiLineMatches = m
+	}
+
+	i := sort.Search(len(m.matches), func(i int) bool {
+		return pos.LessThan(m.matches[i][1])
+	})
+	return i < len(m.matches) && m.matches[i][0].LessEqual(pos)
+}
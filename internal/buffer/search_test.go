@@ -0,0 +1,73 @@
+package buffer
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const searchText = `foo bar
+baz foo
+bar
+foo`
+
+func TestFindMultiLine(t *testing.T) {
+	b := NewBufferFromString(searchText, "", BTDefault)
+	defer b.Close()
+
+	m, found, err := b.FindNext(`bar\nba`, b.Start(), b.End(), b.Start(), true, true)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, [2]Loc{{4, 0}, {2, 1}}, m)
+
+	// "^" only matches at the beginning of lines, not at the search start
+	m, found, _ = b.FindNext(`^\w+\n`, b.Start(), b.End(), Loc{1, 0}, true, true)
+	assert.True(t, found)
+	assert.Equal(t, [2]Loc{{0, 2}, {0, 3}}, m)
+
+	m, found, _ = b.FindNext(`foo\n`, b.Start(), b.End(), Loc{0, 3}, false, true)
+	assert.True(t, found)
+	assert.Equal(t, [2]Loc{{4, 1}, {0, 2}}, m)
+
+	// matches must end before the end of the range
+	_, found, _ = b.FindNext(`foo\nbar`, Loc{0, 1}, Loc{1, 2}, Loc{0, 1}, true, true)
+	assert.False(t, found)
+
+	b.LastSearch = `r\nb`
+	b.LastSearchRegex = true
+	assert.True(t, b.SearchMatch(Loc{6, 0}))
+	assert.True(t, b.SearchMatch(Loc{0, 1}))
+	assert.False(t, b.SearchMatch(Loc{1, 1}))
+	assert.False(t, b.SearchMatch(Loc{5, 0}))
+}
+
+func TestReplaceMultiLine(t *testing.T) {
+	b := NewBufferFromString(searchText, "", BTDefault)
+	defer b.Close()
+
+	n, _ := b.ReplaceRegex(b.Start(), b.End(), regexp.MustCompile(`(?m)(\w+)\n(\w+)$`), []byte("$2-$1"), true)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, "foo bar\nbaz bar-foo\nfoo", string(b.Bytes()))
+
+	b.Undo()
+	assert.Equal(t, searchText, string(b.Bytes()))
+
+	n, _ = b.ReplaceRegex(b.Start(), b.End(), regexp.MustCompile(`o\n`), []byte("o "), false)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, "foo bar\nbaz foo bar\nfoo", string(b.Bytes()))
+}
+
+func TestIsMultiLine(t *testing.T) {
+	assert.True(t, isMultiLine(`a\nb`))
+	assert.True(t, isMultiLine("a\nb"))
+	// classes which contain a line break still match line by line
+	assert.False(t, isMultiLine(`a\s+b`))
+	assert.False(t, isMultiLine(`"[^"]*"`))
+	assert.False(t, isMultiLine(`\D+`))
+
+	b := NewBufferFromString("a  b\nc   d\n", "", BTDefault)
+	defer b.Close()
+	b.ReplaceRegex(b.Start(), b.End(), regexp.MustCompile(`\s+`), []byte(" "), false)
+	assert.Equal(t, "a b\nc d\n", string(b.Bytes()))
+}
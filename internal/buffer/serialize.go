@@ -18,6 +18,7 @@ type SerializedBuffer struct {
 	EventHandler *EventHandler
 	Cursor       Loc
 	ModTime      time.Time
+	Folds        []Fold
 }
 
 // Serialize serializes the buffer to config.ConfigDir/buffers
@@ -25,7 +26,8 @@ func (b *Buffer) Serialize() error {
 	if !b.Settings["savecursor"].(bool) && !b.Settings["saveundo"].(bool) {
 		return nil
 	}
-	if b.Path == "" {
+	// the positions in a hex view are not the ones in the text of the file
+	if b.Path == "" || b.Type.Kind == BTHex.Kind {
 		return nil
 	}
 
@@ -34,6 +36,7 @@ func (b *Buffer) Serialize() error {
 		b.EventHandler,
 		b.GetActiveCursor().Loc,
 		b.ModTime,
+		b.Folds(),
 	})
 	if err != nil {
 		return err
@@ -59,7 +62,7 @@ func (b *Buffer) Serialize() error {
 func (b *Buffer) Unserialize() error {
 	// If either savecursor or saveundo is turned on, we need to load the serialized information
 	// from ~/.config/micro/buffers
-	if b.Path == "" {
+	if b.Path == "" || b.Type.Kind == BTHex.Kind {
 		return nil
 	}
 	name, _ := util.DetermineEscapePath(filepath.Join(config.ConfigDir, "buffers"), b.AbsPath)
@@ -74,11 +77,16 @@ func (b *Buffer) Unserialize() error {
 		}
 		if b.Settings["savecursor"].(bool) {
 			b.StartCursor = buffer.Cursor
+			// The folds are only valid if the lines have not changed
+			if b.ModTime == buffer.ModTime {
+				b.SetFolds(buffer.Folds)
+			}
 		}
 
 		if b.Settings["saveundo"].(bool) {
 			// We should only use last time's eventhandler if the file wasn't modified by someone else in the meantime
-			if b.ModTime == buffer.ModTime {
+			// The undo tree is missing if the history was saved by an older version
+			if b.ModTime == buffer.ModTime && buffer.EventHandler != nil && buffer.EventHandler.UndoTree != nil {
 				b.EventHandler = buffer.EventHandler
 				b.EventHandler.cursors = b.cursors
 				b.EventHandler.buf = b.SharedBuffer
@@ -7,7 +7,6 @@ import (
 	"github.com/micro-editor/micro/v2/internal/config"
 	ulua "github.com/micro-editor/micro/v2/internal/lua"
 	"github.com/micro-editor/micro/v2/internal/screen"
-	"golang.org/x/text/encoding/htmlindex"
 	"golang.org/x/text/encoding/unicode"
 	luar "layeh.com/gopher-luar"
 )
@@ -37,6 +36,10 @@ func (b *Buffer) ReloadSettings(reloadFiletype bool) {
 	}
 
 	config.UpdateFileTypeLocals(settings, curFiletype)
+	b.editorConfig = nil
+	if b.Path != "" && b.Settings["editorconfig"].(bool) {
+		b.editorConfig = config.UpdateEditorConfigLocals(settings, b.AbsPath)
+	}
 
 	for k, v := range config.DefaultCommonSettings() {
 		if k == "filetype" {
@@ -57,6 +60,37 @@ func (b *Buffer) ReloadSettings(reloadFiletype bool) {
 			b.DoSetOptionNative(k, v)
 		}
 	}
+	b.applyEditorConfigBOM()
+}
+
+// applyEditorConfig sets the options given by the .editorconfig files again,
+// since they take precedence over the options of the filetype. The options
+// detected from the file are kept.
+func (b *SharedBuffer) applyEditorConfig() {
+	for k, o := range b.editorConfig {
+		if !b.LocalSettings[k] {
+			b.Settings[k] = o.Value
+		}
+	}
+	b.applyEditorConfigBOM()
+}
+
+// applyEditorConfigBOM makes the file be saved with a byte order mark if its
+// charset is utf-8-bom and it is saved in this encoding
+func (b *SharedBuffer) applyEditorConfigBOM() {
+	if o, ok := b.editorConfig["encoding"]; ok && o.BOM && b.Settings["encoding"] == o.Value {
+		b.bom = true
+	}
+}
+
+// SettingOrigin returns the path of the .editorconfig file giving its
+// current value to the given option of the buffer, or an empty string if the
+// value does not come from an .editorconfig file
+func (b *SharedBuffer) SettingOrigin(option string) string {
+	if o, ok := b.editorConfig[option]; ok && reflect.DeepEqual(b.Settings[option], o.Value) {
+		return o.File
+	}
+	return ""
 }
 
 func (b *Buffer) DoSetOptionNative(option string, nativeValue any) {
@@ -85,11 +119,16 @@ func (b *Buffer) DoSetOptionNative(option string, nativeValue any) {
 	} else if option == "filetype" {
 		b.ReloadSettings(false)
 	} else if option == "fileformat" {
-		switch b.Settings["fileformat"].(string) {
+		switch nativeValue.(string) {
 		case "unix":
-			b.Endings = FFUnix
+			b.SetEndings(FFUnix)
 		case "dos":
-			b.Endings = FFDos
+			b.SetEndings(FFDos)
+		case "mac":
+			b.SetEndings(FFMac)
+		case "mixed":
+			// the line endings of the lines are only mixed by editing them
+			b.Settings["fileformat"] = oldValue
 		}
 		b.setModified()
 	} else if option == "syntax" {
@@ -99,13 +138,23 @@ func (b *Buffer) DoSetOptionNative(option string, nativeValue any) {
 			b.UpdateRules()
 		}
 	} else if option == "encoding" {
-		enc, err := htmlindex.Get(b.Settings["encoding"].(string))
-		if err != nil {
-			enc = unicode.UTF8
-			b.Settings["encoding"] = "utf-8"
+		if nativeValue == "auto" && b.Type.Kind == BTHex.Kind {
+			// the bytes of a hex view are not decoded
+			b.Settings["encoding"] = oldValue
+		} else if nativeValue == "auto" {
+			b.redetectEncoding()
+			b.setModified()
+		} else if b.Type.Kind != BTHex.Kind {
+			enc, err := config.GetEncoding(nativeValue.(string))
+			if err != nil {
+				enc = unicode.UTF8
+				b.Settings["encoding"] = "utf-8"
+			}
+			b.encoding = enc
+			b.setModified()
 		}
-		b.encoding = enc
-		b.setModified()
+	} else if option == "diffgutter" || option == "gitdiffbase" {
+		b.UpdateGitDiffBase()
 	} else if option == "readonly" && b.Type.Kind == BTDefault.Kind {
 		b.Type.Readonly = nativeValue.(bool)
 	} else if option == "hlsearch" {
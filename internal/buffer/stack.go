@@ -1,43 +1,33 @@
 package buffer
 
-// TEStack is a simple implementation of a LIFO stack for text events
+// TEStack is a stack of text events. The undo and redo stacks of a buffer
+// are kept as views of its undo tree: the undo stack holds the events
+// leading from the original text to the current state, the most recent on
+// top, and the redo stack the events that successive redos would apply,
+// the next one on top.
 type TEStack struct {
-	Top  *Element
-	Size int
-}
-
-// An Element which is stored in the Stack
-type Element struct {
-	Value *TextEvent
-	Next  *Element
+	b    *Buffer
+	redo bool
 }
 
 // Len returns the stack's length
 func (s *TEStack) Len() int {
-	return s.Size
-}
-
-// Push a new element onto the stack
-func (s *TEStack) Push(value *TextEvent) {
-	s.Top = &Element{value, s.Top}
-	s.Size++
-}
-
-// Pop removes the top element from the stack and returns its value
-// If the stack is empty, return nil
-func (s *TEStack) Pop() (value *TextEvent) {
-	if s.Size > 0 {
-		value, s.Top = s.Top.Value, s.Top.Next
-		s.Size--
-		return
+	t := s.b.UndoTree
+	if !s.redo {
+		return t.Depth(t.Cur)
 	}
-	return nil
+	n := 0
+	for r := t.Current().Redo; r >= 0; r = t.Nodes[r].Redo {
+		n++
+	}
+	return n
 }
 
 // Peek returns the top element of the stack without removing it
+// If the stack is empty, return nil
 func (s *TEStack) Peek() *TextEvent {
-	if s.Size > 0 {
-		return s.Top.Value
+	if s.redo {
+		return s.b.redoEvent()
 	}
-	return nil
+	return s.b.undoEvent()
 }
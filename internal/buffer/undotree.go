@@ -0,0 +1,157 @@
+package buffer
+
+import (
+	"time"
+)
+
+// An UndoNode is a state of the buffer in the undo tree. Every node except
+// the root holds the text event that leads to it from its parent state.
+type UndoNode struct {
+	// Seq is the sequence number of the state, in the order the states were
+	// created. The root, which is the state of the file when it was opened,
+	// has the sequence number 0.
+	Seq      int
+	Parent   int
+	Children []int
+	// Redo is the child that a redo moves to: the most recently created or
+	// visited one, or -1 if the state has no children
+	Redo  int
+	Time  time.Time
+	Event *TextEvent
+}
+
+// An UndoTree stores every state the buffer has been in, so that no branch
+// of the history is lost when the user undoes some changes and then makes
+// new ones. The nodes are stored in a flat list indexed by their sequence
+// number, which keeps the tree easy to serialize.
+type UndoTree struct {
+	Nodes []*UndoNode
+	// Cur is the sequence number of the current state
+	Cur int
+}
+
+// NewUndoTree returns a new undo tree containing only the root state
+func NewUndoTree() *UndoTree {
+	t := new(UndoTree)
+	t.Nodes = []*UndoNode{{Seq: 0, Parent: -1, Redo: -1, Time: time.Now()}}
+	return t
+}
+
+// Current returns the current state
+func (t *UndoTree) Current() *UndoNode {
+	return t.Nodes[t.Cur]
+}
+
+// Node returns the state with the given sequence number, or nil if it
+// does not exist
+func (t *UndoTree) Node(seq int) *UndoNode {
+	if seq < 0 || seq >= len(t.Nodes) {
+		return nil
+	}
+	return t.Nodes[seq]
+}
+
+// Len returns the number of states in the tree, including the root
+func (t *UndoTree) Len() int {
+	return len(t.Nodes)
+}
+
+// Depth returns the number of changes between the root and the given state
+func (t *UndoTree) Depth(seq int) int {
+	d := 0
+	for seq > 0 {
+		seq = t.Nodes[seq].Parent
+		d++
+	}
+	return d
+}
+
+// Leaves returns the sequence numbers of the states that have no children,
+// i.e. the ends of all the branches, in the order they were created
+func (t *UndoTree) Leaves() []int {
+	var leaves []int
+	for _, n := range t.Nodes {
+		if len(n.Children) == 0 {
+			leaves = append(leaves, n.Seq)
+		}
+	}
+	return leaves
+}
+
+// Applied returns whether the change leading to the given state is
+// currently applied to the buffer, i.e. whether the state is the current
+// state or one of its ancestors
+func (t *UndoTree) Applied(seq int) bool {
+	for s := t.Cur; s >= 0; s = t.Nodes[s].Parent {
+		if s == seq {
+			return true
+		}
+	}
+	return false
+}
+
+// EventType returns the type of the change leading to the given state, as
+// it was originally made. Undoing a text event reverses its type in place,
+// so the type stored in the event is reversed for the states that are
+// currently undone. The root state has no change and must not be passed.
+func (t *UndoTree) EventType(seq int) int {
+	e := t.Nodes[seq].Event
+	if t.Applied(seq) {
+		return e.EventType
+	}
+	return -e.EventType
+}
+
+// StateAt returns the sequence number of the last state that was created
+// at or before the given time, or 0 if there is none
+func (t *UndoTree) StateAt(tm time.Time) int {
+	for i := len(t.Nodes) - 1; i > 0; i-- {
+		if !t.Nodes[i].Time.After(tm) {
+			return i
+		}
+	}
+	return 0
+}
+
+// add adds a new state as a child of the current state and makes it the
+// current state
+func (t *UndoTree) add(e *TextEvent) *UndoNode {
+	n := &UndoNode{
+		Seq:    len(t.Nodes),
+		Parent: t.Cur,
+		Redo:   -1,
+		Time:   e.Time,
+		Event:  e,
+	}
+	t.Nodes = append(t.Nodes, n)
+
+	cur := t.Current()
+	cur.Children = append(cur.Children, n.Seq)
+	cur.Redo = n.Seq
+	t.Cur = n.Seq
+	return n
+}
+
+// path returns the states that need to be undone to get from the current
+// state to the common ancestor with the state seq, and the states that
+// then need to be redone to get to seq, in order
+func (t *UndoTree) path(seq int) (undo []int, redo []int) {
+	ancestors := make(map[int]bool)
+	for s := seq; s >= 0; s = t.Nodes[s].Parent {
+		ancestors[s] = true
+	}
+
+	s := t.Cur
+	for !ancestors[s] {
+		undo = append(undo, s)
+		s = t.Nodes[s].Parent
+	}
+
+	for r := seq; r != s; r = t.Nodes[r].Parent {
+		redo = append(redo, r)
+	}
+	for i, j := 0, len(redo)-1; i < j; i, j = i+1, j-1 {
+		redo[i], redo[j] = redo[j], redo[i]
+	}
+	return undo, redo
+}
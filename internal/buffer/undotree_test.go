@@ -0,0 +1,120 @@
+package buffer
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUndoTree(t *testing.T) {
+	tree := NewUndoTree()
+	e1 := &TextEvent{EventType: TextEventInsert, Time: time.Now()}
+	e2 := &TextEvent{EventType: TextEventInsert, Time: time.Now()}
+	e3 := &TextEvent{EventType: TextEventRemove, Time: time.Now()}
+
+	tree.add(e1)
+	tree.add(e2)
+	tree.Cur = 1
+	tree.add(e3)
+
+	assert.Equal(t, 4, tree.Len())
+	assert.Equal(t, 3, tree.Cur)
+	assert.Equal(t, []int{2, 3}, tree.Node(1).Children)
+	assert.Equal(t, 3, tree.Node(1).Redo)
+	assert.Equal(t, []int{2, 3}, tree.Leaves())
+	assert.Equal(t, 2, tree.Depth(3))
+
+	undo, redo := tree.path(2)
+	assert.Equal(t, []int{3}, undo)
+	assert.Equal(t, []int{2}, redo)
+
+	undo, redo = tree.path(0)
+	assert.Equal(t, []int{3, 1}, undo)
+	assert.Empty(t, redo)
+}
+
+func TestUndoBranches(t *testing.T) {
+	b := NewBufferFromString("abc", "", BTDefault)
+	defer b.Close()
+
+	b.Insert(Loc{3, 0}, "d")
+	b.Insert(Loc{4, 0}, "e")
+	b.UndoOneEvent()
+	assert.Equal(t, "abcd", string(b.Bytes()))
+
+	// a new edit after an undo starts a new branch, keeping the old one
+	b.Insert(Loc{4, 0}, "x")
+	assert.Equal(t, "abcdx", string(b.Bytes()))
+	assert.Equal(t, 4, b.UndoTree.Len())
+
+	assert.True(t, b.GotoState(2))
+	assert.Equal(t, "abcde", string(b.Bytes()))
+	assert.True(t, b.GotoState(0))
+	assert.Equal(t, "abc", string(b.Bytes()))
+	assert.False(t, b.GotoState(10))
+
+	assert.True(t, b.Later(3))
+	assert.Equal(t, "abcdx", string(b.Bytes()))
+	assert.True(t, b.Earlier(1))
+	assert.Equal(t, "abcde", string(b.Bytes()))
+
+	// the redo follows the branch that was visited last
+	b.UndoOneEvent()
+	assert.Equal(t, 1, b.UndoStack.Len())
+	assert.Equal(t, 1, b.RedoStack.Len())
+	assert.Equal(t, b.UndoTree.Node(2).Event, b.RedoStack.Peek())
+	b.RedoOneEvent()
+	assert.Equal(t, "abcde", string(b.Bytes()))
+	assert.Equal(t, 2, b.UndoStack.Len())
+	assert.Nil(t, b.RedoStack.Peek())
+
+	// undone changes keep their original type
+	assert.True(t, b.UndoTree.Applied(2))
+	assert.False(t, b.UndoTree.Applied(3))
+	assert.Equal(t, TextEventInsert, b.UndoTree.EventType(2))
+	assert.Equal(t, TextEventInsert, b.UndoTree.EventType(3))
+}
+
+func TestUndoTime(t *testing.T) {
+	b := NewBufferFromString("", "", BTDefault)
+	defer b.Close()
+
+	b.Insert(Loc{0, 0}, "a")
+	b.Insert(Loc{1, 0}, "b")
+	b.Insert(Loc{2, 0}, "c")
+
+	now := time.Now()
+	b.UndoTree.Node(0).Time = now.Add(-10 * time.Minute)
+	b.UndoTree.Node(1).Time = now.Add(-5 * time.Minute)
+	b.UndoTree.Node(2).Time = now.Add(-1 * time.Minute)
+	b.UndoTree.Node(3).Time = now
+
+	assert.True(t, b.EarlierTime(2*time.Minute))
+	assert.Equal(t, "a", string(b.Bytes()))
+	assert.True(t, b.EarlierTime(time.Hour))
+	assert.Equal(t, "", string(b.Bytes()))
+	assert.True(t, b.LaterTime(9*time.Minute))
+	assert.Equal(t, "ab", string(b.Bytes()))
+}
+
+func TestUndoTreeSerialize(t *testing.T) {
+	b := NewBufferFromString("", "", BTDefault)
+	defer b.Close()
+
+	b.Insert(Loc{0, 0}, "a")
+	b.UndoOneEvent()
+	b.Insert(Loc{0, 0}, "b")
+
+	var buf bytes.Buffer
+	assert.NoError(t, gob.NewEncoder(&buf).Encode(b.EventHandler))
+
+	var eh EventHandler
+	assert.NoError(t, gob.NewDecoder(&buf).Decode(&eh))
+	assert.Equal(t, 3, eh.UndoTree.Len())
+	assert.Equal(t, 2, eh.UndoTree.Cur)
+	assert.Equal(t, []int{1, 2}, eh.UndoTree.Node(0).Children)
+	assert.Equal(t, []byte("a"), eh.UndoTree.Node(1).Event.Deltas[0].Text)
+}
@@ -26,6 +26,8 @@ var CurrentMethod Method = Internal
 
 // A Register is a buffer used to store text. The system clipboard has the 'clipboard'
 // and 'primary' (linux-only) registers, but other registers may be used internal to micro.
+// The named registers 'a' to 'z' and the history registers '1' to '9' are identified by
+// their name.
 type Register int
 
 const (
@@ -37,6 +39,10 @@ const (
 
 var clipboard clipper.Clipboard
 
+// blocks stores the text of the registers that were last written with a
+// rectangular block
+var blocks = make(map[Register]string)
+
 // Initialize attempts to initialize the clipboard using the given method
 func Initialize(m Method) error {
 	var err error
@@ -75,7 +81,9 @@ func Read(r Register) (string, error) {
 
 // Write writes text to a clipboard register
 func Write(text string, r Register) error {
-	return write(text, r, CurrentMethod)
+	err := write(text, r, CurrentMethod)
+	pushHistory(text, r)
+	return err
 }
 
 // ReadMulti reads text from a clipboard register for a certain multi-cursor
@@ -101,9 +109,31 @@ func ValidMulti(r Register, clip string, ncursors int) bool {
 	return multi.isValid(r, clip, ncursors)
 }
 
+// WriteBlock writes the text of a rectangular block to a clipboard register,
+// and remembers that it is a block
+func WriteBlock(text string, r Register) error {
+	multi[r] = nil
+	err := write(text, r, CurrentMethod)
+	blocks[r] = text
+	pushHistory(text, r)
+	return err
+}
+
+// IsBlock checks if the text read from a clipboard register is the
+// rectangular block that was last written to it
+func IsBlock(r Register, clip string) bool {
+	text, ok := blocks[r]
+	return ok && text == clip
+}
+
 func writeMulti(text string, r Register, num int, ncursors int, m Method) error {
 	multi.writeText(text, r, num, ncursors)
-	return write(multi.getAllText(r), r, m)
+	all := multi.getAllText(r)
+	err := write(all, r, m)
+	if num == ncursors-1 {
+		pushHistory(all, r)
+	}
+	return err
 }
 
 func read(r Register, m Method) (string, error) {
@@ -137,6 +167,7 @@ func read(r Register, m Method) (string, error) {
 }
 
 func write(text string, r Register, m Method) error {
+	delete(blocks, r)
 	switch m {
 	case External:
 		switch r {
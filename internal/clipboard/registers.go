@@ -0,0 +1,169 @@
+package clipboard
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"sort"
+)
+
+// HistoryLen is the number of numbered registers keeping the texts last
+// copied or cut, from '1' (the most recent) to '9'. They make up the kill
+// ring.
+const HistoryLen = 9
+
+// NamedRegister returns the register with the given name: a letter from
+// 'a' to 'z' for the named registers, a digit from '1' to '9' for the
+// history registers, '+' for the clipboard and '*' for the primary
+// clipboard
+func NamedRegister(name rune) (Register, bool) {
+	switch {
+	case name >= 'a' && name <= 'z', name >= '1' && name <= '0'+HistoryLen:
+		return Register(name), true
+	case name == '+':
+		return ClipboardReg, true
+	case name == '*':
+		return PrimaryReg, true
+	}
+	return 0, false
+}
+
+// Name returns the name of the register, as accepted by NamedRegister
+func (r Register) Name() rune {
+	switch r {
+	case ClipboardReg:
+		return '+'
+	case PrimaryReg:
+		return '*'
+	}
+	return rune(r)
+}
+
+func (r Register) isHistory() bool {
+	return r >= '1' && r <= '0'+HistoryLen
+}
+
+// Registers returns the named and history registers that are not empty,
+// sorted by name
+func Registers() []Register {
+	var regs []Register
+	for r, text := range internal {
+		if text != "" && r > 0 {
+			regs = append(regs, r)
+		}
+	}
+	sort.Slice(regs, func(i, j int) bool {
+		return regs[i] < regs[j]
+	})
+	return regs
+}
+
+// pushHistory stores the text just written to the register r, with its
+// multi-cursor contents, in the history register '1' after shifting the
+// history. A text equal to the most recent one replaces it instead, so that
+// the history holds no duplicates in a row.
+func pushHistory(text string, r Register) {
+	if r == PrimaryReg || r.isHistory() || text == "" {
+		return
+	}
+
+	first := Register('1')
+	if internal[first] != text {
+		shiftHistory()
+	}
+	internal[first] = text
+	multi[first] = append([]string(nil), multi[r]...)
+	copyBlock(r, first)
+}
+
+// shiftHistory moves the content of every history register to the next
+// one, dropping the oldest
+func shiftHistory() {
+	first := Register('1')
+	for h := first + HistoryLen - 1; h > first; h-- {
+		internal[h] = internal[h-1]
+		multi[h] = multi[h-1]
+		copyBlock(h-1, h)
+	}
+}
+
+// Ring returns the kill ring, i.e. the texts of the history registers that
+// are not empty, the most recent first
+func Ring() []string {
+	var ring []string
+	for h := Register('1'); h < '1'+HistoryLen; h++ {
+		if internal[h] != "" {
+			ring = append(ring, internal[h])
+		}
+	}
+	return ring
+}
+
+// AddToRing adds a text that was not copied or cut in micro, such as the
+// content of the system clipboard written by another application, to the
+// kill ring if it is not in it yet
+func AddToRing(text string) {
+	if text == "" {
+		return
+	}
+	for _, t := range Ring() {
+		if t == text {
+			return
+		}
+	}
+
+	first := Register('1')
+	shiftHistory()
+	internal[first] = text
+	multi[first] = nil
+	delete(blocks, first)
+}
+
+func copyBlock(from, to Register) {
+	if text, ok := blocks[from]; ok {
+		blocks[to] = text
+	} else {
+		delete(blocks, to)
+	}
+}
+
+// savedRegister is the content of a register saved across sessions
+type savedRegister struct {
+	Text  string
+	Multi []string
+	Block bool
+}
+
+// EncodeRegisters encodes the contents of the named and history registers
+// so that they can be restored in another session with DecodeRegisters
+func EncodeRegisters() ([]byte, error) {
+	saved := make(map[Register]savedRegister)
+	for _, r := range Registers() {
+		_, block := blocks[r]
+		saved[r] = savedRegister{internal[r], multi[r], block}
+	}
+
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(saved)
+	return buf.Bytes(), err
+}
+
+// DecodeRegisters restores the registers encoded by EncodeRegisters
+func DecodeRegisters(rd io.Reader) error {
+	var saved map[Register]savedRegister
+	if err := gob.NewDecoder(rd).Decode(&saved); err != nil {
+		return err
+	}
+
+	for r, s := range saved {
+		if _, ok := NamedRegister(r.Name()); !ok || r < 0 {
+			continue
+		}
+		internal[r] = s.Text
+		multi[r] = s.Multi
+		if s.Block {
+			blocks[r] = s.Text
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package clipboard
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterHistory(t *testing.T) {
+	a, _ := NamedRegister('a')
+	Write("one", ClipboardReg)
+	Write("two", a)
+	WriteMulti("x", ClipboardReg, 0, 2)
+	WriteMulti("y", ClipboardReg, 1, 2)
+
+	for i, want := range []string{"xy", "two", "one"} {
+		clip, _ := Read(Register('1' + i))
+		assert.Equal(t, want, clip)
+	}
+	clip, _ := ReadMulti('1', 1, 2)
+	assert.Equal(t, "y", clip)
+
+	// a text extending the last one is a new entry, the same text is not
+	Write("xyz", ClipboardReg)
+	Write("xyz", ClipboardReg)
+	for i, want := range []string{"xyz", "xy", "two"} {
+		clip, _ := Read(Register('1' + i))
+		assert.Equal(t, want, clip)
+	}
+
+	data, err := EncodeRegisters()
+	assert.NoError(t, err)
+	Write("three", a)
+	assert.NoError(t, DecodeRegisters(bytes.NewReader(data)))
+	clip, _ = Read(a)
+	assert.Equal(t, "two", clip)
+}
+
+func TestRing(t *testing.T) {
+	Write("first", ClipboardReg)
+	Write("second", ClipboardReg)
+	AddToRing("outside")
+	AddToRing("first")
+	ring := Ring()
+	assert.Equal(t, []string{"outside", "second", "first"}, ring[:3])
+
+	for i := 0; i < HistoryLen+2; i++ {
+		AddToRing(string(rune('A' + i)))
+	}
+	assert.Len(t, Ring(), HistoryLen)
+	assert.Equal(t, "K", Ring()[0])
+}
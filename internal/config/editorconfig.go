@@ -0,0 +1,366 @@
+package config
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// An EditorConfigOption is the value of an option set by an .editorconfig
+// file
+type EditorConfigOption struct {
+	Value any
+	// File is the path of the .editorconfig file setting the option
+	File string
+	// BOM is set for the encoding if the file is saved with a byte order
+	// mark
+	BOM bool
+}
+
+// an editorConfigProp is the value of a property of an .editorconfig file
+// and the path of the file
+type editorConfigProp struct {
+	value string
+	file  string
+}
+
+// an editorConfigSection is a section of an .editorconfig file, whose
+// properties apply to the files matching its glob
+type editorConfigSection struct {
+	glob  *regexp.Regexp
+	props [][2]string
+}
+
+// UpdateEditorConfigLocals sets the options given by the .editorconfig files
+// of the directory of the file at the given absolute path and of its parent
+// directories. It returns the options which have been set.
+func UpdateEditorConfigLocals(settings map[string]any, path string) map[string]EditorConfigOption {
+	options := editorConfigOptions(editorConfigProps(path))
+	for k, o := range options {
+		settings[k] = o.Value
+	}
+	return options
+}
+
+// editorConfigProps returns the properties given to the file at the given
+// path by the .editorconfig files of its directory and of its parents, up to
+// the first one with root = true. The files closest to the file take
+// precedence.
+func editorConfigProps(path string) map[string]editorConfigProp {
+	type editorConfigFile struct {
+		path     string
+		sections []editorConfigSection
+	}
+	var files []editorConfigFile
+	for dir := filepath.Dir(path); ; {
+		file := filepath.Join(dir, ".editorconfig")
+		if root, sections, err := parseEditorConfig(file); err == nil {
+			files = append(files, editorConfigFile{file, sections})
+			if root {
+				break
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	props := make(map[string]editorConfigProp)
+	name := filepath.ToSlash(path)
+	for i := len(files) - 1; i >= 0; i-- {
+		for _, s := range files[i].sections {
+			if !s.glob.MatchString(name) {
+				continue
+			}
+			for _, p := range s.props {
+				if p[1] == "unset" {
+					delete(props, p[0])
+				} else {
+					props[p[0]] = editorConfigProp{p[1], files[i].path}
+				}
+			}
+		}
+	}
+	return props
+}
+
+// parseEditorConfig parses the given .editorconfig file. The lines which are
+// not valid are ignored, as are the sections with an invalid glob.
+func parseEditorConfig(file string) (bool, []editorConfigSection, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return false, nil, err
+	}
+	defer f.Close()
+
+	dir := filepath.ToSlash(filepath.Dir(file))
+	root := false
+	var sections []editorConfigSection
+	// invalid is set in a section with an invalid glob, whose properties
+	// are ignored
+	invalid := false
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' {
+			end := strings.LastIndexByte(line, ']')
+			if end < 0 {
+				continue
+			}
+			glob, err := editorConfigGlob(dir, line[1:end])
+			invalid = err != nil
+			if !invalid {
+				sections = append(sections, editorConfigSection{glob: glob})
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.ToLower(strings.TrimSpace(value))
+		if invalid {
+			continue
+		}
+		if len(sections) == 0 {
+			// the preamble, before the first section
+			if key == "root" {
+				root = value == "true"
+			}
+		} else {
+			cur := &sections[len(sections)-1]
+			cur.props = append(cur.props, [2]string{key, value})
+		}
+	}
+	return root, sections, scanner.Err()
+}
+
+// editorConfigGlob returns the regular expression matching the paths of the
+// files matched by the given section name of an .editorconfig file in the
+// given directory. A name without a slash matches files in any
+// subdirectory.
+func editorConfigGlob(dir, name string) (*regexp.Regexp, error) {
+	prefix := regexp.QuoteMeta(strings.TrimSuffix(dir, "/")) + "/"
+	if !strings.Contains(name, "/") {
+		prefix += "(?:.*/)?"
+	}
+	name = strings.TrimPrefix(name, "/")
+
+	var re strings.Builder
+	// braces holds for each open brace whether it is an alternation
+	var braces []bool
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch c {
+		case '\\':
+			if i+1 < len(name) {
+				i++
+			}
+			re.WriteString(regexp.QuoteMeta(name[i : i+1]))
+		case '*':
+			if i+1 < len(name) && name[i+1] == '*' {
+				i++
+				if i+1 < len(name) && name[i+1] == '/' && (i == 1 || name[i-2] == '/') {
+					// a/**/b also matches a/b
+					i++
+					re.WriteString("(?:.*/)?")
+				} else {
+					re.WriteString(".*")
+				}
+			} else {
+				re.WriteString("[^/]*")
+			}
+		case '?':
+			re.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(name[i+1:], ']')
+			if end < 0 || strings.Contains(name[i+1:i+1+end], "/") {
+				re.WriteString(`\[`)
+				break
+			}
+			class := name[i+1 : i+1+end]
+			re.WriteByte('[')
+			if strings.HasPrefix(class, "!") {
+				re.WriteByte('^')
+				class = class[1:]
+			} else if strings.HasPrefix(class, "^") {
+				re.WriteByte('\\')
+			}
+			re.WriteString(strings.NewReplacer(`\`, `\\`, "[", `\[`).Replace(class))
+			re.WriteByte(']')
+			i += end + 1
+		case '{':
+			end, alternation := closingBrace(name, i)
+			if end < 0 {
+				re.WriteString(`\{`)
+			} else if r, ok := numberRange(name[i+1 : end]); ok {
+				re.WriteString(r)
+				i = end
+			} else if alternation {
+				re.WriteString("(?:")
+				braces = append(braces, true)
+			} else {
+				re.WriteString(`\{`)
+				braces = append(braces, false)
+			}
+		case '}':
+			if len(braces) > 0 && braces[len(braces)-1] {
+				re.WriteByte(')')
+			} else {
+				re.WriteString(`\}`)
+			}
+			if len(braces) > 0 {
+				braces = braces[:len(braces)-1]
+			}
+		case ',':
+			if len(braces) > 0 && braces[len(braces)-1] {
+				re.WriteByte('|')
+			} else {
+				re.WriteByte(',')
+			}
+		default:
+			re.WriteString(regexp.QuoteMeta(name[i : i+1]))
+		}
+	}
+	return regexp.Compile("^" + prefix + re.String() + "$")
+}
+
+// closingBrace returns the index of the brace closing the one at index i of
+// the given glob, or -1 if it is not closed, and whether the braces contain
+// a comma, which makes them an alternation
+func closingBrace(glob string, i int) (int, bool) {
+	depth := 0
+	comma := false
+	for j := i; j < len(glob); j++ {
+		switch glob[j] {
+		case '\\':
+			j++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return j, comma
+			}
+		case ',':
+			if depth == 1 {
+				comma = true
+			}
+		}
+	}
+	return -1, false
+}
+
+// numberRange returns a regular expression matching the integers of the
+// given range {num1..num2}
+func numberRange(s string) (string, bool) {
+	first, last, ok := strings.Cut(s, "..")
+	if !ok {
+		return "", false
+	}
+	a, err1 := strconv.Atoi(first)
+	b, err2 := strconv.Atoi(last)
+	if err1 != nil || err2 != nil {
+		return "", false
+	}
+	if a > b {
+		a, b = b, a
+	}
+	if b-a > 1000 {
+		// too long to be written out
+		return "", false
+	}
+	nums := make([]string, 0, b-a+1)
+	for n := a; n <= b; n++ {
+		nums = append(nums, strconv.Itoa(n))
+	}
+	return "(?:" + strings.Join(nums, "|") + ")", true
+}
+
+// editorConfigOptions returns the options of micro corresponding to the
+// given properties of .editorconfig files
+func editorConfigOptions(props map[string]editorConfigProp) map[string]EditorConfigOption {
+	options := make(map[string]EditorConfigOption)
+	set := func(option string, value any, p editorConfigProp) {
+		options[option] = EditorConfigOption{value, p.file, false}
+	}
+	number := func(p editorConfigProp) (float64, bool) {
+		n, err := strconv.Atoi(p.value)
+		return float64(n), err == nil && n > 0
+	}
+
+	style, hasStyle := props["indent_style"]
+	switch style.value {
+	case "tab":
+		set("tabstospaces", false, style)
+	case "space":
+		set("tabstospaces", true, style)
+	}
+
+	// micro indents with tabsize spaces, so indent_size only gives the tab
+	// size when the indentation is made of spaces or the tab width is not
+	// given
+	size, hasSize := props["indent_size"]
+	width, hasWidth := props["tab_width"]
+	if size.value == "tab" || (hasStyle && style.value == "tab" && hasWidth) {
+		hasSize = false
+	}
+	if n, ok := number(size); hasSize && ok {
+		set("tabsize", n, size)
+	} else if n, ok := number(width); hasWidth && ok {
+		set("tabsize", n, width)
+	}
+
+	if p, ok := props["end_of_line"]; ok {
+		switch p.value {
+		case "lf":
+			set("fileformat", "unix", p)
+		case "crlf":
+			set("fileformat", "dos", p)
+		case "cr":
+			set("fileformat", "mac", p)
+		}
+	}
+
+	if p, ok := props["charset"]; ok {
+		switch p.value {
+		case "latin1":
+			set("encoding", "iso-8859-1", p)
+		case "utf-8":
+			set("encoding", "utf-8", p)
+		case "utf-8-bom":
+			options["encoding"] = EditorConfigOption{"utf-8", p.file, true}
+		case "utf-16be", "utf-16le":
+			set("encoding", p.value, p)
+		}
+	}
+
+	bools := map[string]string{
+		"trim_trailing_whitespace": "rmtrailingws",
+		"insert_final_newline":     "eofnewline",
+	}
+	for prop, option := range bools {
+		if p, ok := props[prop]; ok && (p.value == "true" || p.value == "false") {
+			set(option, p.value == "true", p)
+		}
+	}
+
+	if p, ok := props["max_line_length"]; ok {
+		if p.value == "off" {
+			set("colorcolumn", float64(0), p)
+		} else if n, ok := number(p); ok {
+			set("colorcolumn", n, p)
+		}
+	}
+	return options
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEditorConfigGlob(t *testing.T) {
+	matches := func(name, path string) bool {
+		re, err := editorConfigGlob("/p", name)
+		assert.NoError(t, err)
+		return re.MatchString(path)
+	}
+	assert.True(t, matches("*", "/p/a/b.go"))
+	assert.True(t, matches("*.go", "/p/a/b.go"))
+	assert.False(t, matches("*.go", "/p/a/b.gox"))
+	assert.True(t, matches("/*.go", "/p/b.go"))
+	assert.False(t, matches("/*.go", "/p/a/b.go"))
+	assert.True(t, matches("src/**/*.c", "/p/src/x/y/z.c"))
+	assert.True(t, matches("src/**/z.c", "/p/src/z.c"))
+	assert.True(t, matches("*.{js,ts}", "/p/a.ts"))
+	assert.False(t, matches("*.{js,ts}", "/p/a.go"))
+	assert.True(t, matches("{single}", "/p/{single}"))
+	assert.True(t, matches("file{1..12}.txt", "/p/file10.txt"))
+	assert.False(t, matches("file{1..12}.txt", "/p/file13.txt"))
+	assert.True(t, matches("[!a]bc", "/p/xbc"))
+	assert.False(t, matches("[!a]bc", "/p/abc"))
+	assert.True(t, matches("Makefile", "/p/sub/Makefile"))
+}
+
+func TestUpdateEditorConfigLocals(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "sub")
+	assert.NoError(t, os.Mkdir(sub, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".editorconfig"), []byte(`
+root = true
+
+[*]
+indent_style = space
+indent_size = 2
+end_of_line = crlf
+max_line_length = 80
+
+[*.go]
+indent_style = tab
+tab_width = 8
+`), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(sub, ".editorconfig"), []byte(`
+# closer files take precedence
+[*]
+max_line_length = unset
+trim_trailing_whitespace = TRUE
+charset = latin1
+`), 0644))
+
+	settings := map[string]any{"colorcolumn": float64(0)}
+	options := UpdateEditorConfigLocals(settings, filepath.Join(sub, "main.go"))
+	assert.Equal(t, map[string]any{
+		"colorcolumn":  float64(0),
+		"tabstospaces": false,
+		"tabsize":      float64(8),
+		"fileformat":   "dos",
+		"rmtrailingws": true,
+		"encoding":     "iso-8859-1",
+	}, settings)
+	assert.Equal(t, filepath.Join(sub, ".editorconfig"), options["rmtrailingws"].File)
+	assert.Equal(t, filepath.Join(dir, ".editorconfig"), options["tabsize"].File)
+
+	settings = map[string]any{}
+	UpdateEditorConfigLocals(settings, filepath.Join(dir, "README.md"))
+	assert.Equal(t, map[string]any{
+		"tabstospaces": true,
+		"tabsize":      float64(2),
+		"fileformat":   "dos",
+		"colorcolumn":  float64(80),
+	}, settings)
+
+	// utf-8-bom is utf-8 with a byte order mark
+	assert.NoError(t, os.WriteFile(filepath.Join(sub, ".editorconfig"), []byte("[*]\ncharset = utf-8-bom\n"), 0644))
+	options = UpdateEditorConfigLocals(map[string]any{}, filepath.Join(sub, "main.go"))
+	assert.Equal(t, "utf-8", options["encoding"].Value)
+	assert.True(t, options["encoding"].BOM)
+}
@@ -14,7 +14,9 @@ import (
 	"github.com/micro-editor/json5"
 	"github.com/micro-editor/micro/v2/internal/util"
 	"github.com/zyedidia/glob"
+	"golang.org/x/text/encoding"
 	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode/utf32"
 )
 
 type optionValidator func(string, any) error
@@ -27,11 +29,15 @@ var optionValidators = map[string]optionValidator{
 	"colorscheme":     validateColorscheme,
 	"detectlimit":     validateNonNegativeValue,
 	"encoding":        validateEncoding,
-	"fileformat":      validateChoice,
+	"fileformat":      validateFileFormat,
+	"foldmethod":      validateChoice,
+	"gitdiffbase":     validateChoice,
 	"helpsplit":       validateChoice,
+	"largefile":       validateNonNegativeValue,
 	"matchbracestyle": validateChoice,
 	"multiopen":       validateChoice,
 	"pageoverlap":     validateNonNegativeValue,
+	"piecetable":      validateNonNegativeValue,
 	"reload":          validateChoice,
 	"scrollmargin":    validateNonNegativeValue,
 	"scrollspeed":     validateNonNegativeValue,
@@ -42,7 +48,9 @@ var optionValidators = map[string]optionValidator{
 // a list of settings with pre-defined choices
 var OptionChoices = map[string][]string{
 	"clipboard":       {"internal", "external", "terminal"},
-	"fileformat":      {"unix", "dos"},
+	"fileformat":      {"unix", "dos", "mac"},
+	"foldmethod":      {"indent", "syntax"},
+	"gitdiffbase":     {"head", "index", "off"},
 	"helpsplit":       {"hsplit", "vsplit"},
 	"matchbracestyle": {"underline", "highlight"},
 	"multiopen":       {"tab", "hsplit", "vsplit"},
@@ -53,68 +61,77 @@ var OptionChoices = map[string][]string{
 // a list of settings that can be globally and locally modified and their
 // default values
 var defaultCommonSettings = map[string]any{
-	"autoindent":      true,
-	"autosu":          false,
-	"backup":          true,
-	"backupdir":       "",
-	"basename":        false,
-	"colorcolumn":     float64(0),
-	"cursorline":      true,
-	"detectlimit":     float64(100),
-	"diffgutter":      false,
-	"encoding":        "utf-8",
-	"eofnewline":      true,
-	"fastdirty":       false,
-	"fileformat":      defaultFileFormat(),
-	"filetype":        "unknown",
-	"hlsearch":        false,
-	"hltaberrors":     false,
-	"hltrailingws":    false,
-	"ignorecase":      true,
-	"incsearch":       true,
-	"indentchar":      " ", // Deprecated
-	"keepautoindent":  false,
-	"matchbrace":      true,
-	"matchbraceleft":  true,
-	"matchbracestyle": "underline",
-	"mkparents":       false,
-	"pageoverlap":     float64(2),
-	"permbackup":      false,
-	"readonly":        false,
-	"relativeruler":   false,
-	"reload":          "prompt",
-	"rmtrailingws":    false,
-	"ruler":           true,
-	"savecursor":      false,
-	"saveundo":        false,
-	"scrollbar":       false,
-	"scrollmargin":    float64(3),
-	"scrollspeed":     float64(2),
-	"showchars":       "",
-	"smartpaste":      true,
-	"softwrap":        false,
-	"splitbottom":     true,
-	"splitright":      true,
-	"statusformatl":   "$(filename) $(modified)$(overwrite)($(line),$(col)) $(status.paste)| ft:$(opt:filetype) | $(opt:fileformat) | $(opt:encoding)",
-	"statusformatr":   "$(bind:ToggleKeyMenu): bindings, $(bind:ToggleHelp): help",
-	"statusline":      true,
-	"syntax":          true,
-	"tabmovement":     false,
-	"tabsize":         float64(4),
-	"tabstospaces":    false,
-	"truecolor":       "auto",
-	"useprimary":      true,
-	"wordwrap":        false,
+	"autodetectindent": false,
+	"autoindent":       true,
+	"autosu":           false,
+	"backup":           true,
+	"backupdir":        "",
+	"basename":         false,
+	"colorcolumn":      float64(0),
+	"cursorline":       true,
+	"detectlimit":      float64(100),
+	"diffgutter":       false,
+	"editorconfig":     true,
+	"encoding":         "utf-8",
+	"eofnewline":       true,
+	"fastdirty":        false,
+	"fileformat":       defaultFileFormat(),
+	"filetype":         "unknown",
+	"foldmethod":       "indent",
+	"gitdiffbase":      "head",
+	"hlsearch":         false,
+	"hltaberrors":      false,
+	"hltrailingws":     false,
+	"ignorecase":       true,
+	"incsearch":        true,
+	"indentchar":       " ", // Deprecated
+	"keepautoindent":   false,
+	"largefile":        float64(10),
+	"lockfile":         true,
+	"matchbrace":       true,
+	"matchbraceleft":   true,
+	"matchbracestyle":  "underline",
+	"mkparents":        false,
+	"pageoverlap":      float64(2),
+	"permbackup":       false,
+	"piecetable":       float64(64),
+	"readonly":         false,
+	"relativeruler":    false,
+	"reload":           "prompt",
+	"rmtrailingws":     false,
+	"ruler":            true,
+	"savecursor":       false,
+	"saveundo":         false,
+	"scrollbar":        false,
+	"scrollmargin":     float64(3),
+	"scrollspeed":      float64(2),
+	"showchars":        "",
+	"smartpaste":       true,
+	"softwrap":         false,
+	"splitbottom":      true,
+	"splitright":       true,
+	"statusformatl":    "$(filename) $(modified)$(overwrite)$(largefile)$(mixedendings)($(line),$(col)) $(status.paste)| ft:$(opt:filetype) | $(opt:fileformat) | $(opt:encoding)",
+	"statusformatr":    "$(bind:ToggleKeyMenu): bindings, $(bind:ToggleHelp): help",
+	"statusline":       true,
+	"syntax":           true,
+	"tabmovement":      false,
+	"tabsize":          float64(4),
+	"tabstospaces":     false,
+	"truecolor":        "auto",
+	"useprimary":       true,
+	"wordwrap":         false,
 }
 
 // a list of settings that should only be globally modified and their
 // default values
 var DefaultGlobalOnlySettings = map[string]any{
 	"autosave":       float64(0),
+	"autosession":    false,
 	"clipboard":      "external",
 	"colorscheme":    "default",
 	"divchars":       "|-",
 	"divreverse":     true,
+	"errorformat":    "%f:%l:%c: %m,%f:%l: %m",
 	"fakecursor":     defaultFakeCursor(),
 	"helpsplit":      "hsplit",
 	"infobar":        true,
@@ -570,6 +587,15 @@ func validateChoice(option string, value any) error {
 	return errors.New("Option has no pre-defined choices")
 }
 
+// validateFileFormat rejects mixed, which is only the value of the option
+// for the buffers whose lines have different line endings
+func validateFileFormat(option string, value any) error {
+	if value == "mixed" {
+		return errors.New("fileformat cannot be set to mixed, use normalizeeol to give every line the same line ending")
+	}
+	return validateChoice(option, value)
+}
+
 func validateColorscheme(option string, value any) error {
 	colorscheme, ok := value.(string)
 
@@ -584,7 +610,26 @@ func validateColorscheme(option string, value any) error {
 	return nil
 }
 
+// GetEncoding returns the encoding with the given name, which is one of the
+// names listed by the WHATWG Encoding Standard, utf-32le or utf-32be
+func GetEncoding(name string) (encoding.Encoding, error) {
+	switch strings.ToLower(name) {
+	case "utf-32", "utf-32le":
+		return utf32.UTF32(utf32.LittleEndian, utf32.IgnoreBOM), nil
+	case "utf-32be":
+		return utf32.UTF32(utf32.BigEndian, utf32.IgnoreBOM), nil
+	}
+	return htmlindex.Get(name)
+}
+
 func validateEncoding(option string, value any) error {
-	_, err := htmlindex.Get(value.(string))
+	name, ok := value.(string)
+	if !ok {
+		return errors.New("Expected string type for " + option)
+	}
+	if name == "auto" {
+		return nil
+	}
+	_, err := GetEncoding(name)
 	return err
 }
@@ -29,6 +29,10 @@ type BufWindow struct {
 	hasMessage       bool
 	maxLineNumLength int
 	drawDivider      bool
+
+	popup *popup
+	// diffSide is set if the window shows a side of a side by side diff
+	diffSide *DiffSide
 }
 
 // NewBufWindow creates a new window at a location in the screen with a width and height
@@ -137,7 +141,7 @@ func (w *BufWindow) updateDisplayInfo() {
 	}
 
 	scrollbarWidth := 0
-	if w.Buf.Settings["scrollbar"].(bool) && w.Buf.LinesNum() > w.Height && w.Width > 0 {
+	if w.Buf.Settings["scrollbar"].(bool) && w.Buf.VisibleLinesNum() > w.Height && w.Width > 0 {
 		scrollbarWidth = 1
 	}
 
@@ -148,6 +152,9 @@ func (w *BufWindow) updateDisplayInfo() {
 	w.maxLineNumLength = len(strconv.Itoa(b.LinesNum()))
 
 	w.gutterOffset = 0
+	if b.BlameShown() {
+		w.gutterOffset += buffer.BlameWidth + 1
+	}
 	if w.hasMessage {
 		w.gutterOffset += 2
 	}
@@ -224,6 +231,11 @@ func (w *BufWindow) Relocate() bool {
 	activeC := w.Buf.GetActiveCursor()
 	scrollmargin := int(b.Settings["scrollmargin"].(float64))
 
+	// The cursor cannot stay hidden, e.g. after a search
+	if b.IsHidden(activeC.Y) {
+		b.Unfold(activeC.Y)
+	}
+
 	c := w.SLocFromLoc(activeC.Loc)
 	bStart := SLoc{0, 0}
 	bEnd := w.SLocFromLoc(b.End())
@@ -295,6 +307,32 @@ func (w *BufWindow) drawGutter(vloc *buffer.Loc, bloc *buffer.Loc) {
 	}
 }
 
+// drawBlame draws the annotation of a line shown by the gitblame command
+func (w *BufWindow) drawBlame(style tcell.Style, softwrapped bool, vloc *buffer.Loc, bloc *buffer.Loc) {
+	text := ""
+	if !softwrapped {
+		text = w.Buf.BlameAt(bloc.Y)
+	}
+	width := 0
+	for _, r := range text {
+		rw := runewidth.RuneWidth(r)
+		if width+rw > buffer.BlameWidth || vloc.X+rw > w.gutterOffset {
+			break
+		}
+		screen.SetContent(w.X+vloc.X, w.Y+vloc.Y, r, nil, style)
+		for i := 1; i < rw; i++ {
+			screen.SetContent(w.X+vloc.X+i, w.Y+vloc.Y, ' ', nil, style)
+		}
+		vloc.X += rw
+		width += rw
+	}
+	// the spaces up to the end of the column and the one after it
+	for ; width <= buffer.BlameWidth && vloc.X < w.gutterOffset; width++ {
+		screen.SetContent(w.X+vloc.X, w.Y+vloc.Y, ' ', nil, style)
+		vloc.X++
+	}
+}
+
 func (w *BufWindow) drawDiffGutter(backgroundStyle tcell.Style, softwrapped bool, vloc *buffer.Loc, bloc *buffer.Loc) {
 	if vloc.X >= w.gutterOffset {
 		return
@@ -333,7 +371,7 @@ func (w *BufWindow) drawLineNum(lineNumStyle tcell.Style, softwrapped bool, vloc
 	if w.Buf.Settings["relativeruler"] == false || cursorLine == bloc.Y {
 		lineInt = bloc.Y + 1
 	} else {
-		lineInt = bloc.Y - cursorLine
+		lineInt = w.Buf.VisibleLinesBetween(cursorLine, bloc.Y)
 	}
 	lineNum := []rune(strconv.Itoa(util.Abs(lineInt)))
 
@@ -431,6 +469,9 @@ func (w *BufWindow) displayBuffer() {
 		}
 	}
 
+	// the start line may have been hidden by a fold closed in another window
+	w.StartLine = w.visibleSLoc(w.StartLine)
+
 	softwrap := b.Settings["softwrap"].(bool)
 	wordwrap := softwrap && b.Settings["wordwrap"].(bool)
 
@@ -440,7 +481,7 @@ func (w *BufWindow) displayBuffer() {
 	// this represents the current draw position
 	// within the current window
 	vloc := buffer.Loc{X: 0, Y: 0}
-	if softwrap {
+	if softwrap || w.diffSide != nil {
 		// the start line may be partially out of the current window
 		vloc.Y = -w.StartLine.Row
 	}
@@ -449,6 +490,8 @@ func (w *BufWindow) displayBuffer() {
 	bloc := buffer.Loc{X: -1, Y: w.StartLine.Line}
 
 	cursors := b.GetCursors()
+	// the range of characters of the current line in each block selection
+	blockSel := make([][2]int, len(cursors))
 
 	curStyle := config.DefStyle
 
@@ -476,6 +519,13 @@ func (w *BufWindow) displayBuffer() {
 	}
 
 	for ; vloc.Y < w.bufHeight; vloc.Y++ {
+		for i := w.fillersAbove(bloc.Y); i > 0 && vloc.Y < w.bufHeight; i-- {
+			w.drawDiffFiller(vloc.Y, maxWidth, lineNumStyle)
+			vloc.Y++
+		}
+		if vloc.Y >= w.bufHeight {
+			break
+		}
 		vloc.X = 0
 
 		currentLine := false
@@ -492,6 +542,10 @@ func (w *BufWindow) displayBuffer() {
 		}
 
 		if vloc.Y >= 0 {
+			if b.BlameShown() {
+				w.drawBlame(lineNumStyle, false, &vloc, &bloc)
+			}
+
 			if w.hasMessage {
 				w.drawGutter(&vloc, &bloc)
 			}
@@ -507,6 +561,16 @@ func (w *BufWindow) displayBuffer() {
 			vloc.X = w.gutterOffset
 		}
 
+		for i, c := range cursors {
+			blockSel[i] = [2]int{-1, -1}
+			if c.BlockMode && c.HasSelection() {
+				if y1, y2, _, _ := c.BlockBounds(); bloc.Y >= y1 && bloc.Y <= y2 {
+					start, end := c.BlockLineRange(bloc.Y)
+					blockSel[i] = [2]int{start, end}
+				}
+			}
+		}
+
 		bline := b.LineBytes(bloc.Y)
 		blineLen := util.CharacterCount(bline)
 
@@ -622,6 +686,9 @@ func (w *BufWindow) displayBuffer() {
 			}
 
 			if highlight {
+				if w.diffSide != nil {
+					style = w.diffStyle(style, bloc)
+				}
 				if w.Buf.HighlightSearch && w.Buf.SearchMatch(bloc) {
 					style = config.DefStyle.Reverse(true)
 					if s, ok := config.Colorscheme["hlsearch"]; ok {
@@ -638,10 +705,11 @@ func (w *BufWindow) displayBuffer() {
 					preservebg = true
 				}
 
-				for _, c := range cursors {
-					if c.HasSelection() &&
-						(bloc.GreaterEqual(c.CurSelection[0]) && bloc.LessThan(c.CurSelection[1]) ||
-							bloc.LessThan(c.CurSelection[0]) && bloc.GreaterEqual(c.CurSelection[1])) {
+				for i, c := range cursors {
+					if c.BlockMode && bloc.X >= blockSel[i][0] && bloc.X < blockSel[i][1] ||
+						!c.BlockMode && c.HasSelection() &&
+							(bloc.GreaterEqual(c.CurSelection[0]) && bloc.LessThan(c.CurSelection[1]) ||
+								bloc.LessThan(c.CurSelection[0]) && bloc.GreaterEqual(c.CurSelection[1])) {
 						// The current character is selected
 						style = config.DefStyle.Reverse(true)
 
@@ -690,6 +758,9 @@ func (w *BufWindow) displayBuffer() {
 			vloc.X = 0
 
 			if vloc.Y >= 0 {
+				if b.BlameShown() {
+					w.drawBlame(lineNumStyle, true, &vloc, &bloc)
+				}
 				if w.hasMessage {
 					w.drawGutter(&vloc, &bloc)
 				}
@@ -814,7 +885,7 @@ func (w *BufWindow) displayBuffer() {
 				}
 			}
 		}
-		for i := vloc.X; i < maxWidth; i++ {
+		for i := vloc.X; i < maxWidth && vloc.Y >= 0; i++ {
 			curStyle := style
 			if s, ok := config.Colorscheme["color-column"]; ok {
 				if colorcolumn != 0 && i-w.gutterOffset+w.StartCol == colorcolumn {
@@ -831,11 +902,43 @@ func (w *BufWindow) displayBuffer() {
 			draw(drawrune, nil, drawstyle, true, true, preservebg)
 		}
 
+		if f, ok := b.FoldAt(bloc.Y); ok {
+			w.drawFoldMarker(f, &vloc, maxWidth)
+			bloc.Y = f.End
+		}
+
 		bloc.X = w.StartCol
 		bloc.Y++
 		if bloc.Y >= b.LinesNum() {
+			for i := 1; i <= w.fillersBelow(bloc.Y-1); i++ {
+				w.drawDiffFiller(vloc.Y+i, maxWidth, lineNumStyle)
+			}
+			break
+		}
+	}
+}
+
+// drawFoldMarker draws the number of hidden lines after the first line of
+// a closed fold
+func (w *BufWindow) drawFoldMarker(f buffer.Fold, vloc *buffer.Loc, maxWidth int) {
+	if vloc.Y < 0 {
+		return
+	}
+
+	style := config.DefStyle
+	if s, ok := config.Colorscheme["fold"]; ok {
+		style = s
+	} else if s, ok := config.Colorscheme["comment"]; ok {
+		style = s
+	}
+
+	marker := " \u00b7\u00b7\u00b7 " + strconv.Itoa(f.End-f.Start) + " lines "
+	for _, r := range marker {
+		if vloc.X >= maxWidth {
 			break
 		}
+		screen.SetContent(w.X+vloc.X, w.Y+vloc.Y, r, nil, style)
+		vloc.X++
 	}
 }
 
@@ -868,13 +971,15 @@ func (w *BufWindow) displayStatusLine() {
 }
 
 func (w *BufWindow) displayScrollBar() {
-	if w.Buf.Settings["scrollbar"].(bool) && w.Buf.LinesNum() > w.Height {
+	lines := w.Buf.VisibleLinesNum()
+	if w.Buf.Settings["scrollbar"].(bool) && lines > w.Height {
 		scrollX := w.X + w.Width - 1
-		barsize := int(float64(w.Height) / float64(w.Buf.LinesNum()) * float64(w.Height))
+		barsize := int(float64(w.Height) / float64(lines) * float64(w.Height))
 		if barsize < 1 {
 			barsize = 1
 		}
-		barstart := w.Y + int(float64(w.StartLine.Line)/float64(w.Buf.LinesNum())*float64(w.Height))
+		start := w.Buf.VisibleLinesBetween(0, w.StartLine.Line)
+		barstart := w.Y + int(float64(start)/float64(lines)*float64(w.Height))
 
 		scrollBarStyle := config.DefStyle.Reverse(true)
 		if style, ok := config.Colorscheme["scrollbar"]; ok {
@@ -900,4 +1005,5 @@ func (w *BufWindow) Display() {
 	w.displayStatusLine()
 	w.displayScrollBar()
 	w.displayBuffer()
+	w.displayPopup()
 }
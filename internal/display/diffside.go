@@ -0,0 +1,102 @@
+package display
+
+import (
+	"github.com/micro-editor/micro/v2/internal/buffer"
+	"github.com/micro-editor/micro/v2/internal/config"
+	"github.com/micro-editor/micro/v2/internal/screen"
+	"github.com/micro-editor/tcell/v2"
+)
+
+// A DiffSide describes how to display a buffer compared with another one
+// in a side by side diff
+type DiffSide struct {
+	// Fillers is the number of blank rows drawn above each line so that the
+	// lines of both sides stay aligned. The rows at the key LinesNum() are
+	// drawn below the last line.
+	Fillers map[int]int
+	// Lines is the status of the changed lines: DSAdded for the lines which
+	// are not on the other side, and DSModified for the lines replaced by
+	// lines of the other side
+	Lines map[int]buffer.DiffStatus
+	// Changes is the ranges of characters of the modified lines which
+	// differ from the matching lines of the other side
+	Changes map[int][][2]int
+	// Old is true for the side shown as the older version, whose lines
+	// which are not on the other side are shown as deleted
+	Old bool
+}
+
+// SetDiffSide shows the buffer of the window as a side of a diff, or as
+// usual if d is nil
+func (w *BufWindow) SetDiffSide(d *DiffSide) {
+	w.diffSide = d
+}
+
+// fillersAbove returns the number of filler rows drawn above a line
+func (w *BufWindow) fillersAbove(line int) int {
+	if w.diffSide == nil {
+		return 0
+	}
+	return w.diffSide.Fillers[line]
+}
+
+// fillersBelow returns the number of filler rows drawn below a line
+func (w *BufWindow) fillersBelow(line int) int {
+	if w.diffSide == nil || line != w.Buf.LinesNum()-1 {
+		return 0
+	}
+	return w.diffSide.Fillers[w.Buf.LinesNum()]
+}
+
+func diffColor(name string) (tcell.Color, bool) {
+	if s, ok := config.Colorscheme[name]; ok {
+		fg, _, _ := s.Decompose()
+		return fg, true
+	}
+	return 0, false
+}
+
+// diffStyle returns the style of a character of a side of a diff
+func (w *BufWindow) diffStyle(style tcell.Style, bloc buffer.Loc) tcell.Style {
+	d := w.diffSide
+	status, ok := d.Lines[bloc.Y]
+	if !ok {
+		return style
+	}
+
+	name := "diff-modified"
+	if status == buffer.DSAdded {
+		if d.Old {
+			name = "diff-deleted"
+		} else {
+			name = "diff-added"
+		}
+	}
+	if fg, ok := diffColor(name); ok {
+		style = style.Foreground(fg)
+	}
+	for _, r := range d.Changes[bloc.Y] {
+		if bloc.X >= r[0] && bloc.X < r[1] {
+			return style.Reverse(true)
+		}
+	}
+	return style
+}
+
+// drawDiffFiller draws a filler row of a side of a diff
+func (w *BufWindow) drawDiffFiller(y, maxWidth int, gutterStyle tcell.Style) {
+	if y < 0 || y >= w.bufHeight {
+		return
+	}
+	style := config.DefStyle
+	if fg, ok := diffColor("diff-deleted"); ok {
+		style = style.Foreground(fg)
+	}
+	for x := 0; x < maxWidth; x++ {
+		if x < w.gutterOffset {
+			screen.SetContent(w.X+x, w.Y+y, ' ', nil, gutterStyle)
+		} else {
+			screen.SetContent(w.X+x, w.Y+y, '-', nil, style)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package display
+
+import (
+	runewidth "github.com/mattn/go-runewidth"
+	"github.com/micro-editor/micro/v2/internal/buffer"
+	"github.com/micro-editor/micro/v2/internal/screen"
+	"github.com/micro-editor/micro/v2/internal/util"
+	"github.com/micro-editor/tcell/v2"
+)
+
+// A popup is a box of text drawn over a window next to a location of its
+// buffer, like the text of the messages at the cursor
+type popup struct {
+	loc    buffer.Loc
+	lines  []string
+	styles []tcell.Style
+}
+
+// ShowPopup shows the given lines in a box below the given location of the
+// buffer, or above it if there is not enough room below, until HidePopup
+// is called. The line i is drawn with styles[i].
+func (w *BufWindow) ShowPopup(loc buffer.Loc, lines []string, styles []tcell.Style) {
+	w.popup = &popup{loc, lines, styles}
+}
+
+// HidePopup hides the box shown by ShowPopup
+func (w *BufWindow) HidePopup() {
+	w.popup = nil
+}
+
+func (w *BufWindow) displayPopup() {
+	p := w.popup
+	if p == nil || len(p.lines) == 0 || w.bufWidth <= 0 {
+		return
+	}
+
+	vloc := w.VLocFromLoc(p.loc)
+	row := w.Diff(w.StartLine, vloc.SLoc)
+	if row < 0 || row >= w.bufHeight {
+		return
+	}
+
+	width := 0
+	for _, l := range p.lines {
+		width = util.Max(width, runewidth.StringWidth(l))
+	}
+	// one column of padding on each side
+	width = util.Min(width+2, w.bufWidth)
+	height := util.Min(len(p.lines), w.bufHeight-1)
+
+	x := w.X + w.gutterOffset + vloc.VisualX - w.StartCol
+	x = util.Clamp(x, w.X+w.gutterOffset, w.X+w.gutterOffset+w.bufWidth-width)
+	y := w.Y + row + 1
+	if row+1+height > w.bufHeight && row >= height {
+		y = w.Y + row - height
+	}
+	height = util.Min(height, w.Y+w.bufHeight-y)
+
+	for i := 0; i < height; i++ {
+		style := p.styles[i]
+		col := 0
+		draw := func(r rune) {
+			rw := runewidth.RuneWidth(r)
+			if col+rw > width {
+				return
+			}
+			screen.SetContent(x+col, y+i, r, nil, style)
+			for j := 1; j < rw; j++ {
+				screen.SetContent(x+col+j, y+i, ' ', nil, style)
+			}
+			col += rw
+		}
+		draw(' ')
+		for _, r := range p.lines[i] {
+			draw(r)
+		}
+		for col < width {
+			screen.SetContent(x+col, y+i, ' ', nil, style)
+			col++
+		}
+	}
+}
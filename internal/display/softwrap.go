@@ -223,9 +223,21 @@ func (w *BufWindow) getLocFromVLoc(svloc VLoc) buffer.Loc {
 	return loc
 }
 
+// getRowCount returns the number of rows of a line, including the filler
+// rows of a side of a diff
 func (w *BufWindow) getRowCount(line int) int {
+	n := w.fillersAbove(line) + w.fillersBelow(line)
+	if !w.Buf.Settings["softwrap"].(bool) {
+		return n + 1
+	}
 	eol := buffer.Loc{X: util.CharacterCount(w.Buf.LineBytes(line)), Y: line}
-	return w.getVLocFromLoc(eol).Row + 1
+	return n + w.getVLocFromLoc(eol).Row + 1
+}
+
+// rowScroll returns true if the locations have to be computed row by row,
+// i.e. if lines may take several rows
+func (w *BufWindow) rowScroll() bool {
+	return w.Buf.Settings["softwrap"].(bool) || w.diffSide != nil
 }
 
 func (w *BufWindow) scrollUp(s SLoc, n int) SLoc {
@@ -234,7 +246,7 @@ func (w *BufWindow) scrollUp(s SLoc, n int) SLoc {
 			s.Row -= n
 			n = 0
 		} else if s.Line > 0 {
-			s.Line--
+			s.Line = w.Buf.VisibleLine(s.Line - 1)
 			n -= s.Row + 1
 			s.Row = w.getRowCount(s.Line) - 1
 		} else {
@@ -251,8 +263,8 @@ func (w *BufWindow) scrollDown(s SLoc, n int) SLoc {
 		if n < rc-s.Row {
 			s.Row += n
 			n = 0
-		} else if s.Line < w.Buf.LinesNum()-1 {
-			s.Line++
+		} else if next := w.Buf.MoveLines(s.Line, 1); next != s.Line {
+			s.Line = next
 			n -= rc - s.Row
 			s.Row = 0
 		} else {
@@ -275,7 +287,7 @@ func (w *BufWindow) diff(s1, s2 SLoc) int {
 	for s1.LessThan(s2) {
 		if s1.Line < s2.Line {
 			n += w.getRowCount(s1.Line) - s1.Row
-			s1.Line++
+			s1.Line = w.Buf.MoveLines(s1.Line, 1)
 			s1.Row = 0
 		} else {
 			n += s2.Row - s1.Row
@@ -285,22 +297,32 @@ func (w *BufWindow) diff(s1, s2 SLoc) int {
 	return n
 }
 
+// visibleSLoc returns s if its line is visible, or the location of the
+// first line of the closed fold hiding it
+func (w *BufWindow) visibleSLoc(s SLoc) SLoc {
+	if w.Buf.IsHidden(s.Line) {
+		return SLoc{w.Buf.VisibleLine(s.Line), 0}
+	}
+	return s
+}
+
 // Scroll returns the location which is n visual lines below the location s
 // i.e. the result of scrolling n lines down. n can be negative,
 // which means scrolling up. The returned location is guaranteed to be
-// within the buffer boundaries.
+// within the buffer boundaries. Lines hidden by closed folds are skipped.
 func (w *BufWindow) Scroll(s SLoc, n int) SLoc {
-	if !w.Buf.Settings["softwrap"].(bool) {
-		s.Line = util.Clamp(s.Line+n, 0, w.Buf.LinesNum()-1)
+	if !w.rowScroll() {
+		s.Line = w.Buf.MoveLines(s.Line, n)
 		return s
 	}
-	return w.scroll(s, n)
+	return w.scroll(w.visibleSLoc(s), n)
 }
 
 // Diff returns the difference (the vertical distance) between two SLocs.
 func (w *BufWindow) Diff(s1, s2 SLoc) int {
-	if !w.Buf.Settings["softwrap"].(bool) {
-		return s2.Line - s1.Line
+	s1, s2 = w.visibleSLoc(s1), w.visibleSLoc(s2)
+	if !w.rowScroll() {
+		return w.Buf.VisibleLinesBetween(s1.Line, s2.Line)
 	}
 	if s1.GreaterThan(s2) {
 		return -w.diff(s2, s1)
@@ -309,29 +331,47 @@ func (w *BufWindow) Diff(s1, s2 SLoc) int {
 }
 
 // SLocFromLoc takes a position in the buffer and returns the location
-// of the visual line containing this position.
+// of the visual line containing this position. A position hidden by a
+// closed fold is displayed on the first line of the fold.
 func (w *BufWindow) SLocFromLoc(loc buffer.Loc) SLoc {
+	if w.Buf.IsHidden(loc.Y) {
+		return SLoc{w.Buf.VisibleLine(loc.Y), 0}
+	}
 	if !w.Buf.Settings["softwrap"].(bool) {
-		return SLoc{loc.Y, 0}
+		return SLoc{loc.Y, w.fillersAbove(loc.Y)}
 	}
-	return w.getVLocFromLoc(loc).SLoc
+	sloc := w.getVLocFromLoc(loc).SLoc
+	sloc.Row += w.fillersAbove(loc.Y)
+	return sloc
 }
 
 // VLocFromLoc takes a position in the buffer and returns the corresponding
 // visual location in the linewrapped buffer.
 func (w *BufWindow) VLocFromLoc(loc buffer.Loc) VLoc {
+	if w.Buf.IsHidden(loc.Y) {
+		return VLoc{SLoc{w.Buf.VisibleLine(loc.Y), 0}, 0}
+	}
 	if !w.Buf.Settings["softwrap"].(bool) {
 		tabsize := util.IntOpt(w.Buf.Settings["tabsize"])
 
 		visualx := util.StringWidth(w.Buf.LineBytes(loc.Y), loc.X, tabsize)
-		return VLoc{SLoc{loc.Y, 0}, visualx}
+		return VLoc{SLoc{loc.Y, w.fillersAbove(loc.Y)}, visualx}
 	}
-	return w.getVLocFromLoc(loc)
+	vloc := w.getVLocFromLoc(loc)
+	vloc.Row += w.fillersAbove(loc.Y)
+	return vloc
 }
 
 // LocFromVLoc takes a visual location in the linewrapped buffer and returns
 // the position in the buffer corresponding to this visual location.
 func (w *BufWindow) LocFromVLoc(vloc VLoc) buffer.Loc {
+	if f := w.fillersAbove(vloc.Line); f > 0 {
+		if vloc.Row < f {
+			// a filler row is before the start of the line
+			return buffer.Loc{0, vloc.Line}
+		}
+		vloc.Row -= f
+	}
 	if !w.Buf.Settings["softwrap"].(bool) {
 		tabsize := util.IntOpt(w.Buf.Settings["tabsize"])
 
@@ -39,6 +39,9 @@ var statusInfo = map[string]func(*buffer.Buffer) string{
 		return strconv.Itoa(b.GetActiveCursor().X + 1)
 	},
 	"modified": func(b *buffer.Buffer) string {
+		if b.DeletedOnDisk {
+			return "[deleted] "
+		}
 		if b.Modified() {
 			return "+ "
 		}
@@ -53,12 +56,41 @@ var statusInfo = map[string]func(*buffer.Buffer) string{
 		}
 		return ""
 	},
+	"largefile": func(b *buffer.Buffer) string {
+		if b.LargeFile {
+			return "[large] "
+		}
+		return ""
+	},
+	"mixedendings": func(b *buffer.Buffer) string {
+		if b.MixedEndings() {
+			return "[mixed eol] "
+		}
+		return ""
+	},
+	"indent": func(b *buffer.Buffer) string {
+		indent := "tabs"
+		if b.Settings["tabstospaces"].(bool) {
+			indent = strconv.Itoa(util.IntOpt(b.Settings["tabsize"])) + " spaces"
+		}
+		if b.IndentDetected() {
+			indent += " (detected)"
+		}
+		return indent
+	},
 	"lines": func(b *buffer.Buffer) string {
 		return strconv.Itoa(b.LinesNum())
 	},
 	"percentage": func(b *buffer.Buffer) string {
 		return strconv.Itoa((b.GetActiveCursor().Y + 1) * 100 / b.LinesNum())
 	},
+	"messages": func(b *buffer.Buffer) string {
+		errors, warnings := b.CountMessages(buffer.MTError), b.CountMessages(buffer.MTWarning)
+		if errors == 0 && warnings == 0 {
+			return ""
+		}
+		return fmt.Sprintf("[E:%d W:%d] ", errors, warnings)
+	},
 }
 
 func SetStatusInfoFnLua(fn string) {
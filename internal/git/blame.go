@@ -0,0 +1,88 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A BlameLine tells which commit last changed a line of a file
+type BlameLine struct {
+	Commit  string
+	Author  string
+	Time    time.Time
+	Summary string
+}
+
+// Committed returns false if the line has not been committed yet
+func (l BlameLine) Committed() bool {
+	return strings.Trim(l.Commit, "0") != ""
+}
+
+// Blame returns the commit which last changed each line of the given text
+// of the file at the given path, which may differ from the file on disk.
+// The lines which have not been committed have no commit.
+func Blame(path string, text []byte) ([]BlameLine, error) {
+	dir, name := filepath.Split(path)
+	out, err := run(dir, text, "blame", "--porcelain", "--contents", "-", "--", name)
+	if err != nil {
+		return nil, err
+	}
+	return parseBlame(out), nil
+}
+
+// parseBlame parses the output of git blame --porcelain, in which the
+// information of a commit is only given for its first line
+func parseBlame(out []byte) []BlameLine {
+	var lines []BlameLine
+	commits := make(map[string]*BlameLine)
+
+	var cur *BlameLine
+	final := 0
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		l := scanner.Text()
+		if strings.HasPrefix(l, "\t") {
+			// the text of the line ends its entry
+			if cur != nil && final > 0 {
+				for len(lines) < final {
+					lines = append(lines, BlameLine{})
+				}
+				lines[final-1] = *cur
+			}
+			cur = nil
+			continue
+		}
+
+		key, value, _ := strings.Cut(l, " ")
+		if cur == nil {
+			// the header of an entry: commit, original line, final line
+			fields := strings.Fields(value)
+			if len(fields) < 2 {
+				continue
+			}
+			final, _ = strconv.Atoi(fields[1])
+			if commits[key] == nil {
+				commits[key] = &BlameLine{Commit: key}
+			}
+			cur = commits[key]
+			continue
+		}
+
+		switch key {
+		case "author":
+			cur.Author = value
+		case "author-time":
+			if t, err := strconv.ParseInt(value, 10, 64); err == nil {
+				cur.Time = time.Unix(t, 0)
+			}
+		case "summary":
+			cur.Summary = value
+		}
+	}
+	return lines
+}
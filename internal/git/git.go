@@ -0,0 +1,89 @@
+// Package git gets the versions of a file and its history from the git
+// repository containing it, by running the git command
+package git
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// run runs git in the given directory with the given input and returns its
+// output. The error contains the error output of git.
+func run(dir string, input []byte, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	if input != nil {
+		cmd.Stdin = bytes.NewReader(input)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, errors.New(msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// GitDir returns the absolute path of the git directory of the repository
+// containing the file at the given path, or an error if it is not in a
+// repository
+func GitDir(path string) (string, error) {
+	out, err := run(filepath.Dir(path), nil, "rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// IndexPath returns the path of the index of the repository with the given
+// git directory, which changes when files are staged, committed or checked
+// out
+func IndexPath(gitDir string) string {
+	return filepath.Join(gitDir, "index")
+}
+
+// Head returns the text of the file at the given path in the last commit
+func Head(path string) ([]byte, error) {
+	return show(path, "HEAD")
+}
+
+// Staged returns the text of the file at the given path in the index
+func Staged(path string) ([]byte, error) {
+	return show(path, "")
+}
+
+func show(path, rev string) ([]byte, error) {
+	dir, name := filepath.Split(path)
+	return run(dir, nil, "show", rev+":./"+name)
+}
+
+// Stage replaces the text of the file at the given path in the index with
+// the given text, leaving the file itself unchanged
+func Stage(path string, text []byte) error {
+	dir, name := filepath.Split(path)
+	out, err := run(dir, text, "hash-object", "-w", "--stdin")
+	if err != nil {
+		return err
+	}
+	object := strings.TrimSpace(string(out))
+
+	mode := "100644"
+	out, err = run(dir, nil, "ls-files", "--stage", "--", name)
+	if err != nil {
+		return err
+	}
+	if fields := strings.Fields(string(out)); len(fields) > 0 {
+		mode = fields[0]
+	} else if info, err := os.Stat(path); err == nil && info.Mode()&0111 != 0 {
+		mode = "100755"
+	}
+
+	_, err = run(dir, nil, "update-index", "--add", "--cacheinfo", mode+","+object+","+name)
+	return err
+}
@@ -0,0 +1,81 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newRepo creates a repository with a committed file and returns the path
+// of the file
+func newRepo(t *testing.T) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	gitCmd := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=Tester", "-c", "user.email=tester@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatal(string(out))
+		}
+	}
+
+	path := filepath.Join(dir, "file.txt")
+	gitCmd("init", "-q")
+	if err := os.WriteFile(path, []byte("one\ntwo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gitCmd("add", "file.txt")
+	gitCmd("commit", "-q", "-m", "first")
+	return path
+}
+
+func TestShow(t *testing.T) {
+	path := newRepo(t)
+
+	gitDir, err := GitDir(path)
+	assert.NoError(t, err)
+	expected, _ := filepath.EvalSymlinks(filepath.Join(filepath.Dir(path), ".git"))
+	gitDir, _ = filepath.EvalSymlinks(gitDir)
+	assert.Equal(t, expected, gitDir)
+
+	head, err := Head(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "one\ntwo\n", string(head))
+
+	_, err = GitDir(filepath.Join(t.TempDir(), "file.txt"))
+	assert.Error(t, err)
+}
+
+func TestStage(t *testing.T) {
+	path := newRepo(t)
+
+	assert.NoError(t, Stage(path, []byte("one\nTWO\n")))
+	staged, err := Staged(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "one\nTWO\n", string(staged))
+
+	// the file and the last commit are unchanged
+	head, _ := Head(path)
+	assert.Equal(t, "one\ntwo\n", string(head))
+	data, _ := os.ReadFile(path)
+	assert.Equal(t, "one\ntwo\n", string(data))
+}
+
+func TestBlame(t *testing.T) {
+	path := newRepo(t)
+
+	lines, err := Blame(path, []byte("one\nnew\ntwo\n"))
+	assert.NoError(t, err)
+	if !assert.Len(t, lines, 3) {
+		return
+	}
+	assert.True(t, lines[0].Committed())
+	assert.Equal(t, "Tester", lines[0].Author)
+	assert.Equal(t, "first", lines[0].Summary)
+	assert.False(t, lines[1].Committed())
+	assert.Equal(t, lines[0].Commit, lines[2].Commit)
+}
@@ -9,13 +9,14 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/micro-editor/micro/v2/internal/clipboard"
 	"github.com/micro-editor/micro/v2/internal/config"
 	"github.com/micro-editor/micro/v2/internal/screen"
 	"github.com/micro-editor/micro/v2/internal/util"
 )
 
 // LoadHistory attempts to load user history from configDir/buffers/history
-// into the history map
+// into the history map, and the registers saved by SaveHistory
 // The savehistory option must be on
 func (i *InfoBuf) LoadHistory() {
 	if config.GetGlobalOption("savehistory").(bool) {
@@ -38,11 +39,31 @@ func (i *InfoBuf) LoadHistory() {
 		if decodedMap != nil {
 			i.History = decodedMap
 		}
+
+		i.loadRegisters()
+	}
+}
+
+// loadRegisters restores the named and history registers saved in
+// configDir/buffers/registers
+func (i *InfoBuf) loadRegisters() {
+	file, err := os.Open(filepath.Join(config.ConfigDir, "buffers", "registers"))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			i.Error("Error loading registers: ", err)
+		}
+		return
+	}
+
+	defer file.Close()
+	if err = clipboard.DecodeRegisters(file); err != nil {
+		i.Error("Error decoding registers: ", err)
 	}
 }
 
 // SaveHistory saves the user's command history to configDir/buffers/history
-// only if the savehistory option is on
+// and the registers to configDir/buffers/registers only if the savehistory
+// option is on
 func (i *InfoBuf) SaveHistory() {
 	if config.GetGlobalOption("savehistory").(bool) {
 		// Don't save history past 100
@@ -65,6 +86,18 @@ func (i *InfoBuf) SaveHistory() {
 			screen.TermMessage("Error saving history: ", err)
 			return
 		}
+
+		regs, err := clipboard.EncodeRegisters()
+		if err != nil {
+			screen.TermMessage("Error encoding registers: ", err)
+			return
+		}
+
+		filename = filepath.Join(config.ConfigDir, "buffers", "registers")
+		err = util.SafeWrite(filename, regs, true)
+		if err != nil {
+			screen.TermMessage("Error saving registers: ", err)
+		}
 	}
 }
 
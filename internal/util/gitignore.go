@@ -0,0 +1,181 @@
+package util
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// A GitIgnore decides which files of a directory tree are ignored by git
+// according to the .gitignore files of the tree. The .gitignore files are
+// added with AddParents for the root of the tree, then with AddDir while
+// walking the tree from its root.
+type GitIgnore struct {
+	rules []ignoreRule
+}
+
+type ignoreRule struct {
+	// dir is the absolute path of the directory of the .gitignore file the
+	// rule comes from
+	dir     string
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// AddDir reads the .gitignore file of the given directory, if any. Its
+// rules apply to the files under this directory and take precedence over
+// the rules of the directories above.
+func (g *GitIgnore) AddDir(dir string) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return
+	}
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if r, ok := parseIgnoreRule(dir, scanner.Text()); ok {
+			g.rules = append(g.rules, r)
+		}
+	}
+}
+
+// AddParents reads the .gitignore files of the directories above the given
+// one, up to the root of its git repository, so that the rules of the whole
+// repository apply when only a subdirectory is walked. Nothing is read if
+// the directory is not in a repository.
+func (g *GitIgnore) AddParents(dir string) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return
+	}
+	var parents []string
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			// not in a repository
+			return
+		}
+		dir = parent
+		parents = append(parents, dir)
+	}
+	// the rules of the directories above come first
+	for i := len(parents) - 1; i >= 0; i-- {
+		g.AddDir(parents[i])
+	}
+}
+
+// Ignored returns true if the given path is ignored. The .gitignore files
+// of the directories containing the path must have been added already.
+func (g *GitIgnore) Ignored(path string, isDir bool) bool {
+	if isDir && filepath.Base(path) == ".git" {
+		return true
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	ignored := false
+	for _, r := range g.rules {
+		if r.dirOnly && !isDir || ignored == !r.negate {
+			continue
+		}
+		rel, err := filepath.Rel(r.dir, path)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if r.re.MatchString(filepath.ToSlash(rel)) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// parseIgnoreRule parses a line of a .gitignore file
+func parseIgnoreRule(dir, line string) (ignoreRule, bool) {
+	r := ignoreRule{dir: dir}
+
+	if !strings.HasSuffix(line, `\ `) {
+		line = strings.TrimRight(line, " ")
+	}
+	if line == "" || line[0] == '#' {
+		return r, false
+	}
+	if line[0] == '!' {
+		r.negate = true
+		line = line[1:]
+	} else if line[0] == '\\' {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+
+	// a pattern without a slash but at the end matches at any depth
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return r, false
+	}
+
+	expr := globToRegexp(line)
+	if !anchored {
+		expr = "(?:.*/)?" + expr
+	}
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return r, false
+	}
+	r.re = re
+	return r, true
+}
+
+// globToRegexp converts a gitignore glob to a regular expression
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**") && i+2 == len(glob):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	return b.String()
+}
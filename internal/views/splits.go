@@ -140,6 +140,36 @@ func (n *Node) GetNode(id uint64) *Node {
 	return nil
 }
 
+// Proportions returns the proportions of the size of this node taken by its
+// children, along the direction the node is split in
+func (n *Node) Proportions() []float64 {
+	props := make([]float64, len(n.children))
+	for i, c := range n.children {
+		if n.Kind == STVert {
+			props[i] = float64(c.H) / float64(n.H)
+		} else {
+			props[i] = float64(c.W) / float64(n.W)
+		}
+	}
+	return props
+}
+
+// SetProportions sets the proportions of the size of this node taken by its
+// children, along the direction the node is split in, and resizes them
+func (n *Node) SetProportions(props []float64) {
+	if len(props) != len(n.children) {
+		return
+	}
+	for i, c := range n.children {
+		if n.Kind == STVert {
+			c.propW, c.propH = 1, props[i]
+		} else {
+			c.propW, c.propH = props[i], 1
+		}
+	}
+	n.Resize(n.W, n.H)
+}
+
 func (n *Node) vResizeSplit(i int, size int) bool {
 	if i < 0 || i >= len(n.children) {
 		return false
@@ -0,0 +1,80 @@
+package watcher
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// A poller checks the paths added to it periodically, for the systems
+// without file notifications
+type poller struct {
+	interval time.Duration
+
+	mu      sync.Mutex
+	states  map[string]fileState
+	started bool
+}
+
+// fileState is what is compared between two checks of a path
+type fileState struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+}
+
+func (s fileState) equal(o fileState) bool {
+	return s.exists == o.exists && s.modTime.Equal(o.modTime) && s.size == o.size
+}
+
+func newPoller(interval time.Duration) *poller {
+	return &poller{interval: interval, states: make(map[string]fileState)}
+}
+
+func stat(path string) fileState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{true, info.ModTime(), info.Size()}
+}
+
+func (p *poller) add(path string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.states[path] = stat(path)
+	if !p.started {
+		p.started = true
+		go p.run()
+	}
+	return nil
+}
+
+func (p *poller) remove(path string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.states, path)
+}
+
+func (p *poller) run() {
+	for range time.Tick(p.interval) {
+		p.check()
+	}
+}
+
+// check notifies the paths which have changed since the last check
+func (p *poller) check() {
+	p.mu.Lock()
+	var changed []string
+	for path, s := range p.states {
+		if cur := stat(path); !cur.equal(s) {
+			p.states[path] = cur
+			changed = append(changed, path)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, path := range changed {
+		notify(path)
+	}
+}
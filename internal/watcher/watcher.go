@@ -0,0 +1,141 @@
+// Package watcher reports the changes of files and directories on disk, so
+// that the open buffers can react to them as soon as they happen. It uses
+// the notifications of the operating system when they are available, and
+// falls back to checking the paths periodically.
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// An Event reports that a watched path has changed on disk. Changes in a
+// watched directory are reported as changes of the directory.
+type Event struct {
+	Path string
+	// Removed is true if the path doesn't exist anymore
+	Removed bool
+}
+
+// Events receives the changes of the watched paths. Successive changes of a
+// path in a short time are reported as a single event.
+var Events = make(chan Event, 100)
+
+// debounce is how long the changes of a path are gathered before being
+// reported, since writing a file often produces several notifications
+const debounce = 100 * time.Millisecond
+
+// A backend notifies the changes of the paths added to it
+type backend interface {
+	add(path string) error
+	remove(path string)
+}
+
+var (
+	mu sync.Mutex
+	// watched maps the watched paths to the number of times they are
+	// watched and to the backend watching them
+	watched = make(map[string]*watch)
+	pending = make(map[string]bool)
+	timer   *time.Timer
+
+	initOnce sync.Once
+	native   backend
+	polling  = newPoller(time.Second)
+)
+
+type watch struct {
+	refs    int
+	backend backend
+}
+
+// Watch starts watching the given path, which may not exist yet. Its events
+// report its absolute path. A path watched several times must be unwatched
+// as many times.
+func Watch(path string) {
+	path = absPath(path)
+	initOnce.Do(func() {
+		if b, err := newNative(); err == nil {
+			native = b
+		}
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if w, ok := watched[path]; ok {
+		w.refs++
+		return
+	}
+
+	w := &watch{refs: 1, backend: polling}
+	if native != nil && native.add(path) == nil {
+		w.backend = native
+	} else {
+		polling.add(path)
+	}
+	watched[path] = w
+}
+
+// Unwatch stops watching the given path
+func Unwatch(path string) {
+	path = absPath(path)
+	mu.Lock()
+	defer mu.Unlock()
+	w, ok := watched[path]
+	if !ok {
+		return
+	}
+	w.refs--
+	if w.refs == 0 {
+		w.backend.remove(path)
+		delete(watched, path)
+		delete(pending, path)
+	}
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// fallback makes the poller watch a path which the native backend can't
+// watch anymore, e.g. because its directory has been removed
+func fallback(path string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if w, ok := watched[path]; ok && w.backend != polling {
+		w.backend = polling
+		polling.add(path)
+	}
+}
+
+// notify is called by the backends when a path may have changed
+func notify(path string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := watched[path]; !ok {
+		return
+	}
+	pending[path] = true
+	if timer == nil {
+		timer = time.AfterFunc(debounce, flush)
+	}
+}
+
+// flush sends the events of the pending changes
+func flush() {
+	mu.Lock()
+	paths := pending
+	pending = make(map[string]bool)
+	timer = nil
+	mu.Unlock()
+
+	for path := range paths {
+		_, err := os.Stat(path)
+		Events <- Event{path, os.IsNotExist(err)}
+	}
+}
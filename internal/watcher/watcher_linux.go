@@ -0,0 +1,150 @@
+package watcher
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_MODIFY | syscall.IN_ATTRIB | syscall.IN_CLOSE_WRITE |
+	syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+	syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+// inotify watches the directories of the watched paths with the inotify
+// API of Linux. A file is watched through its directory so that it is still
+// watched after being replaced, e.g. by an editor saving it by renaming a
+// new file over it.
+type inotify struct {
+	fd int
+
+	mu   sync.Mutex
+	dirs map[string]*inotifyDir
+	wds  map[int]*inotifyDir
+}
+
+// An inotifyDir is a directory watched because it is a watched path
+// itself, or because it contains watched files
+type inotifyDir struct {
+	path  string
+	wd    int
+	self  bool
+	files map[string]bool
+}
+
+func newNative() (backend, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	w := &inotify{
+		fd:   fd,
+		dirs: make(map[string]*inotifyDir),
+		wds:  make(map[int]*inotifyDir),
+	}
+	go w.run()
+	return w, nil
+}
+
+func (w *inotify) add(path string) error {
+	dir, name := filepath.Dir(path), filepath.Base(path)
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		dir, name = path, ""
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	d, ok := w.dirs[dir]
+	if !ok {
+		wd, err := syscall.InotifyAddWatch(w.fd, dir, inotifyMask)
+		if err != nil {
+			return err
+		}
+		d = &inotifyDir{path: dir, wd: wd, files: make(map[string]bool)}
+		w.dirs[dir] = d
+		w.wds[wd] = d
+	}
+	if name == "" {
+		d.self = true
+	} else {
+		d.files[name] = true
+	}
+	return nil
+}
+
+func (w *inotify) remove(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if d, ok := w.dirs[path]; ok && d.self {
+		d.self = false
+		w.release(d)
+	} else if d, ok := w.dirs[filepath.Dir(path)]; ok {
+		delete(d.files, filepath.Base(path))
+		w.release(d)
+	}
+}
+
+// release stops watching a directory if no watched path needs it anymore
+func (w *inotify) release(d *inotifyDir) {
+	if d.self || len(d.files) > 0 {
+		return
+	}
+	syscall.InotifyRmWatch(w.fd, uint32(d.wd))
+	delete(w.dirs, d.path)
+	delete(w.wds, d.wd)
+}
+
+func (w *inotify) run() {
+	var buf [64 * (syscall.SizeofInotifyEvent + syscall.NAME_MAX + 1)]byte
+	for {
+		n, err := syscall.Read(w.fd, buf[:])
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil || n <= 0 {
+			return
+		}
+
+		var changed, lost []string
+		w.mu.Lock()
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			e := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := string(bytes.TrimRight(buf[nameStart:nameStart+int(e.Len)], "\x00"))
+			offset = nameStart + int(e.Len)
+
+			d, ok := w.wds[int(e.Wd)]
+			if !ok {
+				continue
+			}
+			if d.self {
+				changed = append(changed, d.path)
+			}
+			if name != "" && d.files[name] {
+				changed = append(changed, filepath.Join(d.path, name))
+			}
+			if e.Mask&syscall.IN_IGNORED != 0 {
+				// the directory has been removed, so have its files
+				if d.self {
+					lost = append(lost, d.path)
+				}
+				for f := range d.files {
+					changed = append(changed, filepath.Join(d.path, f))
+					lost = append(lost, filepath.Join(d.path, f))
+				}
+				delete(w.dirs, d.path)
+				delete(w.wds, d.wd)
+			}
+		}
+		w.mu.Unlock()
+
+		for _, path := range lost {
+			fallback(path)
+		}
+		for _, path := range changed {
+			notify(path)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package watcher
+
+import "errors"
+
+func newNative() (backend, error) {
+	return nil, errors.New("File notifications are not supported on this system")
+}
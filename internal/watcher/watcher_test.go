@@ -0,0 +1,79 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func nextEvent(t *testing.T) Event {
+	select {
+	case e := <-Events:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event")
+		return Event{}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file.txt")
+
+	// the file doesn't exist yet
+	Watch(path)
+	defer Unwatch(path)
+
+	assert.NoError(t, os.WriteFile(path, []byte("one"), 0644))
+	assert.Equal(t, Event{path, false}, nextEvent(t))
+
+	// replacing the file by renaming another one over it
+	tmp := filepath.Join(dir, "tmp")
+	assert.NoError(t, os.WriteFile(tmp, []byte("two"), 0644))
+	assert.NoError(t, os.Rename(tmp, path))
+	assert.Equal(t, Event{path, false}, nextEvent(t))
+
+	assert.NoError(t, os.Remove(path))
+	assert.Equal(t, Event{path, true}, nextEvent(t))
+}
+
+func TestWatchDir(t *testing.T) {
+	dir := t.TempDir()
+	Watch(dir)
+	defer Unwatch(dir)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "new"), nil, 0644))
+	assert.Equal(t, Event{dir, false}, nextEvent(t))
+}
+
+func TestPoller(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	p := newPoller(time.Hour)
+	p.states[path] = stat(path)
+	mu.Lock()
+	watched[path] = &watch{refs: 1, backend: p}
+	mu.Unlock()
+	defer Unwatch(path)
+
+	assert.NoError(t, os.WriteFile(path, []byte("one"), 0644))
+	p.check()
+	assert.Equal(t, Event{path, false}, nextEvent(t))
+
+	// same modification time, different size
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(path, []byte("three"), 0644))
+	assert.NoError(t, os.Chtimes(path, info.ModTime(), info.ModTime()))
+	p.check()
+	assert.Equal(t, Event{path, false}, nextEvent(t))
+
+	p.check()
+	select {
+	case e := <-Events:
+		t.Errorf("unexpected event %v", e)
+	case <-time.After(2 * debounce):
+	}
+}
@@ -54,6 +54,17 @@ func runePos(p int, str []byte) int {
 // A State represents the region at the end of a line
 type State *region
 
+// InRegion returns true if the state s is inside the region r, either
+// directly or in one of its nested regions
+func InRegion(s, r State) bool {
+	for ; s != nil; s = s.parent {
+		if s == r {
+			return true
+		}
+	}
+	return false
+}
+
 // LineStates is an interface for a buffer-like object which can also store the states and matches for every line
 type LineStates interface {
 	LineBytes(n int) []byte
@@ -5,6 +5,10 @@ local filepath = import("path/filepath")
 local shell = import("micro/shell")
 
 function onBufferOpen(buf)
+	-- the diff base is set by micro itself unless gitdiffbase is off
+	if buf.Settings["gitdiffbase"] ~= "off" then
+		return
+	end
 	if buf.Settings["diffgutter"] and (not buf.Type.Scratch) and (buf.Path ~= "") then
 		-- check that file exists
 		local _, err = os.Stat(buf.AbsPath)
@@ -1,6 +1,11 @@
-VERSION = "1.0.0"
+VERSION = "1.0.1"
 
 function onBufferOpen(b)
+    -- the indentation of the file itself and .editorconfig files come first
+    if b:IndentDetected() or b:SettingOrigin("tabstospaces") ~= "" then
+        return
+    end
+
     local ft = b:FileType()
 
     if ft == "go" or